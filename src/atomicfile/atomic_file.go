@@ -0,0 +1,31 @@
+// The package atomicfile writes the files the service persists its state to (i.e. the quota usage, the modes of the
+// circuit breakers and the cache snapshot), so that these are never left half written.
+package atomicfile
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Write replaces the file with the content. The content is written (and synced) to a temporary file alongside it,
+// which is then renamed over the file, so that a crash leaves either the old or the new content.
+func Write(path string, content []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
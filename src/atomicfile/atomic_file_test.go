@@ -0,0 +1,28 @@
+package atomicfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Write(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+
+	assert.NoError(t, Write(path, []byte(`{"version":1}`)))
+	assert.NoError(t, Write(path, []byte(`{"version":2}`)))
+
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"version":2}`, string(content))
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1, "The temporary files are removed")
+}
+
+func Test_WriteToAMissingDirectory(t *testing.T) {
+	assert.Error(t, Write(filepath.Join(t.TempDir(), "missing", "state.json"), []byte("{}")))
+}
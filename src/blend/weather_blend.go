@@ -0,0 +1,158 @@
+// The package blend combines the readings of several weather services, into a single reading.
+package blend
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/ColinSchofield/zai-weather/src/model"
+)
+
+// The Method used to blend the readings.
+type Method string
+
+const (
+	// Median takes the median of the temperatures and of the wind speeds
+	Median Method = "median"
+	// WeightedMean weights the readings by the trust placed in each weather service
+	WeightedMean Method = "weighted"
+	// PrimaryOutlier takes the reference reading, unless it is an outlier when compared with the other readings
+	PrimaryOutlier Method = "outlier"
+)
+
+// A Reading from a weather service, along with the trust placed in it.
+type Reading struct {
+	model.Reading
+	Trust float64
+}
+
+// The Blender combines the readings of the weather services.
+type Blender struct {
+	Method Method
+	// The provider whose reading is kept by the outlier method (should it have answered)
+	Reference string
+	// A reference temperature further than this from the median of the other readings is an outlier
+	OutlierDegrees float64
+}
+
+// ParseMethod returns the Method with the given name.
+func ParseMethod(name string) (Method, error) {
+	switch method := Method(name); method {
+	case Median, WeightedMean, PrimaryOutlier:
+		return method, nil
+	default:
+		return "", fmt.Errorf("unknown blend method %q", name)
+	}
+}
+
+// Blend combines the readings into the weather information, listing each of the readings and their disagreement.
+func (b Blender) Blend(readings []Reading) (*model.Weather, error) {
+	if len(readings) == 0 {
+		return nil, fmt.Errorf("there are no readings to blend")
+	}
+
+	var data model.Data
+	switch b.Method {
+	case Median:
+		data = median(readings)
+	case WeightedMean:
+		data = weightedMean(readings)
+	case PrimaryOutlier:
+		data = b.primaryOutlier(readings)
+	default:
+		return nil, fmt.Errorf("unknown blend method %q", b.Method)
+	}
+
+	blend := &model.Blend{Method: string(b.Method), Disagreement: disagreement(readings)}
+	for _, reading := range readings {
+		blend.Readings = append(blend.Readings, reading.Reading)
+	}
+
+	return &model.Weather{Data: &data, Blend: blend}, nil
+}
+
+func median(readings []Reading) model.Data {
+	return data(medianValues(readings))
+}
+
+func weightedMean(readings []Reading) model.Data {
+	var temperature, windSpeed, weights float64
+	for _, reading := range readings {
+		temperature += reading.Trust * reading.Temperature
+		windSpeed += reading.Trust * reading.WindSpeed
+		weights += reading.Trust
+	}
+	if weights <= 0 {
+		return median(readings)
+	}
+
+	return data(model.Values{Temperature: temperature / weights, WindSpeed: windSpeed / weights})
+}
+
+// The reference reading is kept, unless it is an outlier when compared with the median of the other readings. Should
+// the reference have failed, there is nothing to keep, so the median of the readings is taken.
+func (b Blender) primaryOutlier(readings []Reading) model.Data {
+	var others []Reading
+	var reference *Reading
+	for i := range readings {
+		if readings[i].Provider == b.Reference && reference == nil {
+			reference = &readings[i]
+		} else {
+			others = append(others, readings[i])
+		}
+	}
+	if reference == nil {
+		return median(readings)
+	}
+	if len(others) == 0 {
+		return data(reference.Values)
+	}
+
+	consensus := medianValues(others)
+	if math.Abs(reference.Temperature-consensus.Temperature) > b.OutlierDegrees {
+		return data(consensus)
+	}
+
+	return data(reference.Values)
+}
+
+// The spread of the temperatures (in degrees celsius).
+func disagreement(readings []Reading) float64 {
+	lowest, highest := readings[0].Temperature, readings[0].Temperature
+	for _, reading := range readings[1:] {
+		lowest = min(lowest, reading.Temperature)
+		highest = max(highest, reading.Temperature)
+	}
+
+	return highest - lowest
+}
+
+func medianValues(readings []Reading) model.Values {
+	temperatures := make([]float64, len(readings))
+	windSpeeds := make([]float64, len(readings))
+	for i, reading := range readings {
+		temperatures[i] = reading.Temperature
+		windSpeeds[i] = reading.WindSpeed
+	}
+
+	return model.Values{Temperature: medianOf(temperatures), WindSpeed: medianOf(windSpeeds)}
+}
+
+// The blended values, rounded to whole numbers.
+func data(values model.Values) model.Data {
+	return model.Data{
+		Temperature: int(math.Round(values.Temperature)),
+		WindSpeed:   int(math.Round(values.WindSpeed)),
+	}
+}
+
+func medianOf(values []float64) float64 {
+	sort.Float64s(values)
+	middle := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[middle-1] + values[middle]) / 2
+	}
+
+	return values[middle]
+}
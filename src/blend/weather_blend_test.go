@@ -0,0 +1,122 @@
+package blend_test
+
+import (
+	"testing"
+
+	"github.com/ColinSchofield/zai-weather/src/blend"
+	"github.com/ColinSchofield/zai-weather/src/model"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type BlendTestSuite struct {
+	suite.Suite
+
+	readings []blend.Reading
+}
+
+func TestBlendSuite(t *testing.T) {
+	suite.Run(t, new(BlendTestSuite))
+}
+
+func (s *BlendTestSuite) SetupTest() {
+	s.readings = []blend.Reading{
+		reading("primary", 10, 20, 3),
+		reading("failover", 20, 10, 1),
+		reading("other", 12, 16, 0),
+	}
+}
+
+func (s *BlendTestSuite) Test_Median() {
+	// When
+	weather, err := blend.Blender{Method: blend.Median}.Blend(s.readings)
+	// Then
+	s.Assert().NoError(err)
+	s.Assert().Equal(model.Data{Temperature: 12, WindSpeed: 16}, *weather.Data)
+	s.Assert().Equal("median", weather.Blend.Method)
+	s.Assert().Equal(float64(10), weather.Blend.Disagreement, "the spread of the temperatures")
+	s.Assert().Len(weather.Blend.Readings, 3, "the raw values of each weather service are listed")
+	s.Assert().Equal("failover", weather.Blend.Readings[1].Provider)
+}
+
+func (s *BlendTestSuite) Test_MedianOfAnEvenNumberOfReadings() {
+	// When
+	weather, err := blend.Blender{Method: blend.Median}.Blend(s.readings[:2])
+	// Then
+	s.Assert().NoError(err)
+	s.Assert().Equal(model.Data{Temperature: 15, WindSpeed: 15}, *weather.Data)
+}
+
+func (s *BlendTestSuite) Test_WeightedMean() {
+	// When
+	weather, err := blend.Blender{Method: blend.WeightedMean}.Blend(s.readings)
+	// Then
+	s.Assert().NoError(err)
+	s.Assert().Equal(model.Data{Temperature: 13, WindSpeed: 18}, *weather.Data, "weighted 3:1:0")
+}
+
+func (s *BlendTestSuite) Test_PrimaryOutlier() {
+	// When
+	weather, err := blend.Blender{Method: blend.PrimaryOutlier, Reference: "primary", OutlierDegrees: 5}.Blend(s.readings)
+	// Then
+	s.Assert().NoError(err)
+	s.Assert().Equal(model.Data{Temperature: 16, WindSpeed: 13}, *weather.Data, "the primary is 6 degrees from the others")
+	// When
+	weather, err = blend.Blender{Method: blend.PrimaryOutlier, Reference: "primary", OutlierDegrees: 6}.Blend(s.readings)
+	// Then
+	s.Assert().NoError(err)
+	s.Assert().Equal(model.Data{Temperature: 10, WindSpeed: 20}, *weather.Data, "the primary is trusted")
+}
+
+func (s *BlendTestSuite) Test_ReferenceOutlier() {
+	// When
+	weather, err := blend.Blender{Method: blend.PrimaryOutlier, Reference: "failover", OutlierDegrees: 5}.Blend(s.readings)
+	// Then
+	s.Assert().NoError(err)
+	s.Assert().Equal(model.Data{Temperature: 11, WindSpeed: 18}, *weather.Data,
+		"the failover is 9 degrees from the others, whichever the order of the readings")
+}
+
+func (s *BlendTestSuite) Test_ReferenceFailed() {
+	// When
+	weather, err := blend.Blender{Method: blend.PrimaryOutlier, Reference: "primary", OutlierDegrees: 5}.
+		Blend(s.readings[1:])
+	// Then
+	s.Assert().NoError(err)
+	s.Assert().Equal(model.Data{Temperature: 16, WindSpeed: 13}, *weather.Data,
+		"the median, rather than trusting whichever reading came first")
+}
+
+func (s *BlendTestSuite) Test_UntruncatedValues() {
+	// Given
+	readings := []blend.Reading{reading("primary", 10.4, 20.6, 1), reading("failover", 11.5, 20.2, 1)}
+	// When
+	weather, err := blend.Blender{Method: blend.Median}.Blend(readings)
+	// Then
+	s.Assert().NoError(err)
+	s.Assert().Equal(model.Data{Temperature: 11, WindSpeed: 20}, *weather.Data, "rounded once blended")
+	s.Assert().Equal(10.4, weather.Blend.Readings[0].Temperature, "the raw values are listed")
+	s.Assert().InDelta(1.1, weather.Blend.Disagreement, 1e-9)
+}
+
+func (s *BlendTestSuite) Test_InvalidBlends() {
+	// When
+	_, err := blend.Blender{Method: blend.Median}.Blend(nil)
+	// Then
+	s.Assert().Error(err)
+	// When
+	_, err = blend.Blender{Method: "mode"}.Blend(s.readings)
+	// Then
+	s.Assert().Error(err)
+	// When
+	_, err = blend.ParseMethod("mode")
+	// Then
+	s.Assert().Error(err)
+}
+
+func reading(provider string, temperature, windSpeed, trust float64) blend.Reading {
+	return blend.Reading{
+		Reading: model.Reading{Provider: provider, Values: model.Values{Temperature: temperature, WindSpeed: windSpeed}},
+		Trust:   trust,
+	}
+}
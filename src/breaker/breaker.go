@@ -0,0 +1,225 @@
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ColinSchofield/zai-weather/src/model"
+	"github.com/ColinSchofield/zai-weather/src/service"
+
+	"github.com/sony/gobreaker/v2"
+)
+
+// ErrForcedOpen is returned when the circuit breaker has been forced open (see the admin API).
+var ErrForcedOpen = errors.New("the circuit breaker is forced open")
+
+// ErrSuspended is returned while the circuit breaker is suspended, as the quota of the weather service is exhausted.
+var ErrSuspended = errors.New("the circuit breaker is suspended until the quota is renewed")
+
+// The Mode of a circuit breaker, as set by an operator. A forced mode is kept until it is explicitly released (even
+// across a restart), whatever the outcome of the requests.
+type Mode string
+
+const (
+	// Auto trips the circuit breaker on the failures of the weather service
+	Auto Mode = "auto"
+	// ForcedOpen rejects every request
+	ForcedOpen Mode = "forced-open"
+	// ForcedClosed lets every request through, without counting its outcome
+	ForcedClosed Mode = "forced-closed"
+	// Disabled skips the weather service, as if it were not configured
+	Disabled Mode = "disabled"
+)
+
+// ParseMode returns the mode of the circuit breaker by its name.
+func ParseMode(name string) (Mode, error) {
+	switch mode := Mode(name); mode {
+	case Auto, ForcedOpen, ForcedClosed, Disabled:
+		return mode, nil
+	default:
+		return "", errors.New("unknown circuit breaker mode " + name)
+	}
+}
+
+// The CircuitBreaker interface that guards the calls to a weather service (met by both the gobreaker and Breaker).
+type CircuitBreaker interface {
+	Name() string
+	Execute(req func() (interface{}, error)) (interface{}, error)
+}
+
+// The Suspender interface is met by a circuit breaker that may be suspended (i.e. the Breaker, but not the gobreaker).
+type Suspender interface {
+	Suspend(until time.Time)
+}
+
+// The Breaker is a circuit breaker, that may be forced into a mode or reset by an operator.
+type Breaker struct {
+	id       string
+	settings func() Settings
+	build    func(settings Settings) *gobreaker.CircuitBreaker[any]
+
+	mu        sync.RWMutex
+	cb        *gobreaker.CircuitBreaker[any]
+	built     Settings // Those the circuit breaker was built with
+	mode      Mode
+	suspended time.Time // Rejects the requests (in the auto mode) until then, as the quota is exhausted
+}
+
+var (
+	_ CircuitBreaker = (*Breaker)(nil)
+	_ Suspender      = (*Breaker)(nil)
+)
+
+// Returns a breaker (in the auto mode), with the circuit breaker built by the function given from the settings.
+func newBreaker(id string, settings func() Settings, build func(settings Settings) *gobreaker.CircuitBreaker[any]) *Breaker {
+	built := settings()
+	return &Breaker{
+		id:       id,
+		settings: settings,
+		build:    build,
+		cb:       build(built),
+		built:    built,
+		mode:     Auto,
+	}
+}
+
+// ID returns the short name of the breaker, as used by the admin API (e.g. primary).
+func (b *Breaker) ID() string {
+	return b.id
+}
+
+// Name returns the name of the weather service the breaker guards.
+func (b *Breaker) Name() string {
+	return b.breaker().Name()
+}
+
+// Mode returns the mode set by the operator.
+func (b *Breaker) Mode() Mode {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.mode
+}
+
+// Available returns true should a request be let through (i.e. the breaker is not open nor suspended, nor forced open
+// or disabled).
+func (b *Breaker) Available() bool {
+	b.mu.RLock()
+	cb, mode, suspended := b.cb, b.mode, b.suspended
+	b.mu.RUnlock()
+
+	switch mode {
+	case Disabled, ForcedOpen:
+		return false
+	case ForcedClosed:
+		return true
+	default:
+		return cb.State() != gobreaker.StateOpen && !time.Now().Before(suspended)
+	}
+}
+
+// Execute runs the request through the circuit breaker, unless the mode forces the outcome (or, in the auto mode, the
+// breaker is suspended).
+func (b *Breaker) Execute(req func() (interface{}, error)) (interface{}, error) {
+	b.mu.RLock()
+	cb, mode, suspended := b.cb, b.mode, b.suspended
+	b.mu.RUnlock()
+
+	switch mode {
+	case Disabled:
+		return nil, &service.FetchError{Kind: service.KindUnavailable, Provider: cb.Name(), Err: service.ErrDisabled}
+	case ForcedOpen:
+		return nil, &service.FetchError{Kind: service.KindUnavailable, Provider: cb.Name(), Err: ErrForcedOpen}
+	case ForcedClosed:
+		return req()
+	default:
+		if remaining := time.Until(suspended); remaining > 0 {
+			return nil, &service.FetchError{
+				Kind:       service.KindQuotaExceeded,
+				Provider:   cb.Name(),
+				RetryAfter: remaining,
+				Err:        ErrSuspended,
+			}
+		}
+		return cb.Execute(req)
+	}
+}
+
+// Suspend rejects the requests until the time given (i.e. once the quota of the weather service is renewed), unless
+// the mode forces the outcome. Forcing the mode, or a reset, lifts the suspension.
+func (b *Breaker) Suspend(until time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.suspended = until
+}
+
+// Status returns the mode, the state (along with any suspension) and the counts of the circuit breaker.
+func (b *Breaker) Status() model.BreakerStatus {
+	b.mu.RLock()
+	cb, mode, suspended := b.cb, b.mode, b.suspended
+	b.mu.RUnlock()
+
+	counts := cb.Counts()
+	status := model.BreakerStatus{
+		ID:    b.id,
+		Name:  cb.Name(),
+		Mode:  string(mode),
+		State: cb.State().String(),
+		Counts: model.BreakerCounts{
+			Requests:             counts.Requests,
+			TotalSuccesses:       counts.TotalSuccesses,
+			TotalFailures:        counts.TotalFailures,
+			TotalExclusions:      counts.TotalExclusions,
+			ConsecutiveSuccesses: counts.ConsecutiveSuccesses,
+			ConsecutiveFailures:  counts.ConsecutiveFailures,
+		},
+	}
+	if time.Now().Before(suspended) {
+		status.SuspendedUntil = &suspended
+	}
+
+	return status
+}
+
+// Set the mode of the circuit breaker, lifting any suspension.
+func (b *Breaker) setMode(mode Mode) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.mode, b.suspended = mode, time.Time{}
+}
+
+// Replace the circuit breaker with a new one (i.e. closed, with its counts cleared), built from the current settings,
+// lifting any suspension. The mode is kept.
+func (b *Breaker) reset() {
+	built := b.settings()
+	cb := b.build(built)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.cb, b.built, b.suspended = cb, built, time.Time{}
+}
+
+// Returns true should the settings only read when the circuit breaker is built (the half open requests, the interval
+// and the open timeout) have changed since.
+func (b *Breaker) outdated() bool {
+	current := b.settings()
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return current.HalfOpenRequests != b.built.HalfOpenRequests ||
+		current.Interval != b.built.Interval ||
+		current.OpenTimeout != b.built.OpenTimeout
+}
+
+// Returns the current circuit breaker.
+func (b *Breaker) breaker() *gobreaker.CircuitBreaker[any] {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.cb
+}
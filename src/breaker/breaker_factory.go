@@ -0,0 +1,247 @@
+// The package breaker builds the circuit breakers of the weather services, from their configuration, which may be
+// forced into a mode (or reset) by an operator.
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ColinSchofield/zai-weather/src/config"
+	"github.com/ColinSchofield/zai-weather/src/metrics"
+	"github.com/ColinSchofield/zai-weather/src/model"
+	"github.com/ColinSchofield/zai-weather/src/quota"
+	"github.com/ColinSchofield/zai-weather/src/service"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sony/gobreaker/v2"
+)
+
+// The Policy used to trip a circuit breaker.
+type Policy string
+
+const (
+	// Ratio trips once the failure ratio is reached (following at least the configured number of requests)
+	Ratio Policy = "ratio"
+	// Consecutive trips once the configured number of consecutive failures is reached
+	Consecutive Policy = "consecutive"
+)
+
+// ErrUnknownBreaker is returned when there is no circuit breaker of the id given.
+var ErrUnknownBreaker = errors.New("unknown circuit breaker")
+
+// The most recent changes of state kept, across all the circuit breakers.
+const maxEvents = 100
+
+// The Settings of a circuit breaker.
+type Settings struct {
+	Policy              Policy
+	Requests            uint32
+	FailureRatio        float64
+	ConsecutiveFailures uint32
+	HalfOpenRequests    uint32        // Let through once half open
+	Interval            time.Duration // Clears the counts while closed (zero never clears them)
+	OpenTimeout         time.Duration // How long the breaker stays open, before it is half open
+}
+
+// PrimarySettings returns the settings of the circuit breaker of the primary (Weather Stack) service.
+func PrimarySettings(cfg *config.WeatherConfig) Settings {
+	return Settings{
+		Policy:              Policy(cfg.PrimaryBreakerPolicy),
+		Requests:            cfg.PrimaryRequests,
+		FailureRatio:        cfg.PrimaryFailureRatio,
+		ConsecutiveFailures: cfg.PrimaryConsecutiveFailures,
+		HalfOpenRequests:    cfg.PrimaryHalfOpenRequests,
+		Interval:            time.Duration(cfg.PrimaryBreakerIntervalSeconds) * time.Second,
+		OpenTimeout:         time.Duration(cfg.PrimaryBreakerOpenSeconds) * time.Second,
+	}
+}
+
+// FailoverSettings returns the settings of the circuit breaker of the failover (Open Weather Map) service.
+func FailoverSettings(cfg *config.WeatherConfig) Settings {
+	return Settings{
+		Policy:              Policy(cfg.FailoverBreakerPolicy),
+		Requests:            cfg.FailoverRequests,
+		FailureRatio:        cfg.FailoverFailureRatio,
+		ConsecutiveFailures: cfg.FailoverConsecutiveFailures,
+		HalfOpenRequests:    cfg.FailoverHalfOpenRequests,
+		Interval:            time.Duration(cfg.FailoverBreakerIntervalSeconds) * time.Second,
+		OpenTimeout:         time.Duration(cfg.FailoverBreakerOpenSeconds) * time.Second,
+	}
+}
+
+// ReadyToTrip returns true once the counts of a closed circuit breaker breach the policy.
+func (s Settings) ReadyToTrip(counts gobreaker.Counts) bool {
+	if s.Policy == Consecutive {
+		return counts.ConsecutiveFailures >= s.ConsecutiveFailures
+	}
+
+	// The excluded requests (see IsExcluded) count neither way
+	requests := counts.Requests - counts.TotalExclusions
+	failureRatio := float64(counts.TotalFailures) / float64(requests)
+	return requests >= s.Requests && failureRatio >= s.FailureRatio
+}
+
+// The breaker.Factory interface builds the circuit breakers, recording their changes of state, and lets an operator
+// force their mode or reset them.
+type Factory interface {
+	New(id, name string, settings func() Settings) *Breaker
+	Breakers() []*Breaker
+	SetMode(id string, mode Mode) (*Breaker, error)
+	Reset(id string) (*Breaker, error)
+	Reconfigure()
+	Events() []model.BreakerEvent
+}
+
+type DefaultFactory struct {
+	log   *logrus.Logger
+	store Store
+	now   func() time.Time
+
+	mu       sync.Mutex
+	breakers []*Breaker
+	events   []model.BreakerEvent
+}
+
+var _ Factory = (*DefaultFactory)(nil)
+
+// NewFactory returns the default factory of the circuit breakers, with their forced modes persisted to the store.
+func NewFactory(log *logrus.Logger, store Store) *DefaultFactory {
+	return &DefaultFactory{
+		log:   log,
+		store: store,
+		now:   time.Now,
+	}
+}
+
+// New returns a circuit breaker, in the mode last forced (if any). The trip policy is read from the settings on each
+// failure (so it may be reloaded), whereas the half open requests, the interval and the open timeout are only read
+// when the breaker is built (see Reconfigure).
+func (f *DefaultFactory) New(id, name string, settings func() Settings) *Breaker {
+	b := newBreaker(id, settings, func(initial Settings) *gobreaker.CircuitBreaker[any] {
+		metrics.BreakerState.WithLabelValues(name).Set(float64(gobreaker.StateClosed))
+
+		return gobreaker.NewCircuitBreaker[any](gobreaker.Settings{
+			Name:        name,
+			MaxRequests: initial.HalfOpenRequests,
+			Interval:    initial.Interval,
+			Timeout:     initial.OpenTimeout,
+			ReadyToTrip: func(counts gobreaker.Counts) bool {
+				return settings().ReadyToTrip(counts)
+			},
+			OnStateChange: f.onStateChange,
+			IsExcluded:    IsExcluded,
+		})
+	})
+	if mode, found := f.store.Load(id); found {
+		f.log.WithField("mode", mode).Warn("The circuit breaker of ", name, " is still forced")
+		b.setMode(mode)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.breakers = append(f.breakers, b)
+	return b
+}
+
+// Breakers returns all the circuit breakers (in the order these were built).
+func (f *DefaultFactory) Breakers() []*Breaker {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]*Breaker{}, f.breakers...)
+}
+
+// SetMode forces the mode of the circuit breaker (the auto mode releases it), saving the mode so that it survives a
+// restart.
+func (f *DefaultFactory) SetMode(id string, mode Mode) (*Breaker, error) {
+	b, err := f.breaker(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.store.Save(id, mode); err != nil {
+		return nil, fmt.Errorf("failed to save the mode of the circuit breaker: %w", err)
+	}
+
+	b.setMode(mode)
+	return b, nil
+}
+
+// Reset closes the circuit breaker, clearing its counts (its mode is kept).
+func (f *DefaultFactory) Reset(id string) (*Breaker, error) {
+	b, err := f.breaker(id)
+	if err != nil {
+		return nil, err
+	}
+
+	from := b.breaker().State()
+	b.reset()
+	if from != gobreaker.StateClosed {
+		f.onStateChange(b.Name(), from, gobreaker.StateClosed)
+	}
+	return b, nil
+}
+
+// Reconfigure rebuilds (i.e. resets) each circuit breaker whose half open requests, interval or open timeout have
+// changed, once the configuration is reloaded.
+func (f *DefaultFactory) Reconfigure() {
+	for _, b := range f.Breakers() {
+		if b.outdated() {
+			f.log.Info("Rebuilt the circuit breaker of ", b.Name(), " with its reloaded settings")
+			_, _ = f.Reset(b.ID()) // The breaker is known
+		}
+	}
+}
+
+// Events returns the most recent changes of state of the circuit breakers (the oldest first).
+func (f *DefaultFactory) Events() []model.BreakerEvent {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]model.BreakerEvent{}, f.events...)
+}
+
+// Returns the circuit breaker of the id.
+func (f *DefaultFactory) breaker(id string) (*Breaker, error) {
+	for _, b := range f.Breakers() {
+		if b.ID() == id {
+			return b, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnknownBreaker, id)
+}
+
+// Log, count and record the change of state of the circuit breaker.
+func (f *DefaultFactory) onStateChange(name string, from, to gobreaker.State) {
+	entry := f.log.WithField("from", from.String()).WithField("to", to.String())
+	if to == gobreaker.StateOpen {
+		entry.Warn("Opened the circuit breaker of ", name)
+	} else {
+		entry.Info("Changed the state of the circuit breaker of ", name)
+	}
+	metrics.BreakerState.WithLabelValues(name).Set(float64(to))
+	metrics.BreakerTransitions.WithLabelValues(name, from.String(), to.String()).Inc()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.events = append(f.events, model.BreakerEvent{Breaker: name, From: from.String(), To: to.String(), At: f.now()})
+	if len(f.events) > maxEvents {
+		f.events = f.events[len(f.events)-maxEvents:]
+	}
+}
+
+// IsExcluded returns true should the error count neither for nor against the circuit breaker (so that only an answer
+// of the weather service may close it). A fetch that was cancelled by the hedging of the other weather service, a
+// location that could not be found, or a weather service skipped as its budget is exhausted (or it is disabled), are
+// all excluded.
+func IsExcluded(err error) bool {
+	return errors.Is(err, context.Canceled) ||
+		errors.Is(err, service.ErrDisabled) ||
+		errors.Is(err, quota.ErrBudgetExhausted) ||
+		service.IsNotFound(err)
+}
@@ -0,0 +1,317 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ColinSchofield/zai-weather/src/config"
+	"github.com/ColinSchofield/zai-weather/src/quota"
+	"github.com/ColinSchofield/zai-weather/src/service"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sony/gobreaker/v2"
+	"github.com/stretchr/testify/suite"
+)
+
+type BreakerFactoryTestSuite struct {
+	suite.Suite
+
+	factory  *DefaultFactory
+	store    *FileStore
+	now      time.Time
+	failure  error
+	settings Settings
+}
+
+func TestBreakerFactorySuite(t *testing.T) {
+	suite.Run(t, new(BreakerFactoryTestSuite))
+}
+
+func (s *BreakerFactoryTestSuite) SetupTest() {
+	s.now = time.Date(2023, time.December, 15, 10, 0, 0, 0, time.UTC)
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	s.store, _ = NewFileStore("")
+	s.factory = NewFactory(log, s.store)
+	s.factory.now = func() time.Time { return s.now }
+	s.failure = errors.New("failed")
+	s.settings = Settings{
+		Policy:              Ratio,
+		Requests:            3,
+		FailureRatio:        0.6,
+		ConsecutiveFailures: 2,
+		HalfOpenRequests:    1,
+		OpenTimeout:         time.Minute,
+	}
+}
+
+func (s *BreakerFactoryTestSuite) newBreaker() *Breaker {
+	return s.factory.New("test", "test", func() Settings { return s.settings })
+}
+
+func (s *BreakerFactoryTestSuite) execute(cb *Breaker, err error) error {
+	_, err = cb.Execute(func() (interface{}, error) { return nil, err })
+	return err
+}
+
+func (s *BreakerFactoryTestSuite) Test_RatioPolicy() {
+	// Given
+	cb := s.newBreaker()
+	// When
+	_ = s.execute(cb, nil)
+	_ = s.execute(cb, s.failure)
+	// Then
+	s.Assert().Equal(gobreaker.StateClosed, cb.breaker().State(), "Not enough requests yet")
+	// When
+	_ = s.execute(cb, s.failure)
+	// Then
+	s.Assert().Equal(gobreaker.StateOpen, cb.breaker().State())
+}
+
+func (s *BreakerFactoryTestSuite) Test_ConsecutivePolicy() {
+	// Given
+	s.settings.Policy = Consecutive
+	cb := s.newBreaker()
+	// When
+	_ = s.execute(cb, s.failure)
+	_ = s.execute(cb, nil)
+	_ = s.execute(cb, s.failure)
+	// Then
+	s.Assert().Equal(gobreaker.StateClosed, cb.breaker().State())
+	// When
+	_ = s.execute(cb, s.failure)
+	// Then
+	s.Assert().Equal(gobreaker.StateOpen, cb.breaker().State())
+}
+
+func (s *BreakerFactoryTestSuite) Test_ReloadedPolicy() {
+	// Given
+	cb := s.newBreaker()
+	_ = s.execute(cb, s.failure)
+	// When
+	s.settings.Policy = Consecutive
+	s.settings.ConsecutiveFailures = 1
+	_ = s.execute(cb, s.failure)
+	// Then
+	s.Assert().Equal(gobreaker.StateOpen, cb.breaker().State())
+}
+
+func (s *BreakerFactoryTestSuite) Test_EventsAreRecorded() {
+	// Given
+	s.settings.Policy = Consecutive
+	s.settings.ConsecutiveFailures = 1
+	cb := s.newBreaker()
+	// When
+	_ = s.execute(cb, s.failure)
+	// Then
+	events := s.factory.Events()
+	s.Require().Len(events, 1)
+	s.Assert().Equal("test", events[0].Breaker)
+	s.Assert().Equal("closed", events[0].From)
+	s.Assert().Equal("open", events[0].To)
+	s.Assert().Equal(s.now, events[0].At)
+}
+
+func (s *BreakerFactoryTestSuite) Test_OnlyTheMostRecentEventsAreKept() {
+	// When
+	for i := 0; i < maxEvents+10; i++ {
+		s.factory.onStateChange(fmt.Sprint(i), gobreaker.StateClosed, gobreaker.StateOpen)
+	}
+	// Then
+	events := s.factory.Events()
+	s.Assert().Len(events, maxEvents)
+	s.Assert().Equal("10", events[0].Breaker)
+}
+
+func (s *BreakerFactoryTestSuite) Test_SettingsFromTheConfig() {
+	// Given
+	cfg := &config.WeatherConfig{}
+	cfg.PrimaryBreakerPolicy = "consecutive"
+	cfg.PrimaryConsecutiveFailures = 4
+	cfg.PrimaryBreakerOpenSeconds = 30
+	cfg.FailoverRequests = 7
+	cfg.FailoverBreakerIntervalSeconds = 10
+	// Then
+	primary, failover := PrimarySettings(cfg), FailoverSettings(cfg)
+	s.Assert().Equal(Consecutive, primary.Policy)
+	s.Assert().Equal(uint32(4), primary.ConsecutiveFailures)
+	s.Assert().Equal(30*time.Second, primary.OpenTimeout)
+	s.Assert().Equal(uint32(7), failover.Requests)
+	s.Assert().Equal(10*time.Second, failover.Interval)
+}
+
+func (s *BreakerFactoryTestSuite) Test_IsExcluded() {
+	s.Assert().False(IsExcluded(nil))
+	s.Assert().True(IsExcluded(context.Canceled))
+	s.Assert().True(IsExcluded(quota.ErrBudgetExhausted))
+	s.Assert().True(IsExcluded(&service.FetchError{Kind: service.KindNotFound}))
+	s.Assert().True(IsExcluded(&service.FetchError{Kind: service.KindUnavailable, Err: service.ErrDisabled}))
+	s.Assert().False(IsExcluded(&service.FetchError{Kind: service.KindUnavailable}))
+	s.Assert().False(IsExcluded(s.failure))
+}
+
+func (s *BreakerFactoryTestSuite) Test_ExcludedDoNotCloseTheHalfOpenBreaker() {
+	// Given
+	s.settings.Policy = Consecutive
+	s.settings.ConsecutiveFailures = 1
+	s.settings.OpenTimeout = 100 * time.Millisecond
+	cb := s.newBreaker()
+	_ = s.execute(cb, s.failure)
+	s.Require().Eventually(func() bool {
+		return cb.Status().State == "half-open"
+	}, time.Second, 10*time.Millisecond)
+	// When
+	_ = s.execute(cb, context.Canceled)
+	// Then
+	s.Assert().Equal("half-open", cb.Status().State, "The cancelled fetch neither closes nor opens the breaker")
+	s.Assert().Equal(uint32(1), cb.Status().Counts.TotalExclusions)
+	s.Assert().NoError(s.execute(cb, nil), "Its half open request is given back")
+	s.Assert().Equal("closed", cb.Status().State)
+}
+
+func (s *BreakerFactoryTestSuite) Test_ForcedOpen() {
+	// Given
+	cb := s.newBreaker()
+	// When
+	_, err := s.factory.SetMode("test", ForcedOpen)
+	// Then
+	s.Require().NoError(err)
+	s.Assert().ErrorIs(s.execute(cb, nil), ErrForcedOpen)
+	s.Assert().False(cb.Available())
+	s.Assert().Equal(service.KindUnavailable, service.KindOf(s.execute(cb, nil)))
+}
+
+func (s *BreakerFactoryTestSuite) Test_ForcedClosed() {
+	// Given
+	s.settings.Policy = Consecutive
+	s.settings.ConsecutiveFailures = 1
+	cb := s.newBreaker()
+	_ = s.execute(cb, s.failure)
+	s.Require().Equal(gobreaker.StateOpen, cb.breaker().State())
+	s.Assert().False(cb.Available())
+	// When
+	_, _ = s.factory.SetMode("test", ForcedClosed)
+	// Then
+	s.Assert().NoError(s.execute(cb, nil), "The open breaker is bypassed")
+	s.Assert().True(cb.Available())
+	s.Assert().ErrorIs(s.execute(cb, s.failure), s.failure)
+	s.Assert().Equal(ForcedClosed, cb.Mode(), "The failures do not release the mode")
+}
+
+func (s *BreakerFactoryTestSuite) Test_Disabled() {
+	// Given
+	cb := s.newBreaker()
+	// When
+	_, _ = s.factory.SetMode("test", Disabled)
+	// Then
+	err := s.execute(cb, nil)
+	s.Assert().ErrorIs(err, service.ErrDisabled)
+	s.Assert().True(IsExcluded(err))
+}
+
+func (s *BreakerFactoryTestSuite) Test_Suspended() {
+	// Given
+	cb := s.newBreaker()
+	// When
+	cb.Suspend(time.Now().Add(time.Hour))
+	// Then
+	err := s.execute(cb, nil)
+	s.Assert().ErrorIs(err, ErrSuspended)
+	s.Assert().Equal(service.KindQuotaExceeded, service.KindOf(err))
+	s.Assert().Greater(service.RetryAfterOf(err), 59*time.Minute)
+	s.Assert().False(cb.Available())
+	s.Assert().NotNil(cb.Status().SuspendedUntil)
+	// When
+	_, err = s.factory.Reset("test")
+	// Then
+	s.Require().NoError(err)
+	s.Assert().NoError(s.execute(cb, nil), "The reset lifts the suspension")
+	s.Assert().Nil(cb.Status().SuspendedUntil)
+	// When
+	cb.Suspend(time.Now().Add(time.Hour))
+	_, _ = s.factory.SetMode("test", ForcedClosed)
+	// Then
+	s.Assert().NoError(s.execute(cb, nil), "Forcing the mode lifts the suspension")
+	s.Assert().True(cb.Available())
+}
+
+func (s *BreakerFactoryTestSuite) Test_Released() {
+	// Given
+	cb := s.newBreaker()
+	_, _ = s.factory.SetMode("test", ForcedOpen)
+	// When
+	_, _ = s.factory.SetMode("test", Auto)
+	// Then
+	s.Assert().NoError(s.execute(cb, nil))
+	_, found := s.store.Load("test")
+	s.Assert().False(found, "The auto mode is not kept")
+}
+
+func (s *BreakerFactoryTestSuite) Test_Reset() {
+	// Given
+	s.settings.Policy = Consecutive
+	s.settings.ConsecutiveFailures = 1
+	cb := s.newBreaker()
+	_ = s.execute(cb, s.failure)
+	// When
+	_, err := s.factory.Reset("test")
+	// Then
+	s.Require().NoError(err)
+	status := cb.Status()
+	s.Assert().Equal("closed", status.State)
+	s.Assert().Zero(status.Counts.Requests)
+	events := s.factory.Events()
+	s.Assert().Equal("closed", events[len(events)-1].To)
+}
+
+func (s *BreakerFactoryTestSuite) Test_Reconfigure() {
+	// Given
+	s.settings.Policy = Consecutive
+	s.settings.ConsecutiveFailures = 1
+	cb := s.newBreaker()
+	_ = s.execute(cb, s.failure)
+	// When
+	s.factory.Reconfigure()
+	// Then
+	s.Assert().Equal("open", cb.Status().State, "Unchanged, so not rebuilt")
+	// When
+	s.settings.OpenTimeout = time.Second
+	s.factory.Reconfigure()
+	// Then
+	s.Assert().Equal("closed", cb.Status().State, "Rebuilt, as the open timeout was reloaded")
+	_ = s.execute(cb, s.failure)
+	s.Assert().Eventually(func() bool {
+		return cb.Status().State == "half-open"
+	}, 3*time.Second, 50*time.Millisecond, "Half open after the reloaded open timeout")
+}
+
+func (s *BreakerFactoryTestSuite) Test_UnknownBreaker() {
+	// When
+	_, err := s.factory.SetMode("unknown", ForcedOpen)
+	// Then
+	s.Assert().ErrorIs(err, ErrUnknownBreaker)
+	_, err = s.factory.Reset("unknown")
+	s.Assert().ErrorIs(err, ErrUnknownBreaker)
+}
+
+func (s *BreakerFactoryTestSuite) Test_ForcedModeSurvivesRestart() {
+	// Given
+	path := filepath.Join(s.T().TempDir(), "breakers.json")
+	store, err := NewFileStore(path)
+	s.Require().NoError(err)
+	s.factory.store = store
+	s.newBreaker()
+	_, err = s.factory.SetMode("test", Disabled)
+	s.Require().NoError(err)
+	// When
+	restarted, err := NewFileStore(path)
+	s.Require().NoError(err)
+	s.factory = NewFactory(s.factory.log, restarted)
+	// Then
+	s.Assert().Equal(Disabled, s.newBreaker().Mode())
+}
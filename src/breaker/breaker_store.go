@@ -0,0 +1,87 @@
+package breaker
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+
+	"github.com/ColinSchofield/zai-weather/src/atomicfile"
+)
+
+// The breaker.Store interface persists the modes forced by an operator, so that these survive a restart.
+type Store interface {
+	Load(id string) (Mode, bool)
+	Save(id string, mode Mode) error
+}
+
+type FileStore struct {
+	path string
+
+	mu    sync.Mutex
+	modes map[string]Mode
+}
+
+var _ Store = (*FileStore)(nil)
+
+// NewFileStore reads the modes from the JSON file (if it exists). An empty path keeps the modes in memory only.
+func NewFileStore(path string) (*FileStore, error) {
+	store := &FileStore{
+		path:  path,
+		modes: make(map[string]Mode),
+	}
+	if path == "" {
+		return store, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, &store.modes); err != nil {
+		return nil, err
+	}
+	for id, mode := range store.modes {
+		if _, err := ParseMode(string(mode)); err != nil {
+			return nil, err
+		}
+		if mode == Auto {
+			delete(store.modes, id)
+		}
+	}
+
+	return store, nil
+}
+
+// Load returns the mode of the circuit breaker, if one was forced.
+func (f *FileStore) Load(id string) (Mode, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	mode, found := f.modes[id]
+	return mode, found
+}
+
+// Save writes the modes to the file (the auto mode is not kept).
+func (f *FileStore) Save(id string, mode Mode) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if mode == Auto {
+		delete(f.modes, id)
+	} else {
+		f.modes[id] = mode
+	}
+	if f.path == "" {
+		return nil
+	}
+
+	content, err := json.Marshal(f.modes)
+	if err != nil {
+		return err
+	}
+
+	return atomicfile.Write(f.path, content)
+}
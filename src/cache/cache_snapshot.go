@@ -0,0 +1,156 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ColinSchofield/zai-weather/src/atomicfile"
+	"github.com/ColinSchofield/zai-weather/src/model"
+
+	"github.com/sirupsen/logrus"
+)
+
+// The version of the snapshot format, which is checked when the snapshot is loaded.
+const snapshotVersion = 1
+
+// The snapshot of the cache, as written to the file.
+type snapshot struct {
+	Version int             `json:"version"`
+	SavedAt time.Time       `json:"saved_at"`
+	Entries []snapshotEntry `json:"entries"`
+}
+
+type snapshotEntry struct {
+	Key        string         `json:"key"`
+	Source     string         `json:"source"`
+	StoredAt   time.Time      `json:"stored_at"`
+	TTLSeconds int            `json:"ttl_seconds,omitempty"` // The default TTL when missing
+	ObservedAt *time.Time     `json:"observed_at,omitempty"`
+	FetchedAt  *time.Time     `json:"fetched_at,omitempty"`
+	Weather    *model.Weather `json:"weather"`
+}
+
+// The Snapshotter saves the weather information of the cache to a (versioned JSON) file, so that the last known
+// values survive a restart.
+type Snapshotter struct {
+	log          *logrus.Logger
+	weatherCache Weather
+	path         string
+	maxAge       time.Duration
+	now          func() time.Time
+}
+
+// NewSnapshotter returns a snapshotter of the cache to the file. The entries older than the maximum age are
+// discarded when the snapshot is loaded.
+func NewSnapshotter(log *logrus.Logger, weatherCache Weather, path string, maxAge time.Duration) *Snapshotter {
+	return &Snapshotter{
+		log:          log,
+		weatherCache: weatherCache,
+		path:         path,
+		maxAge:       maxAge,
+		now:          time.Now,
+	}
+}
+
+// Load restores the entries of the snapshot (if it exists) into the cache, returning how many were restored. A
+// corrupt (or unknown version of) snapshot is logged and ignored.
+func (s *Snapshotter) Load() int {
+	content, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0
+	} else if err != nil {
+		s.log.WithError(err).WithField("file", s.path).Error("Ignored the unreadable cache snapshot")
+		return 0
+	}
+
+	var saved snapshot
+	if err := json.Unmarshal(content, &saved); err != nil {
+		s.log.WithError(err).WithField("file", s.path).Error("Ignored the corrupt cache snapshot")
+		return 0
+	}
+	if saved.Version != snapshotVersion {
+		s.log.WithField("file", s.path).WithField("version", saved.Version).Error("Ignored the cache snapshot version")
+		return 0
+	}
+
+	restored, discarded := 0, 0
+	for _, entry := range saved.Entries {
+		if entry.Key == "" || entry.Weather == nil || s.now().Sub(entry.StoredAt) > s.maxAge {
+			discarded++
+			continue
+		}
+		if entry.ObservedAt != nil {
+			entry.Weather.ObservedAt = *entry.ObservedAt
+		}
+		if entry.FetchedAt != nil {
+			entry.Weather.FetchedAt = *entry.FetchedAt
+		}
+		s.weatherCache.Restore(Entry{
+			Key:      entry.Key,
+			Value:    entry.Weather,
+			Source:   entry.Source,
+			StoredAt: entry.StoredAt,
+			TTL:      time.Duration(entry.TTLSeconds) * time.Second,
+		})
+		restored++
+	}
+	s.log.WithField("restored", restored).WithField("discarded", discarded).Info("Loaded the cache snapshot")
+
+	return restored
+}
+
+// Save writes the weather information of the cache to the file (replacing the last snapshot atomically).
+func (s *Snapshotter) Save() error {
+	saved := snapshot{Version: snapshotVersion, SavedAt: s.now(), Entries: []snapshotEntry{}}
+	for _, entry := range s.weatherCache.Entries() {
+		if weather, ok := entry.Value.(*model.Weather); ok {
+			saved.Entries = append(saved.Entries, snapshotEntry{
+				Key:        entry.Key,
+				Source:     entry.Source,
+				StoredAt:   entry.StoredAt,
+				TTLSeconds: int(entry.TTL / time.Second),
+				ObservedAt: timeOrNil(weather.ObservedAt),
+				FetchedAt:  timeOrNil(weather.FetchedAt),
+				Weather:    weather,
+			})
+		}
+	}
+
+	content, err := json.Marshal(saved)
+	if err != nil {
+		return fmt.Errorf("failed to encode the cache snapshot: %w", err)
+	}
+
+	return atomicfile.Write(s.path, content)
+}
+
+// Run saves the snapshot at the interval given, until the context is done (the final snapshot is left to the
+// shutdown).
+func (s *Snapshotter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Save(); err != nil {
+				s.log.WithError(err).WithField("file", s.path).Error("Failed to save the cache snapshot")
+			}
+		}
+	}
+}
+
+// Returns the time, or nil should it be the zero time (so that it is omitted from the snapshot).
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+
+	return &t
+}
@@ -0,0 +1,114 @@
+package cache_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ColinSchofield/zai-weather/src/cache"
+	"github.com/ColinSchofield/zai-weather/src/model"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/suite"
+)
+
+type CacheSnapshotTestSuite struct {
+	suite.Suite
+
+	log  *logrus.Logger
+	logs *test.Hook
+	path string
+}
+
+func TestCacheSnapshotSuite(t *testing.T) {
+	suite.Run(t, new(CacheSnapshotTestSuite))
+}
+
+func (s *CacheSnapshotTestSuite) SetupTest() {
+	s.log = logrus.New()
+	s.log.SetOutput(io.Discard)
+	s.logs = test.NewLocal(s.log)
+	s.path = filepath.Join(s.T().TempDir(), "cache-snapshot.json")
+}
+
+func (s *CacheSnapshotTestSuite) snapshotter(weatherCache cache.Weather) *cache.Snapshotter {
+	return cache.NewSnapshotter(s.log, weatherCache, s.path, time.Hour)
+}
+
+func (s *CacheSnapshotTestSuite) Test_SavedThenLoaded() {
+	// Given
+	saved := cache.NewWeatherCache(time.Minute)
+	observed := time.Now().Add(-5 * time.Minute).UTC().Truncate(time.Second)
+	weather := &model.Weather{Status: 200, Data: &model.Data{Temperature: 10, WindSpeed: 5}, ObservedAt: observed}
+	saved.Set("Melbourne", weather, "primary", 2*time.Minute)
+	s.Require().NoError(s.snapshotter(saved).Save())
+	// When
+	loaded := cache.NewWeatherCache(time.Minute)
+	restored := s.snapshotter(loaded).Load()
+	// Then
+	s.Assert().Equal(1, restored)
+	entry, found := loaded.Lookup("Melbourne")
+	s.Require().True(found)
+	s.Assert().Equal("primary", entry.Source)
+	s.Assert().True(entry.Fresh, "Still within its TTL")
+	s.Assert().Equal(2*time.Minute, entry.TTL, "The TTL of the entry is kept")
+	s.Assert().Equal(10, entry.Value.(*model.Weather).Data.Temperature)
+	s.Assert().True(observed.Equal(entry.Value.(*model.Weather).ObservedAt), "The observation time is kept")
+	original, _ := saved.Lookup("Melbourne")
+	s.Assert().True(original.StoredAt.Equal(entry.StoredAt), "The age is kept")
+}
+
+func (s *CacheSnapshotTestSuite) Test_StaleEntriesAreOnlyServedIgnoringTTL() {
+	// Given
+	saved := cache.NewWeatherCache(time.Minute)
+	saved.Restore(cache.Entry{Key: "Sydney", Value: &model.Weather{}, Source: "failover",
+		StoredAt: time.Now().Add(-10 * time.Minute)})
+	s.Require().NoError(s.snapshotter(saved).Save())
+	// When
+	loaded := cache.NewWeatherCache(time.Minute)
+	s.snapshotter(loaded).Load()
+	// Then
+	_, found := loaded.Get("Sydney")
+	s.Assert().False(found, "Past its TTL")
+	_, found = loaded.GetIgnoreTTL("Sydney")
+	s.Assert().True(found)
+}
+
+func (s *CacheSnapshotTestSuite) Test_EntriesOlderThanTheMaxAgeAreDiscarded() {
+	// Given
+	saved := cache.NewWeatherCache(time.Minute)
+	saved.Restore(cache.Entry{Key: "Perth", Value: &model.Weather{}, StoredAt: time.Now().Add(-2 * time.Hour)})
+	saved.Set("Hobart", &model.Weather{}, "primary", 0)
+	s.Require().NoError(s.snapshotter(saved).Save())
+	// When
+	loaded := cache.NewWeatherCache(time.Minute)
+	restored := s.snapshotter(loaded).Load()
+	// Then
+	s.Assert().Equal(1, restored)
+	_, found := loaded.Lookup("Perth")
+	s.Assert().False(found)
+	s.Assert().Equal(1, s.logs.LastEntry().Data["discarded"])
+}
+
+func (s *CacheSnapshotTestSuite) Test_CorruptSnapshotIsIgnored() {
+	for _, content := range []string{`{"version":1,"entries":[`, `{"version":99,"entries":[]}`} {
+		// Given
+		s.Require().NoError(os.WriteFile(s.path, []byte(content), 0o600))
+		// When
+		restored := s.snapshotter(cache.NewWeatherCache(time.Minute)).Load()
+		// Then
+		s.Assert().Zero(restored)
+		s.Assert().Equal(logrus.ErrorLevel, s.logs.LastEntry().Level)
+	}
+}
+
+func (s *CacheSnapshotTestSuite) Test_MissingSnapshot() {
+	// When
+	restored := s.snapshotter(cache.NewWeatherCache(time.Minute)).Load()
+	// Then
+	s.Assert().Zero(restored)
+	s.Assert().Empty(s.logs.AllEntries())
+}
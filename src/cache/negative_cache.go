@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+)
+
+// The cache.Negative interface remembers the locations that could not be found, so they need not be fetched again.
+type Negative interface {
+	Contains(key string) bool
+	Add(key string)
+	Delete(key string) bool
+	DeletePrefix(prefix string) int
+	Flush() int
+}
+
+type DefaultNegativeCache struct {
+	ttlCache *cache.Cache
+	ttl      atomic.Int64
+}
+
+var _ Negative = (*DefaultNegativeCache)(nil)
+
+// NewNegativeCache creates a TTL cache of the unknown locations. A TTL of zero disables the negative caching.
+func NewNegativeCache(ttl time.Duration) *DefaultNegativeCache {
+	negative := &DefaultNegativeCache{
+		ttlCache: cache.New(cache.NoExpiration, time.Minute),
+	}
+	negative.SetTTL(ttl)
+
+	return negative
+}
+
+// SetTTL changes the TTL of the locations added from now on (i.e. when the configuration is reloaded).
+func (n *DefaultNegativeCache) SetTTL(ttl time.Duration) {
+	n.ttl.Store(int64(ttl))
+	if ttl <= 0 {
+		n.ttlCache.Flush()
+	}
+}
+
+// Contains returns true, if the location could not be found and the TTL has not expired.
+func (n *DefaultNegativeCache) Contains(key string) bool {
+	if n.ttl.Load() <= 0 {
+		return false
+	}
+
+	_, found := n.ttlCache.Get(key)
+	return found
+}
+
+// Add remembers that the location could not be found.
+func (n *DefaultNegativeCache) Add(key string) {
+	ttl := time.Duration(n.ttl.Load())
+	if ttl <= 0 {
+		return
+	}
+
+	n.ttlCache.Set(key, struct{}{}, ttl)
+}
+
+// Delete forgets the location, returning true should it have been remembered.
+func (n *DefaultNegativeCache) Delete(key string) bool {
+	_, found := n.ttlCache.Get(key)
+	n.ttlCache.Delete(key)
+
+	return found
+}
+
+// DeletePrefix forgets the locations that start with the prefix (ignoring case), returning how many were forgotten.
+func (n *DefaultNegativeCache) DeletePrefix(prefix string) int {
+	deleted := 0
+	for key := range n.ttlCache.Items() {
+		if strings.HasPrefix(strings.ToLower(key), strings.ToLower(prefix)) && n.Delete(key) {
+			deleted++
+		}
+	}
+
+	return deleted
+}
+
+// Flush forgets all the locations, returning how many were forgotten.
+func (n *DefaultNegativeCache) Flush() int {
+	deleted := n.ttlCache.ItemCount()
+	n.ttlCache.Flush()
+
+	return deleted
+}
@@ -0,0 +1,70 @@
+package cache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ColinSchofield/zai-weather/src/cache"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type NegativeCacheTestSuite struct {
+	suite.Suite
+
+	negativeCache cache.Negative
+}
+
+func TestNegativeCacheSuite(t *testing.T) {
+	suite.Run(t, new(NegativeCacheTestSuite))
+}
+
+func (s *NegativeCacheTestSuite) SetupTest() {
+	s.negativeCache = cache.NewNegativeCache(200 * time.Millisecond)
+}
+
+func (s *NegativeCacheTestSuite) Test_UnknownLocationIsRemembered() {
+	// When
+	s.negativeCache.Add("asdfgh")
+	// Then
+	s.Assert().True(s.negativeCache.Contains("asdfgh"))
+	s.Assert().False(s.negativeCache.Contains("Melbourne"))
+}
+
+func (s *NegativeCacheTestSuite) Test_UnknownLocationIsForgottenOnceTTLHasExpired() {
+	// When
+	s.negativeCache.Add("asdfgh")
+	time.Sleep(300 * time.Millisecond)
+	// Then
+	s.Assert().False(s.negativeCache.Contains("asdfgh"))
+}
+
+func (s *NegativeCacheTestSuite) Test_Delete() {
+	// Given
+	s.negativeCache.Add("asdfgh")
+	s.negativeCache.Add("Asdzxc")
+	s.negativeCache.Add("qwerty")
+	// Then
+	s.Assert().True(s.negativeCache.Delete("qwerty"))
+	s.Assert().False(s.negativeCache.Delete("qwerty"), "Already forgotten")
+	s.Assert().Equal(2, s.negativeCache.DeletePrefix("asd"), "Ignoring case")
+	s.Assert().False(s.negativeCache.Contains("Asdzxc"))
+}
+
+func (s *NegativeCacheTestSuite) Test_Flush() {
+	// Given
+	s.negativeCache.Add("asdfgh")
+	s.negativeCache.Add("qwerty")
+	// Then
+	s.Assert().Equal(2, s.negativeCache.Flush())
+	s.Assert().False(s.negativeCache.Contains("asdfgh"))
+}
+
+func (s *NegativeCacheTestSuite) Test_ZeroTTLDisablesTheCache() {
+	// Given
+	s.negativeCache = cache.NewNegativeCache(0)
+	// When
+	s.negativeCache.Add("asdfgh")
+	// Then
+	s.Assert().False(s.negativeCache.Contains("asdfgh"))
+}
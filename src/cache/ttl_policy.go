@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ColinSchofield/zai-weather/src/config"
+	"github.com/ColinSchofield/zai-weather/src/model"
+)
+
+// The weather services, whose TTL may be configured.
+const (
+	ProviderPrimary  = "primary"
+	ProviderFailover = "failover"
+)
+
+// The cache.TTLPolicy interface derives how long the weather information of a location is cached for, from the
+// weather service it came from (empty for a blend of the weather services).
+type TTLPolicy interface {
+	TTL(location, provider string, weather *model.Weather) time.Duration
+}
+
+type DefaultTTLPolicy struct {
+	cfg config.Source
+	now func() time.Time
+}
+
+var _ TTLPolicy = (*DefaultTTLPolicy)(nil)
+
+// NewTTLPolicy returns the default struct for the TTL policy, following the (reloadable) configuration.
+func NewTTLPolicy(cfg config.Source) *DefaultTTLPolicy {
+	return &DefaultTTLPolicy{
+		cfg: cfg,
+		now: time.Now,
+	}
+}
+
+// TTL returns the TTL of the first location rule that matches. Otherwise the hints of the weather service are honored
+// (bounded by the global TTL and the max TTL), falling back to the TTL of the weather service, then the global TTL.
+func (p *DefaultTTLPolicy) TTL(location, provider string, weather *model.Weather) time.Duration {
+	cfg := p.cfg.Current()
+	rules, _ := cfg.LocationTTLs() // The rules were validated, when the configuration was loaded
+	for _, rule := range rules {
+		if matched, _ := path.Match(rule.Pattern, strings.ToLower(location)); matched {
+			return rule.TTL
+		}
+	}
+
+	global := time.Duration(cfg.CacheTTLSeconds) * time.Second
+	providerTTL := time.Duration(0)
+	switch provider {
+	case ProviderPrimary:
+		providerTTL = time.Duration(cfg.TTLPrimarySeconds) * time.Second
+	case ProviderFailover:
+		providerTTL = time.Duration(cfg.TTLFailoverSeconds) * time.Second
+	}
+
+	if cfg.TTLHonorUpstream && weather != nil {
+		if ttl, found := p.upstream(weather, providerTTL); found {
+			return min(max(ttl, global), time.Duration(cfg.TTLMaxSeconds)*time.Second)
+		}
+	}
+	if providerTTL > 0 {
+		return providerTTL
+	}
+
+	return global
+}
+
+// Returns the TTL hinted at by the weather service: its max age, otherwise until its next observation is due (given
+// the interval it updates at). An observation that is overdue gives a zero TTL, so that it is soon checked again.
+func (p *DefaultTTLPolicy) upstream(weather *model.Weather, interval time.Duration) (time.Duration, bool) {
+	if weather.MaxAge > 0 {
+		return weather.MaxAge, true
+	}
+	if !weather.ObservedAt.IsZero() && interval > 0 {
+		return weather.ObservedAt.Add(interval).Sub(p.now()), true
+	}
+
+	return 0, false
+}
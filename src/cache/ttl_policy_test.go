@@ -0,0 +1,77 @@
+package cache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ColinSchofield/zai-weather/src/cache"
+	"github.com/ColinSchofield/zai-weather/src/config"
+	"github.com/ColinSchofield/zai-weather/src/model"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type TTLPolicyTestSuite struct {
+	suite.Suite
+
+	cfg    *config.WeatherConfig
+	policy cache.TTLPolicy
+}
+
+func TestTTLPolicySuite(t *testing.T) {
+	suite.Run(t, new(TTLPolicyTestSuite))
+}
+
+func (s *TTLPolicyTestSuite) SetupTest() {
+	s.cfg = &config.WeatherConfig{
+		CacheTTLSeconds: 3,
+		TTLConfig: config.TTLConfig{
+			TTLLocations:       []string{"Darwin=600", "mel*=300"},
+			TTLHonorUpstream:   true,
+			TTLMaxSeconds:      900,
+			TTLPrimarySeconds:  60,
+			TTLFailoverSeconds: 0,
+		},
+	}
+	s.policy = cache.NewTTLPolicy(s.cfg)
+}
+
+func (s *TTLPolicyTestSuite) Test_LocationRuleWins() {
+	// Given
+	weather := &model.Weather{MaxAge: 30 * time.Second}
+	// Then
+	s.Assert().Equal(600*time.Second, s.policy.TTL("darwin", cache.ProviderPrimary, weather), "Ignoring case")
+	s.Assert().Equal(300*time.Second, s.policy.TTL("Melbourne", cache.ProviderFailover, weather), "By the pattern")
+	s.Assert().Equal(30*time.Second, s.policy.TTL("Sydney", cache.ProviderPrimary, weather))
+}
+
+func (s *TTLPolicyTestSuite) Test_UpstreamMaxAgeIsBounded() {
+	s.Assert().Equal(3*time.Second, s.policy.TTL("Sydney", cache.ProviderPrimary, &model.Weather{MaxAge: time.Second}),
+		"At least the global TTL")
+	s.Assert().Equal(900*time.Second, s.policy.TTL("Sydney", cache.ProviderPrimary, &model.Weather{MaxAge: time.Hour}),
+		"At most the max TTL")
+}
+
+func (s *TTLPolicyTestSuite) Test_UntilTheNextObservation() {
+	// Given
+	recent := &model.Weather{ObservedAt: time.Now().Add(-20 * time.Second)}
+	overdue := &model.Weather{ObservedAt: time.Now().Add(-time.Hour)}
+	// Then
+	s.Assert().InDelta(40*time.Second, s.policy.TTL("Sydney", cache.ProviderPrimary, recent), float64(time.Second))
+	s.Assert().Equal(3*time.Second, s.policy.TTL("Sydney", cache.ProviderPrimary, overdue), "Checked again soon")
+	s.Assert().Equal(3*time.Second, s.policy.TTL("Sydney", cache.ProviderFailover, recent),
+		"Without an interval, the observation says nothing of the next")
+}
+
+func (s *TTLPolicyTestSuite) Test_WithoutHints() {
+	s.Assert().Equal(60*time.Second, s.policy.TTL("Sydney", cache.ProviderPrimary, &model.Weather{}))
+	s.Assert().Equal(3*time.Second, s.policy.TTL("Sydney", cache.ProviderFailover, &model.Weather{}))
+	s.Assert().Equal(3*time.Second, s.policy.TTL("Sydney", "", &model.Weather{}), "A blend")
+}
+
+func (s *TTLPolicyTestSuite) Test_UpstreamIgnored() {
+	// Given
+	s.cfg.TTLHonorUpstream = false
+	// Then
+	s.Assert().Equal(60*time.Second, s.policy.TTL("Sydney", cache.ProviderPrimary, &model.Weather{MaxAge: time.Hour}))
+}
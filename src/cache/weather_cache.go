@@ -2,45 +2,166 @@
 package cache
 
 import (
+	"sort"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/patrickmn/go-cache"
 )
 
-// The cache.Weather interface provides cached access to weather information based on (or disregarding) TTL.
+// The Entry of the cache, along with the weather service it came from, when it was stored and for how long.
+type Entry struct {
+	Key      string
+	Value    any
+	Source   string
+	StoredAt time.Time
+	TTL      time.Duration
+	Fresh    bool // Within its TTL
+}
+
+// The cache.Weather interface provides cached access to weather information based on (or disregarding) TTL, along
+// with its administration (i.e. listing and purging the entries).
 type Weather interface {
 	Get(key string) (any, bool)
 	GetIgnoreTTL(key string) (any, bool)
-	Set(key string, value any)
+	Set(key string, value any, source string, ttl time.Duration)
+	Restore(entry Entry)
+	Entries() []Entry
+	Lookup(key string) (Entry, bool)
+	Delete(key string) bool
+	DeletePrefix(prefix string) int
+	Flush() int
 }
 
 type DefaultWeatherCache struct {
 	ttlCache    *cache.Cache
 	nonTTLCache *cache.Cache
+	ttl         atomic.Int64
 }
 
 var _ Weather = (*DefaultWeatherCache)(nil)
 
 // NewWeatherCache internally creates a TTL and a non-TTL cache (used in the failure edge case).
 func NewWeatherCache(ttl time.Duration) *DefaultWeatherCache {
-	return &DefaultWeatherCache{
+	weatherCache := &DefaultWeatherCache{
 		ttlCache:    cache.New(ttl, 10*ttl),
 		nonTTLCache: cache.New(cache.NoExpiration, cache.NoExpiration),
 	}
+	weatherCache.SetTTL(ttl)
+
+	return weatherCache
+}
+
+// SetTTL changes the default TTL of the values set from now on (i.e. when the configuration is reloaded).
+func (w *DefaultWeatherCache) SetTTL(ttl time.Duration) {
+	w.ttl.Store(int64(ttl))
 }
 
 // Get wraps the cache.Get method, returning a value if the TTL has not expired.
 func (w *DefaultWeatherCache) Get(key string) (any, bool) {
-	return w.ttlCache.Get(key)
+	return value(w.ttlCache.Get(key))
 }
 
 // GetIgnoreTTL wraps the cache.Get method, with values read from the non-TTL cache.
 func (w *DefaultWeatherCache) GetIgnoreTTL(key string) (any, bool) {
-	return w.nonTTLCache.Get(key)
+	return value(w.nonTTLCache.Get(key))
+}
+
+// Set wraps the cache.Set method, storing the values (from the weather service given) into the TTL and the non-TTL
+// cache. A zero TTL is the default TTL.
+func (w *DefaultWeatherCache) Set(key string, value any, source string, ttl time.Duration) {
+	entry := &Entry{Key: key, Value: value, Source: source, StoredAt: time.Now(), TTL: w.orDefault(ttl)}
+	w.ttlCache.Set(key, entry, entry.TTL)
+	w.nonTTLCache.SetDefault(key, entry)
+}
+
+// Restore puts back the entry (i.e. from a snapshot), keeping when it was stored. The entry is only fresh for what
+// remains of its TTL (the default TTL, should it have none).
+func (w *DefaultWeatherCache) Restore(entry Entry) {
+	stored := &Entry{
+		Key:      entry.Key,
+		Value:    entry.Value,
+		Source:   entry.Source,
+		StoredAt: entry.StoredAt,
+		TTL:      w.orDefault(entry.TTL),
+	}
+	if remaining := stored.TTL - time.Since(entry.StoredAt); remaining > 0 {
+		w.ttlCache.Set(entry.Key, stored, remaining)
+	}
+	w.nonTTLCache.SetDefault(entry.Key, stored)
 }
 
-// Set wraps the cache.SetDefault method, storing the values into the TTL and the non-TTL cache.
-func (w *DefaultWeatherCache) Set(key string, value any) {
-	w.ttlCache.SetDefault(key, value)
-	w.nonTTLCache.SetDefault(key, value)
+// Entries returns all the entries (including those past their TTL), ordered by their key.
+func (w *DefaultWeatherCache) Entries() []Entry {
+	var entries []Entry
+	for key := range w.nonTTLCache.Items() {
+		if entry, found := w.Lookup(key); found {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+
+	return entries
+}
+
+// Lookup returns the entry of the key (including one past its TTL).
+func (w *DefaultWeatherCache) Lookup(key string) (Entry, bool) {
+	stored, found := w.nonTTLCache.Get(key)
+	if !found {
+		return Entry{}, false
+	}
+
+	entry := *stored.(*Entry)
+	fresh, found := w.ttlCache.Get(key)
+	entry.Fresh = found && fresh == stored
+	return entry, true
+}
+
+// Delete removes the entry of the key from both caches, returning true if it was found.
+func (w *DefaultWeatherCache) Delete(key string) bool {
+	_, found := w.nonTTLCache.Get(key)
+	w.ttlCache.Delete(key)
+	w.nonTTLCache.Delete(key)
+
+	return found
+}
+
+// DeletePrefix removes the entries whose key starts with the prefix (ignoring case), returning how many were removed.
+func (w *DefaultWeatherCache) DeletePrefix(prefix string) int {
+	deleted := 0
+	for key := range w.nonTTLCache.Items() {
+		if strings.HasPrefix(strings.ToLower(key), strings.ToLower(prefix)) && w.Delete(key) {
+			deleted++
+		}
+	}
+
+	return deleted
+}
+
+// Flush removes all the entries, returning how many were removed.
+func (w *DefaultWeatherCache) Flush() int {
+	deleted := w.nonTTLCache.ItemCount()
+	w.ttlCache.Flush()
+	w.nonTTLCache.Flush()
+
+	return deleted
+}
+
+// Returns the TTL, or the default TTL should it be zero.
+func (w *DefaultWeatherCache) orDefault(ttl time.Duration) time.Duration {
+	if ttl <= 0 {
+		return time.Duration(w.ttl.Load())
+	}
+
+	return ttl
+}
+
+// Returns the value of the entry that was found.
+func value(stored any, found bool) (any, bool) {
+	if !found {
+		return nil, false
+	}
+
+	return stored.(*Entry).Value, true
 }
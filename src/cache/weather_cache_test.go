@@ -25,7 +25,7 @@ func (s *WeatherCacheTestSuite) SetupTest() {
 
 func (s *WeatherCacheTestSuite) Test_HappyPathReadBeforeTTLExpires() {
 	// When
-	s.weatherCache.Set("one", "1")
+	s.weatherCache.Set("one", "1", "primary", 0)
 	value, ok := s.weatherCache.Get("one")
 	// Then
 	s.Assert().True(ok)
@@ -34,7 +34,7 @@ func (s *WeatherCacheTestSuite) Test_HappyPathReadBeforeTTLExpires() {
 
 func (s *WeatherCacheTestSuite) Test_HappyPathReadButTTLHasExpired() {
 	// When
-	s.weatherCache.Set("one", "1")
+	s.weatherCache.Set("one", "1", "primary", 0)
 	time.Sleep(300 * time.Millisecond)
 	value, ok := s.weatherCache.Get("one")
 	// Then
@@ -46,3 +46,84 @@ func (s *WeatherCacheTestSuite) Test_HappyPathReadButTTLHasExpired() {
 	s.Assert().True(ok)
 	s.Assert().Equal("1", value, "Value is still in the cache")
 }
+
+func (s *WeatherCacheTestSuite) Test_ReconfiguredTTL() {
+	// Given
+	weatherCache := cache.NewWeatherCache(time.Minute)
+	// When
+	weatherCache.SetTTL(100 * time.Millisecond)
+	weatherCache.Set("one", "1", "primary", 0)
+	time.Sleep(200 * time.Millisecond)
+	// Then
+	_, ok := weatherCache.Get("one")
+	s.Assert().False(ok, "The reconfigured TTL has expired")
+}
+
+func (s *WeatherCacheTestSuite) Test_TTLOfTheEntry() {
+	// When
+	s.weatherCache.Set("one", "1", "primary", time.Minute)
+	s.weatherCache.Set("two", "2", "primary", 0)
+	time.Sleep(300 * time.Millisecond)
+	// Then
+	_, ok := s.weatherCache.Get("one")
+	s.Assert().True(ok, "The TTL of the entry outlasts the default TTL")
+	_, ok = s.weatherCache.Get("two")
+	s.Assert().False(ok, "The default TTL has expired")
+	entry, _ := s.weatherCache.Lookup("two")
+	s.Assert().Equal(200*time.Millisecond, entry.TTL)
+}
+
+func (s *WeatherCacheTestSuite) Test_Entries() {
+	// Given
+	s.weatherCache.Set("Sydney", "2", "failover", 0)
+	s.weatherCache.Set("Melbourne", "1", "primary", 0)
+	// When
+	entries := s.weatherCache.Entries()
+	// Then
+	s.Require().Len(entries, 2)
+	s.Assert().Equal("Melbourne", entries[0].Key, "Ordered by the key")
+	s.Assert().Equal("1", entries[0].Value)
+	s.Assert().Equal("primary", entries[0].Source)
+	s.Assert().True(entries[0].Fresh)
+	s.Assert().WithinDuration(time.Now(), entries[0].StoredAt, time.Second)
+}
+
+func (s *WeatherCacheTestSuite) Test_LookupPastTheTTL() {
+	// Given
+	s.weatherCache.Set("one", "1", "primary", 0)
+	time.Sleep(300 * time.Millisecond)
+	// When
+	entry, found := s.weatherCache.Lookup("one")
+	// Then
+	s.Assert().True(found)
+	s.Assert().False(entry.Fresh, "The TTL has expired")
+	_, found = s.weatherCache.Lookup("two")
+	s.Assert().False(found)
+}
+
+func (s *WeatherCacheTestSuite) Test_Delete() {
+	// Given
+	s.weatherCache.Set("one", "1", "primary", 0)
+	// When
+	s.Assert().True(s.weatherCache.Delete("one"))
+	// Then
+	_, found := s.weatherCache.Get("one")
+	s.Assert().False(found)
+	_, found = s.weatherCache.GetIgnoreTTL("one")
+	s.Assert().False(found, "Removed from the non-TTL cache")
+	s.Assert().False(s.weatherCache.Delete("one"))
+}
+
+func (s *WeatherCacheTestSuite) Test_DeletePrefixAndFlush() {
+	// Given
+	for _, key := range []string{"Melbourne", "Melton", "Sydney"} {
+		s.weatherCache.Set(key, key, "primary", 0)
+	}
+	// When
+	deleted := s.weatherCache.DeletePrefix("mel")
+	// Then
+	s.Assert().Equal(2, deleted, "The prefix ignores case")
+	s.Assert().Len(s.weatherCache.Entries(), 1)
+	s.Assert().Equal(1, s.weatherCache.Flush())
+	s.Assert().Empty(s.weatherCache.Entries())
+}
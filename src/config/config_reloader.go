@@ -0,0 +1,197 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// The Source interface provides the current configuration, which may be reloaded while the service is running.
+type Source interface {
+	Current() *WeatherConfig
+}
+
+// Current returns the configuration itself (i.e. a configuration that is never reloaded).
+func (c *WeatherConfig) Current() *WeatherConfig {
+	return c
+}
+
+// The fields only read at startup, which are kept until the service is restarted.
+var restartFields = []string{
+	"Port", "HTTPDebug", "PrimaryEnabled", "FailoverEnabled", "PrimaryBillingDay", "FailoverBillingDay",
+	"PrimaryDailyBudget", "PrimaryMonthlyBudget", "FailoverDailyBudget", "FailoverMonthlyBudget",
+	"QuotaReserve", "QuotaFile", "QuotaFlushSeconds", "KeyRotation", "DriftIntervalSeconds", "DriftCities", "ReloadIntervalSeconds",
+	"AdminBreakerFile",
+	"SnapshotFile", "SnapshotIntervalSeconds", "SnapshotMaxAgeSeconds",
+}
+
+// The fields holding access keys (or the admin token), whose values are never logged.
+var secretFields = map[string]bool{
+	"PrimaryAccessKey": true, "PrimaryAccessKeys": true, "FailoverAccessKey": true, "FailoverAccessKeys": true,
+	"AdminToken": true,
+}
+
+type Reloader struct {
+	log  *logrus.Logger
+	path string
+
+	current   atomic.Pointer[WeatherConfig]
+	mu        sync.Mutex // Only the one reload at a time
+	listeners []func(cfg *WeatherConfig)
+}
+
+var _ Source = (*Reloader)(nil)
+
+// NewReloader returns a source of the configuration (loaded from the file path, which may be empty), that is
+// reloaded whenever it changes. A reloaded configuration is rejected should it fail to validate.
+func NewReloader(log *logrus.Logger, path string, cfg *WeatherConfig) *Reloader {
+	reloader := &Reloader{
+		log:  log,
+		path: path,
+	}
+	reloader.current.Store(cfg)
+
+	return reloader
+}
+
+// Current returns the configuration that was most recently (and successfully) loaded.
+func (r *Reloader) Current() *WeatherConfig {
+	return r.current.Load()
+}
+
+// OnReload registers a listener, called with the configuration each time it is reloaded.
+func (r *Reloader) OnReload(listener func(cfg *WeatherConfig)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.listeners = append(r.listeners, listener)
+}
+
+// Reload loads the configuration again, atomically replacing the current configuration should it be valid. The
+// changes to those fields only read at startup are ignored (until the service is restarted), so the configuration is
+// validated again once these are kept.
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cfg, err := LoadConfig(r.path)
+	if err != nil {
+		return err
+	}
+
+	current := r.Current()
+	for _, name := range restartFields {
+		previous, field := reflect.ValueOf(current).Elem().FieldByName(name), reflect.ValueOf(cfg).Elem().FieldByName(name)
+		if !reflect.DeepEqual(previous.Interface(), field.Interface()) {
+			r.log.WithField("field", name).Warn("The configuration change requires a restart, and was ignored")
+			field.Set(previous)
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("the configuration is invalid, once the changes requiring a restart are ignored: %w", err)
+	}
+
+	changes := Diff(current, cfg)
+	if len(changes) == 0 {
+		r.log.Info("The configuration is unchanged")
+		return nil
+	}
+	r.current.Store(cfg)
+	r.log.WithField("changes", changes).Info("Reloaded the configuration")
+	for _, listener := range r.listeners {
+		listener(cfg)
+	}
+
+	return nil
+}
+
+// Watch reloads the configuration on a SIGHUP, or when the file (or an access key or admin token file) is modified,
+// until the context is done. The files are checked at the interval given (zero only reloads on a SIGHUP).
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	modified := r.modified()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			r.reload("SIGHUP")
+		case <-tick:
+			if latest := r.modified(); !latest.Equal(modified) {
+				modified = latest
+				r.reload("modified file")
+			}
+		}
+	}
+}
+
+// Reload the configuration, logging (rather than applying) an invalid configuration.
+func (r *Reloader) reload(reason string) {
+	if err := r.Reload(); err != nil {
+		r.log.WithError(err).WithField("reason", reason).Error("Rejected the reloaded configuration")
+	}
+}
+
+// Returns the latest modification time of the configuration file, the access key files and the admin token file.
+func (r *Reloader) modified() time.Time {
+	cfg := r.Current()
+
+	var latest time.Time
+	for _, path := range []string{r.path, cfg.PrimaryAccessKeyFile, cfg.FailoverAccessKeyFile, cfg.AdminTokenFile} {
+		if path == "" {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+
+	return latest
+}
+
+// Diff returns a description of each field that differs between the configurations (with the access keys redacted).
+func Diff(previous, cfg *WeatherConfig) []string {
+	return diff("", reflect.ValueOf(previous).Elem(), reflect.ValueOf(cfg).Elem())
+}
+
+// Returns the differences of the fields, named by their (nested) yaml tags.
+func diff(prefix string, previous, current reflect.Value) []string {
+	var changes []string
+	for i := 0; i < current.NumField(); i++ {
+		field := current.Type().Field(i)
+		name := prefix + field.Tag.Get("yaml")
+		if field.Type.Kind() == reflect.Struct {
+			changes = append(changes, diff(name+".", previous.Field(i), current.Field(i))...)
+			continue
+		}
+
+		before, after := previous.Field(i).Interface(), current.Field(i).Interface()
+		switch {
+		case reflect.DeepEqual(before, after):
+		case secretFields[field.Name]:
+			changes = append(changes, fmt.Sprintf("%s: %s", name, redacted))
+		default:
+			changes = append(changes, fmt.Sprintf("%s: %v -> %v", name, before, after))
+		}
+	}
+
+	return changes
+}
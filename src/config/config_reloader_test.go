@@ -0,0 +1,102 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ColinSchofield/zai-weather/src/config"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/suite"
+)
+
+type ConfigReloaderTestSuite struct {
+	suite.Suite
+
+	path     string
+	reloader *config.Reloader
+	reloaded []*config.WeatherConfig
+}
+
+func TestConfigReloaderSuite(t *testing.T) {
+	suite.Run(t, new(ConfigReloaderTestSuite))
+}
+
+func (s *ConfigReloaderTestSuite) SetupTest() {
+	s.path = filepath.Join(s.T().TempDir(), "weather.yaml")
+	s.write("port: \":9090\"\ncache_ttl_seconds: 10\nprimary:\n  access_key: first\nfailover:\n  access_key: other\n")
+	cfg, err := config.LoadConfig(s.path)
+	s.Require().NoError(err)
+
+	s.reloaded = nil
+	s.reloader = config.NewReloader(logrus.New(), s.path, cfg)
+	s.reloader.OnReload(func(cfg *config.WeatherConfig) {
+		s.reloaded = append(s.reloaded, cfg)
+	})
+}
+
+func (s *ConfigReloaderTestSuite) write(content string) {
+	s.Require().NoError(os.WriteFile(s.path, []byte(content), 0o600))
+}
+
+func (s *ConfigReloaderTestSuite) Test_ReloadAppliesTheChanges() {
+	// Given
+	s.write("port: \":9191\"\ncache_ttl_seconds: 20\nprimary:\n  access_key: second\nfailover:\n  access_key: other\n")
+	// When
+	err := s.reloader.Reload()
+	// Then
+	s.Require().NoError(err)
+	s.Require().Len(s.reloaded, 1)
+	s.Assert().Same(s.reloaded[0], s.reloader.Current())
+	s.Assert().Equal(20, s.reloader.Current().CacheTTLSeconds)
+	s.Assert().Equal([]string{"second"}, s.reloader.Current().PrimaryKeys())
+	s.Assert().Equal(":9090", s.reloader.Current().Port, "The port is only read at startup")
+}
+
+func (s *ConfigReloaderTestSuite) Test_ReloadRejectsAnInvalidConfig() {
+	// Given
+	s.write("cache_ttl_seconds: -1\nprimary:\n  access_key: first\nfailover:\n  access_key: other\n")
+	// When
+	err := s.reloader.Reload()
+	// Then
+	s.Assert().Error(err)
+	s.Assert().Empty(s.reloaded)
+	s.Assert().Equal(10, s.reloader.Current().CacheTTLSeconds)
+	// Given
+	s.write("cache_ttl_seconds: 20\n")
+	// When
+	err = s.reloader.Reload()
+	// Then
+	s.Assert().ErrorIs(err, config.ErrMissingAccessKey)
+	s.Assert().Equal(10, s.reloader.Current().CacheTTLSeconds)
+}
+
+func (s *ConfigReloaderTestSuite) Test_ReloadValidatesTheConfigThatIsApplied() {
+	// Given
+	s.write("cache_ttl_seconds: 20\nprimary:\n  enabled: false\nfailover:\n  access_key: other\n")
+	// When
+	err := s.reloader.Reload()
+	// Then
+	s.Assert().ErrorIs(err, config.ErrMissingAccessKey, "The primary is still enabled (until a restart)")
+	s.Assert().Empty(s.reloaded)
+	s.Assert().Equal(10, s.reloader.Current().CacheTTLSeconds)
+}
+
+func (s *ConfigReloaderTestSuite) Test_ReloadIgnoresAnUnchangedConfig() {
+	// When
+	err := s.reloader.Reload()
+	// Then
+	s.Assert().NoError(err)
+	s.Assert().Empty(s.reloaded)
+}
+
+func (s *ConfigReloaderTestSuite) Test_DiffRedactsTheAccessKeys() {
+	// Given
+	previous := &config.WeatherConfig{CacheTTLSeconds: 1}
+	previous.PrimaryAccessKey = "first"
+	cfg := &config.WeatherConfig{CacheTTLSeconds: 2}
+	cfg.PrimaryAccessKey = "second"
+	// Then
+	s.Assert().Equal([]string{"cache_ttl_seconds: 1 -> 2", "primary.access_key: REDACTED"}, config.Diff(previous, cfg))
+}
@@ -0,0 +1,257 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"strconv"
+
+	"github.com/ColinSchofield/zai-weather/src/blend"
+	"github.com/ColinSchofield/zai-weather/src/keys"
+)
+
+// The fetch strategies (see the controller).
+const (
+	strategyFailover = "failover"
+	strategyBlend    = "blend"
+)
+
+// The weather services, as named by the provider order (see the controller).
+const (
+	providerPrimary  = "primary"
+	providerFailover = "failover"
+)
+
+// The policies that trip the circuit breakers (see the breaker).
+const (
+	policyRatio       = "ratio"
+	policyConsecutive = "consecutive"
+)
+
+// The latest billing day, so that every month has the day.
+const maxBillingDay = 28
+
+// The shortest admin token, so that it cannot be guessed.
+const minAdminTokenLength = 16
+
+// Validate checks the ranges, the formats and the constraints between the fields, returning all the problems found
+// (joined as the one error), or nil should the configuration be valid.
+func (c *WeatherConfig) Validate() error {
+	v := &validator{}
+
+	v.port(c.Port)
+	v.check(c.CacheTTLSeconds >= 1, "CacheTTLSeconds", "must be at least 1 (was %d)", c.CacheTTLSeconds)
+	v.check(c.NegativeCacheTTLSeconds >= 0, "NegativeCacheTTLSeconds", "must not be negative (was %d)",
+		c.NegativeCacheTTLSeconds)
+	v.check(c.ReloadIntervalSeconds >= 0, "ReloadIntervalSeconds", "must not be negative (was %d)",
+		c.ReloadIntervalSeconds)
+
+	// The weather services
+	v.check(c.PrimaryEnabled || c.FailoverEnabled, "PrimaryEnabled", "must be true, should the failover be disabled")
+	if c.PrimaryEnabled {
+		v.check(len(c.PrimaryKeys()) > 0, "PrimaryAccessKey", "%w (nor file) for the enabled primary service",
+			ErrMissingAccessKey)
+	}
+	if c.FailoverEnabled {
+		v.check(len(c.FailoverKeys()) > 0, "FailoverAccessKey", "%w (nor file) for the enabled failover service",
+			ErrMissingAccessKey)
+	}
+	v.check(c.PrimaryTimeoutSeconds >= 1, "PrimaryTimeoutSeconds", "must be at least 1 (was %d)",
+		c.PrimaryTimeoutSeconds)
+	v.check(c.FailoverTimeoutSeconds >= 1, "FailoverTimeoutSeconds", "must be at least 1 (was %d)",
+		c.FailoverTimeoutSeconds)
+	v.endPoint("PrimaryEndPoint", c.PrimaryEndPoint)
+	v.endPoint("FailoverEndPoint", c.FailoverEndPoint)
+	v.check(c.PrimaryBillingDay >= 1 && c.PrimaryBillingDay <= maxBillingDay, "PrimaryBillingDay",
+		"must be between 1 and %d (was %d)", maxBillingDay, c.PrimaryBillingDay)
+	v.check(c.FailoverBillingDay >= 1 && c.FailoverBillingDay <= maxBillingDay, "FailoverBillingDay",
+		"must be between 1 and %d (was %d)", maxBillingDay, c.FailoverBillingDay)
+
+	// The budgets
+	v.budget("PrimaryDailyBudget", c.PrimaryDailyBudget, c.PrimaryMonthlyBudget)
+	v.budget("FailoverDailyBudget", c.FailoverDailyBudget, c.FailoverMonthlyBudget)
+	v.check(c.PrimaryMonthlyBudget >= 0, "PrimaryMonthlyBudget", "must not be negative (was %d)",
+		c.PrimaryMonthlyBudget)
+	v.check(c.FailoverMonthlyBudget >= 0, "FailoverMonthlyBudget", "must not be negative (was %d)",
+		c.FailoverMonthlyBudget)
+	v.check(c.QuotaReserve >= 0 && c.QuotaReserve < 1, "QuotaReserve", "must be at least 0 and below 1 (was %g)",
+		c.QuotaReserve)
+	v.check(c.QuotaFlushSeconds >= 1, "QuotaFlushSeconds", "must be at least 1 (was %d)", c.QuotaFlushSeconds)
+
+	// The access keys
+	_, err := keys.ParseStrategy(c.KeyRotation)
+	v.check(err == nil, "KeyRotation", "must be %s or %s (was %q)", keys.RoundRobin, keys.Failover, c.KeyRotation)
+	v.check(c.KeyCooldownSeconds >= 0, "KeyCooldownSeconds", "must not be negative (was %d)", c.KeyCooldownSeconds)
+
+	// The circuit breakers
+	v.check(c.PrimaryRequests >= 1, "PrimaryRequests", "must be at least 1 (was %d)", c.PrimaryRequests)
+	v.check(c.FailoverRequests >= 1, "FailoverRequests", "must be at least 1 (was %d)", c.FailoverRequests)
+	v.ratio("PrimaryFailureRatio", c.PrimaryFailureRatio)
+	v.ratio("FailoverFailureRatio", c.FailoverFailureRatio)
+	v.breakerPolicy("PrimaryBreakerPolicy", c.PrimaryBreakerPolicy)
+	v.breakerPolicy("FailoverBreakerPolicy", c.FailoverBreakerPolicy)
+	v.check(c.PrimaryConsecutiveFailures >= 1, "PrimaryConsecutiveFailures", "must be at least 1 (was %d)",
+		c.PrimaryConsecutiveFailures)
+	v.check(c.FailoverConsecutiveFailures >= 1, "FailoverConsecutiveFailures", "must be at least 1 (was %d)",
+		c.FailoverConsecutiveFailures)
+	v.check(c.PrimaryHalfOpenRequests >= 1, "PrimaryHalfOpenRequests", "must be at least 1 (was %d)",
+		c.PrimaryHalfOpenRequests)
+	v.check(c.FailoverHalfOpenRequests >= 1, "FailoverHalfOpenRequests", "must be at least 1 (was %d)",
+		c.FailoverHalfOpenRequests)
+	v.check(c.PrimaryBreakerIntervalSeconds >= 0, "PrimaryBreakerIntervalSeconds", "must not be negative (was %d)",
+		c.PrimaryBreakerIntervalSeconds)
+	v.check(c.FailoverBreakerIntervalSeconds >= 0, "FailoverBreakerIntervalSeconds", "must not be negative (was %d)",
+		c.FailoverBreakerIntervalSeconds)
+	v.check(c.PrimaryBreakerOpenSeconds >= 1, "PrimaryBreakerOpenSeconds", "must be at least 1 (was %d)",
+		c.PrimaryBreakerOpenSeconds)
+	v.check(c.FailoverBreakerOpenSeconds >= 1, "FailoverBreakerOpenSeconds", "must be at least 1 (was %d)",
+		c.FailoverBreakerOpenSeconds)
+
+	// The fetch strategy, and the blending of the readings
+	v.check(c.FetchStrategy == strategyFailover || c.FetchStrategy == strategyBlend, "FetchStrategy",
+		"must be %s or %s (was %q)", strategyFailover, strategyBlend, c.FetchStrategy)
+	v.providerOrder(c.ProviderOrder)
+	_, err = blend.ParseMethod(c.BlendMethod)
+	v.check(err == nil, "BlendMethod", "must be %s, %s or %s (was %q)",
+		blend.Median, blend.WeightedMean, blend.PrimaryOutlier, c.BlendMethod)
+	v.check(c.BlendOutlierDegrees > 0, "BlendOutlierDegrees", "must be positive (was %g)", c.BlendOutlierDegrees)
+	v.check(c.PrimaryTrust >= 0, "PrimaryTrust", "must not be negative (was %g)", c.PrimaryTrust)
+	v.check(c.FailoverTrust >= 0, "FailoverTrust", "must not be negative (was %g)", c.FailoverTrust)
+	if c.FetchStrategy == strategyBlend && c.BlendMethod == string(blend.WeightedMean) {
+		v.check(c.PrimaryTrust+c.FailoverTrust > 0, "PrimaryTrust", "must be positive, should the failover trust be zero")
+	}
+
+	// The drift sampler
+	v.check(c.DriftIntervalSeconds >= 0, "DriftIntervalSeconds", "must not be negative (was %d)",
+		c.DriftIntervalSeconds)
+	if c.DriftIntervalSeconds > 0 {
+		v.check(len(c.DriftCities) > 0, "DriftCities", "must be given when the drift sampler is enabled")
+	}
+
+	// The hedging
+	v.check(c.HedgingPercentile > 0 && c.HedgingPercentile < 1, "HedgingPercentile",
+		"must be above 0 and below 1 (was %g)", c.HedgingPercentile)
+	v.check(c.HedgingDelayMilliseconds >= 0, "HedgingDelayMilliseconds", "must not be negative (was %d)",
+		c.HedgingDelayMilliseconds)
+	if c.HedgingEnabled {
+		v.check(c.HedgingDelayMilliseconds < c.PrimaryTimeoutSeconds*1000, "HedgingDelayMilliseconds",
+			"must be less than the primary timeout (was %d)", c.HedgingDelayMilliseconds)
+	}
+
+	// The cache snapshots
+	v.check(c.SnapshotIntervalSeconds >= 0, "SnapshotIntervalSeconds", "must not be negative (was %d)",
+		c.SnapshotIntervalSeconds)
+	v.check(c.SnapshotMaxAgeSeconds >= 1, "SnapshotMaxAgeSeconds", "must be at least 1 (was %d)",
+		c.SnapshotMaxAgeSeconds)
+
+	// The pre-warming of the cache
+	v.check(c.WarmTopN >= 0, "WarmTopN", "must not be negative (was %d)", c.WarmTopN)
+	v.check(c.WarmLeadSeconds >= 1, "WarmLeadSeconds", "must be at least 1 (was %d)", c.WarmLeadSeconds)
+	if len(c.WarmCities) > 0 || c.WarmTopN > 0 {
+		v.check(c.WarmLeadSeconds < c.CacheTTLSeconds, "WarmLeadSeconds",
+			"must be less than the cache TTL, when pre-warming (was %d)", c.WarmLeadSeconds)
+	}
+
+	// The TTL policies
+	_, err = c.LocationTTLs()
+	v.check(err == nil, "TTLLocations", "%v", err)
+	v.check(c.TTLMaxSeconds >= c.CacheTTLSeconds, "TTLMaxSeconds", "must be at least the cache TTL of %d (was %d)",
+		c.CacheTTLSeconds, c.TTLMaxSeconds)
+	v.check(c.TTLPrimarySeconds >= 0, "TTLPrimarySeconds", "must not be negative (was %d)", c.TTLPrimarySeconds)
+	v.check(c.TTLFailoverSeconds >= 0, "TTLFailoverSeconds", "must not be negative (was %d)", c.TTLFailoverSeconds)
+	v.check(c.TTLStaleMaxAgeSeconds >= 0, "TTLStaleMaxAgeSeconds", "must not be negative (was %d)",
+		c.TTLStaleMaxAgeSeconds)
+
+	// The streams
+	v.check(c.StreamRefreshSeconds >= 1, "StreamRefreshSeconds", "must be at least 1 (was %d)", c.StreamRefreshSeconds)
+	v.check(c.StreamHeartbeatSeconds >= 1, "StreamHeartbeatSeconds", "must be at least 1 (was %d)",
+		c.StreamHeartbeatSeconds)
+
+	// The admin API
+	v.check(c.AdminToken == "" || len(c.AdminToken) >= minAdminTokenLength, "AdminToken",
+		"must be at least %d characters (was %d)", minAdminTokenLength, len(c.AdminToken))
+
+	return errors.Join(v.errs...)
+}
+
+// The validator collects the problems of the configuration.
+type validator struct {
+	errs []error
+}
+
+// Records the problem with the field (given by its name within the WeatherConfig), should the check fail.
+func (v *validator) check(ok bool, field, format string, args ...any) {
+	if ok {
+		return
+	}
+
+	v.errs = append(v.errs, fmt.Errorf("%s: %w", fieldName(field), fmt.Errorf(format, args...)))
+}
+
+// Checks the port is given as [host]:port.
+func (v *validator) port(port string) {
+	_, number, err := net.SplitHostPort(port)
+	if err == nil {
+		_, err = strconv.ParseUint(number, 10, 16)
+	}
+	v.check(err == nil, "Port", "must be [host]:port, such as :8080 (was %q)", port)
+}
+
+// Checks the end point is an absolute http(s) URL.
+func (v *validator) endPoint(field, endPoint string) {
+	u, err := url.Parse(endPoint)
+	v.check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", field,
+		"must be an http or https URL (was %q)", endPoint)
+}
+
+// Checks the daily budget is not negative, nor more than the monthly budget.
+func (v *validator) budget(field string, daily, monthly int) {
+	v.check(daily >= 0, field, "must not be negative (was %d)", daily)
+	if daily > 0 && monthly > 0 {
+		v.check(daily <= monthly, field, "must not exceed the monthly budget of %d (was %d)", monthly, daily)
+	}
+}
+
+// Checks the circuit breaker trips on either the failure ratio, or the consecutive failures.
+func (v *validator) breakerPolicy(field, policy string) {
+	v.check(policy == policyRatio || policy == policyConsecutive, field, "must be %s or %s (was %q)",
+		policyRatio, policyConsecutive, policy)
+}
+
+// Checks the provider order gives each weather service the once.
+func (v *validator) providerOrder(order []string) {
+	primary, failover := 0, 0
+	for _, provider := range order {
+		switch provider {
+		case providerPrimary:
+			primary++
+		case providerFailover:
+			failover++
+		}
+	}
+	v.check(len(order) == 2 && primary == 1 && failover == 1, "ProviderOrder",
+		"must be %s and %s, in either order (was %v)", providerPrimary, providerFailover, order)
+}
+
+// Checks the failure ratio is above 0 and at most 1.
+func (v *validator) ratio(field string, ratio float64) {
+	v.check(ratio > 0 && ratio <= 1, field, "must be above 0 and at most 1 (was %g)", ratio)
+}
+
+// Returns the name of the field, as both its environment variable and its (nested) path within the file.
+func fieldName(name string) string {
+	field, found := reflect.TypeOf(WeatherConfig{}).FieldByName(name)
+	if !found {
+		return name
+	}
+
+	path := field.Tag.Get("yaml")
+	if len(field.Index) > 1 {
+		section := reflect.TypeOf(WeatherConfig{}).Field(field.Index[0])
+		path = section.Tag.Get("yaml") + "." + path
+	}
+
+	return fmt.Sprintf("%s (%s)", field.Tag.Get("env"), path)
+}
@@ -1,34 +1,375 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
 	"github.com/ilyakaznacheev/cleanenv"
+	"gopkg.in/yaml.v3"
 )
 
+// ErrMissingAccessKey is returned when a weather service is enabled, without any access key.
+var ErrMissingAccessKey = errors.New("no access key is configured")
+
+// The redacted form of an access key, when the configuration is printed.
+const redacted = "REDACTED"
+
+// DefaultLocation is the location of the weather information, should the caller not give a city.
+const DefaultLocation = "Melbourne"
+
+// The WeatherConfig is read from an (optional) YAML or TOML file, with each section below nested within the file
+// (e.g. primary.timeout_seconds), and overridden by the environment variables.
 type WeatherConfig struct {
 	// See the Dockerfile for the Port mappings
-	Port            string `env:"PORT" env-default:":8080"`
-	CacheTTLSeconds int    `env:"CACHE_TTL_SECONDS" env-default:"3"`
-	// The primary is the Weather Stack Service
-	PrimaryTimeoutSeconds int    `env:"PRIMARY_TIMEOUT_SECONDS" env-default:"3"`
-	PrimaryAccessKey      string `env:"PRIMARY_ACCESS_KEY" env-default:"1cadfad44c3387c66d14a12cb33f282e"`
-	PrimaryEndPoint       string `env:"PRIMARY_END_POINT" env-default:"http://api.weatherstack.com/current"`
-	// The failover is the Open Weather Map Service
-	FailoverTimeoutSeconds int    `env:"FAILOVER_TIMEOUT_SECONDS" env-default:"3"`
-	FailoverAccessKey      string `env:"FAILOVER_ACCESS_KEY" env-default:"fe0e197efcdefea9a19e9c4810f2801b"`
-	FailoverEndPoint       string `env:"FAILOVER_END_POINT" env-default:"http://api.openweathermap.org/data/2.5/weather"`
-	// Circuit Breaker used for both the primary and failover
-	PrimaryRequests      uint32  `env:"PRIMARY_REQUESTS" env-default:"3"`
-	PrimaryFailureRatio  float64 `env:"PRIMARY_FAILURE_RATIO" env-default:"0.6"`
-	FailoverRequests     uint32  `env:"FAILOVER_REQUESTS" env-default:"3"`
-	FailoverFailureRatio float64 `env:"FAILOVER_FAILURE_RATIO" env-default:"0.6"`
-}
-
-// LoadConfig reads the configuration from the system environment variables.
-func LoadConfig() (*WeatherConfig, error) {
+	Port            string `yaml:"port" toml:"port" env:"PORT" env-default:":8080"`
+	CacheTTLSeconds int    `yaml:"cache_ttl_seconds" toml:"cache_ttl_seconds" env:"CACHE_TTL_SECONDS" env-default:"3"`
+	// Locations that could not be found are remembered for this long (zero disables the negative cache)
+	NegativeCacheTTLSeconds int `yaml:"negative_cache_ttl_seconds" toml:"negative_cache_ttl_seconds" env:"NEGATIVE_CACHE_TTL_SECONDS" env-default:"60"`
+	// Log the requests to (and responses from) the weather services, with the access keys redacted
+	HTTPDebug bool `yaml:"http_debug" toml:"http_debug" env:"HTTP_DEBUG" env-default:"false"`
+	// The files are checked for changes at this interval, and reloaded (zero only reloads on a SIGHUP)
+	ReloadIntervalSeconds int `yaml:"reload_interval_seconds" toml:"reload_interval_seconds" env:"RELOAD_INTERVAL_SECONDS" env-default:"5"`
+
+	PrimaryConfig  `yaml:"primary" toml:"primary"`
+	FailoverConfig `yaml:"failover" toml:"failover"`
+	QuotaConfig    `yaml:"quota" toml:"quota"`
+	KeysConfig     `yaml:"keys" toml:"keys"`
+	BlendConfig    `yaml:"blend" toml:"blend"`
+	DriftConfig    `yaml:"drift" toml:"drift"`
+	HedgingConfig  `yaml:"hedging" toml:"hedging"`
+	AdminConfig    `yaml:"admin" toml:"admin"`
+	SnapshotConfig `yaml:"snapshot" toml:"snapshot"`
+	WarmConfig     `yaml:"warm" toml:"warm"`
+	TTLConfig      `yaml:"ttl" toml:"ttl"`
+	StreamConfig   `yaml:"stream" toml:"stream"`
+}
+
+// The PrimaryConfig is the Weather Stack Service. There are no default access keys: these are given either directly,
+// or as a file of keys (one per line) such as a Docker or Kubernetes secret.
+type PrimaryConfig struct {
+	PrimaryEnabled        bool     `yaml:"enabled" toml:"enabled" env:"PRIMARY_ENABLED" env-default:"true"`
+	PrimaryTimeoutSeconds int      `yaml:"timeout_seconds" toml:"timeout_seconds" env:"PRIMARY_TIMEOUT_SECONDS" env-default:"3"`
+	PrimaryAccessKey      string   `yaml:"access_key" toml:"access_key" env:"PRIMARY_ACCESS_KEY"`
+	PrimaryAccessKeys     []string `yaml:"access_keys" toml:"access_keys" env:"PRIMARY_ACCESS_KEYS"` // Rotated along with the key
+	PrimaryAccessKeyFile  string   `yaml:"access_key_file" toml:"access_key_file" env:"PRIMARY_ACCESS_KEY_FILE"`
+	PrimaryEndPoint       string   `yaml:"end_point" toml:"end_point" env:"PRIMARY_END_POINT" env-default:"http://api.weatherstack.com/current"`
+	// The day of the month the usage resets
+	PrimaryBillingDay int `yaml:"billing_day" toml:"billing_day" env:"PRIMARY_BILLING_DAY" env-default:"1"`
+	// The daily and monthly budgets of calls to the service (zero is unlimited)
+	PrimaryDailyBudget   int `yaml:"daily_budget" toml:"daily_budget" env:"PRIMARY_DAILY_BUDGET" env-default:"0"`
+	PrimaryMonthlyBudget int `yaml:"monthly_budget" toml:"monthly_budget" env:"PRIMARY_MONTHLY_BUDGET" env-default:"0"`
+	// Circuit Breaker, tripped by either the failure ratio (of at least the requests) or the consecutive failures.
+	// Once open for the open seconds, the half open requests are let through. The counts are cleared at the interval
+	// while closed (zero never clears them).
+	PrimaryBreakerPolicy          string  `yaml:"breaker_policy" toml:"breaker_policy" env:"PRIMARY_BREAKER_POLICY" env-default:"ratio"`
+	PrimaryRequests               uint32  `yaml:"requests" toml:"requests" env:"PRIMARY_REQUESTS" env-default:"3"`
+	PrimaryFailureRatio           float64 `yaml:"failure_ratio" toml:"failure_ratio" env:"PRIMARY_FAILURE_RATIO" env-default:"0.6"`
+	PrimaryConsecutiveFailures    uint32  `yaml:"consecutive_failures" toml:"consecutive_failures" env:"PRIMARY_CONSECUTIVE_FAILURES" env-default:"5"`
+	PrimaryHalfOpenRequests       uint32  `yaml:"half_open_requests" toml:"half_open_requests" env:"PRIMARY_HALF_OPEN_REQUESTS" env-default:"1"`
+	PrimaryBreakerIntervalSeconds int     `yaml:"breaker_interval_seconds" toml:"breaker_interval_seconds" env:"PRIMARY_BREAKER_INTERVAL_SECONDS" env-default:"0"`
+	PrimaryBreakerOpenSeconds     int     `yaml:"breaker_open_seconds" toml:"breaker_open_seconds" env:"PRIMARY_BREAKER_OPEN_SECONDS" env-default:"60"`
+	// The trust of its readings, when blended
+	PrimaryTrust float64 `yaml:"trust" toml:"trust" env:"PRIMARY_TRUST" env-default:"1"`
+}
+
+// The FailoverConfig is the Open Weather Map Service.
+type FailoverConfig struct {
+	FailoverEnabled        bool     `yaml:"enabled" toml:"enabled" env:"FAILOVER_ENABLED" env-default:"true"`
+	FailoverTimeoutSeconds int      `yaml:"timeout_seconds" toml:"timeout_seconds" env:"FAILOVER_TIMEOUT_SECONDS" env-default:"3"`
+	FailoverAccessKey      string   `yaml:"access_key" toml:"access_key" env:"FAILOVER_ACCESS_KEY"`
+	FailoverAccessKeys     []string `yaml:"access_keys" toml:"access_keys" env:"FAILOVER_ACCESS_KEYS"` // Rotated along with the key
+	FailoverAccessKeyFile  string   `yaml:"access_key_file" toml:"access_key_file" env:"FAILOVER_ACCESS_KEY_FILE"`
+	FailoverEndPoint       string   `yaml:"end_point" toml:"end_point" env:"FAILOVER_END_POINT" env-default:"http://api.openweathermap.org/data/2.5/weather"`
+	// The day of the month the usage resets
+	FailoverBillingDay int `yaml:"billing_day" toml:"billing_day" env:"FAILOVER_BILLING_DAY" env-default:"1"`
+	// The daily and monthly budgets of calls to the service (zero is unlimited)
+	FailoverDailyBudget   int `yaml:"daily_budget" toml:"daily_budget" env:"FAILOVER_DAILY_BUDGET" env-default:"0"`
+	FailoverMonthlyBudget int `yaml:"monthly_budget" toml:"monthly_budget" env:"FAILOVER_MONTHLY_BUDGET" env-default:"0"`
+	// Circuit Breaker (see the primary)
+	FailoverBreakerPolicy          string  `yaml:"breaker_policy" toml:"breaker_policy" env:"FAILOVER_BREAKER_POLICY" env-default:"ratio"`
+	FailoverRequests               uint32  `yaml:"requests" toml:"requests" env:"FAILOVER_REQUESTS" env-default:"3"`
+	FailoverFailureRatio           float64 `yaml:"failure_ratio" toml:"failure_ratio" env:"FAILOVER_FAILURE_RATIO" env-default:"0.6"`
+	FailoverConsecutiveFailures    uint32  `yaml:"consecutive_failures" toml:"consecutive_failures" env:"FAILOVER_CONSECUTIVE_FAILURES" env-default:"5"`
+	FailoverHalfOpenRequests       uint32  `yaml:"half_open_requests" toml:"half_open_requests" env:"FAILOVER_HALF_OPEN_REQUESTS" env-default:"1"`
+	FailoverBreakerIntervalSeconds int     `yaml:"breaker_interval_seconds" toml:"breaker_interval_seconds" env:"FAILOVER_BREAKER_INTERVAL_SECONDS" env-default:"0"`
+	FailoverBreakerOpenSeconds     int     `yaml:"breaker_open_seconds" toml:"breaker_open_seconds" env:"FAILOVER_BREAKER_OPEN_SECONDS" env-default:"60"`
+	// The trust of its readings, when blended
+	FailoverTrust float64 `yaml:"trust" toml:"trust" env:"FAILOVER_TRUST" env-default:"1"`
+}
+
+// The QuotaConfig persists the usage of each service to the file (at the interval, and on shutdown). A service is
+// skipped once its usage is within the reserve (a fraction) of a budget.
+type QuotaConfig struct {
+	QuotaReserve      float64 `yaml:"reserve" toml:"reserve" env:"QUOTA_RESERVE" env-default:"0.05"`
+	QuotaFile         string  `yaml:"file" toml:"file" env:"QUOTA_FILE" env-default:"quota.json"`
+	QuotaFlushSeconds int     `yaml:"flush_seconds" toml:"flush_seconds" env:"QUOTA_FLUSH_SECONDS" env-default:"10"`
+}
+
+// The KeysConfig rotates the access keys either round-robin, or failover (to the next key once rejected for the
+// cooldown).
+type KeysConfig struct {
+	KeyRotation        string `yaml:"rotation" toml:"rotation" env:"KEY_ROTATION" env-default:"failover"`
+	KeyCooldownSeconds int    `yaml:"cooldown_seconds" toml:"cooldown_seconds" env:"KEY_COOLDOWN_SECONDS" env-default:"3600"`
+}
+
+// The BlendConfig fetch strategy is either "failover" (each service in the provider order, the first before the
+// second) or "blend" (all services concurrently).
+type BlendConfig struct {
+	FetchStrategy       string   `yaml:"strategy" toml:"strategy" env:"FETCH_STRATEGY" env-default:"failover"`
+	ProviderOrder       []string `yaml:"provider_order" toml:"provider_order" env:"PROVIDER_ORDER" env-default:"primary,failover"`
+	BlendMethod         string   `yaml:"method" toml:"method" env:"BLEND_METHOD" env-default:"median"` // median, weighted or outlier
+	BlendOutlierDegrees float64  `yaml:"outlier_degrees" toml:"outlier_degrees" env:"BLEND_OUTLIER_DEGREES" env-default:"5"`
+}
+
+// The DriftConfig sampler periodically queries all the weather services for these cities (zero disables the sampler).
+type DriftConfig struct {
+	DriftIntervalSeconds int      `yaml:"interval_seconds" toml:"interval_seconds" env:"DRIFT_INTERVAL_SECONDS" env-default:"0"`
+	DriftCities          []string `yaml:"cities" toml:"cities" env:"DRIFT_CITIES" env-default:"Melbourne,Sydney,Brisbane,Perth,Adelaide,Hobart,Darwin,Canberra"`
+}
+
+// The HedgingConfig fires the failover in parallel, once the primary is slower than a percentile of its recent
+// latencies.
+type HedgingConfig struct {
+	HedgingEnabled           bool    `yaml:"enabled" toml:"enabled" env:"HEDGING_ENABLED" env-default:"false"`
+	HedgingPercentile        float64 `yaml:"percentile" toml:"percentile" env:"HEDGING_PERCENTILE" env-default:"0.95"`
+	HedgingDelayMilliseconds int     `yaml:"delay_milliseconds" toml:"delay_milliseconds" env:"HEDGING_DELAY_MILLISECONDS" env-default:"500"`
+}
+
+// The AdminConfig authenticates the admin API by the bearer token, given either directly or as a file (without a
+// token, the admin API is disabled). The modes forced upon the circuit breakers are persisted to the breaker file.
+type AdminConfig struct {
+	AdminToken       string `yaml:"token" toml:"token" env:"ADMIN_TOKEN"`
+	AdminTokenFile   string `yaml:"token_file" toml:"token_file" env:"ADMIN_TOKEN_FILE"`
+	AdminBreakerFile string `yaml:"breaker_file" toml:"breaker_file" env:"ADMIN_BREAKER_FILE" env-default:"breakers.json"`
+}
+
+// The SnapshotConfig saves the cache to the file at the interval (zero only on shutdown), and restores it at startup
+// discarding the entries older than the max age. An empty file disables the snapshots.
+type SnapshotConfig struct {
+	SnapshotFile            string `yaml:"file" toml:"file" env:"CACHE_SNAPSHOT_FILE" env-default:"cache-snapshot.json"`
+	SnapshotIntervalSeconds int    `yaml:"interval_seconds" toml:"interval_seconds" env:"CACHE_SNAPSHOT_INTERVAL_SECONDS" env-default:"60"`
+	SnapshotMaxAgeSeconds   int    `yaml:"max_age_seconds" toml:"max_age_seconds" env:"CACHE_SNAPSHOT_MAX_AGE_SECONDS" env-default:"86400"`
+}
+
+// The WarmConfig pre-warms the cache with the cities, along with the top N most requested cities (zero for none). Each
+// city is refreshed this many lead seconds before its TTL expires.
+type WarmConfig struct {
+	WarmCities      []string `yaml:"cities" toml:"cities" env:"WARM_CITIES"`
+	WarmTopN        int      `yaml:"top_n" toml:"top_n" env:"WARM_TOP_N" env-default:"0"`
+	WarmLeadSeconds int      `yaml:"lead_seconds" toml:"lead_seconds" env:"WARM_LEAD_SECONDS" env-default:"1"`
+}
+
+// The TTLConfig varies the TTL of the cached weather information. A location matching one of the rules (a glob pattern
+// ignoring case, such as "Darwin=600" or "Mel*=300") is cached for its seconds. Otherwise the hints of the weather
+// service are honored (its Cache-Control max age, or its observation time plus its TTL as the interval it updates at),
+// bounded by the global TTL and the max seconds. Without any hints, the TTL of the weather service is used (zero for
+// the global TTL). Should the weather services fail, values are served stale for up to the max age (zero for no limit).
+type TTLConfig struct {
+	TTLLocations          []string `yaml:"locations" toml:"locations" env:"CACHE_TTL_LOCATIONS"`
+	TTLHonorUpstream      bool     `yaml:"honor_upstream" toml:"honor_upstream" env:"CACHE_TTL_HONOR_UPSTREAM" env-default:"true"`
+	TTLMaxSeconds         int      `yaml:"max_seconds" toml:"max_seconds" env:"CACHE_TTL_MAX_SECONDS" env-default:"900"`
+	TTLPrimarySeconds     int      `yaml:"primary_seconds" toml:"primary_seconds" env:"CACHE_TTL_PRIMARY_SECONDS" env-default:"0"`
+	TTLFailoverSeconds    int      `yaml:"failover_seconds" toml:"failover_seconds" env:"CACHE_TTL_FAILOVER_SECONDS" env-default:"0"`
+	TTLStaleMaxAgeSeconds int      `yaml:"stale_max_age_seconds" toml:"stale_max_age_seconds" env:"CACHE_STALE_MAX_AGE_SECONDS" env-default:"0"`
+}
+
+// The StreamConfig is of the Server-Sent Events of the weather information. Each location that is subscribed to is
+// refreshed (through the cache) at the interval, shared by all its subscribers, whereas each subscriber is sent a
+// heartbeat at its own interval (so that idle connections are not dropped by proxies).
+type StreamConfig struct {
+	StreamRefreshSeconds   int `yaml:"refresh_seconds" toml:"refresh_seconds" env:"STREAM_REFRESH_SECONDS" env-default:"5"`
+	StreamHeartbeatSeconds int `yaml:"heartbeat_seconds" toml:"heartbeat_seconds" env:"STREAM_HEARTBEAT_SECONDS" env-default:"15"`
+}
+
+// The LocationTTL caches the locations matching the (lower case) glob pattern for the TTL.
+type LocationTTL struct {
+	Pattern string
+	TTL     time.Duration
+}
+
+// LoadConfig reads the configuration from the file (should a path be given), overridden by the system environment
+// variables, along with any access key files. Loading fails (with all the problems found) should the configuration not
+// be valid.
+func LoadConfig(path string) (*WeatherConfig, error) {
 	var cfg WeatherConfig
 	if err := cleanenv.ReadEnv(&cfg); err != nil {
 		return nil, err
 	}
 
+	if path != "" {
+		env := cfg
+		if err := readFile(path, &cfg); err != nil {
+			return nil, err
+		}
+		// The file only overrides the defaults, not the environment variables
+		overrideFromEnv(reflect.ValueOf(&cfg).Elem(), reflect.ValueOf(env))
+	}
+
+	primaryKeys, err := readKeyFile(cfg.PrimaryAccessKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	cfg.PrimaryAccessKeys = append(cfg.PrimaryAccessKeys, primaryKeys...)
+	failoverKeys, err := readKeyFile(cfg.FailoverAccessKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	cfg.FailoverAccessKeys = append(cfg.FailoverAccessKeys, failoverKeys...)
+	adminTokens, err := readKeyFile(cfg.AdminTokenFile)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.AdminToken == "" && len(adminTokens) > 0 {
+		cfg.AdminToken = adminTokens[0]
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
 	return &cfg, nil
 }
+
+// PrimaryKeys returns the access keys of the primary (Weather Stack) service.
+func (c *WeatherConfig) PrimaryKeys() []string {
+	return nonEmpty(append([]string{c.PrimaryAccessKey}, c.PrimaryAccessKeys...))
+}
+
+// FailoverKeys returns the access keys of the failover (Open Weather Map) service.
+func (c *WeatherConfig) FailoverKeys() []string {
+	return nonEmpty(append([]string{c.FailoverAccessKey}, c.FailoverAccessKeys...))
+}
+
+// LocationTTLs returns the rules of the TTL by location, in the order given (so that the first match wins).
+func (c *WeatherConfig) LocationTTLs() ([]LocationTTL, error) {
+	var rules []LocationTTL
+	for _, rule := range nonEmpty(c.TTLLocations) {
+		pattern, value, found := strings.Cut(rule, "=")
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		seconds, err := strconv.Atoi(strings.TrimSpace(value))
+		if !found || pattern == "" || err != nil || seconds < 1 {
+			return nil, fmt.Errorf("rule %q is not pattern=seconds (of at least 1)", rule)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("rule %q: %w", rule, err)
+		}
+		rules = append(rules, LocationTTL{Pattern: pattern, TTL: time.Duration(seconds) * time.Second})
+	}
+
+	return rules, nil
+}
+
+// Secrets returns all the access keys (and the admin token), that must never be written to the logs.
+func (c *WeatherConfig) Secrets() []string {
+	return nonEmpty(append(append(c.PrimaryKeys(), c.FailoverKeys()...), c.AdminToken))
+}
+
+// WriteRedacted writes the (effective) configuration as YAML, with the access keys (and the admin token) redacted.
+func (c *WeatherConfig) WriteRedacted(w io.Writer) error {
+	cfg := *c
+	cfg.PrimaryAccessKey = redact(cfg.PrimaryAccessKey)
+	cfg.PrimaryAccessKeys = redactAll(cfg.PrimaryAccessKeys)
+	cfg.FailoverAccessKey = redact(cfg.FailoverAccessKey)
+	cfg.FailoverAccessKeys = redactAll(cfg.FailoverAccessKeys)
+	cfg.AdminToken = redact(cfg.AdminToken)
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(cfg); err != nil {
+		return err
+	}
+
+	return encoder.Close()
+}
+
+// Read the configuration file, either YAML or TOML (based upon its extension).
+func readFile(path string, cfg *WeatherConfig) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read the configuration file: %w", err)
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	case ".toml":
+		err = toml.Unmarshal(data, cfg)
+	default:
+		return fmt.Errorf("unsupported configuration file type %q", ext)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse the configuration file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// Copy the fields (i.e. those nested within each section) that were given as an environment variable.
+func overrideFromEnv(dst, src reflect.Value) {
+	for i := 0; i < dst.NumField(); i++ {
+		field := dst.Type().Field(i)
+		if field.Type.Kind() == reflect.Struct {
+			overrideFromEnv(dst.Field(i), src.Field(i))
+			continue
+		}
+		if name, found := field.Tag.Lookup("env"); found {
+			if _, set := os.LookupEnv(name); set {
+				dst.Field(i).Set(src.Field(i))
+			}
+		}
+	}
+}
+
+// Read the access keys (one per line) from the file, should one be given.
+func readKeyFile(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the access key file: %w", err)
+	}
+
+	return nonEmpty(strings.Split(string(data), "\n")), nil
+}
+
+// Returns the (trimmed) keys that are not blank.
+func nonEmpty(keys []string) []string {
+	var result []string
+	for _, key := range keys {
+		if key = strings.TrimSpace(key); key != "" {
+			result = append(result, key)
+		}
+	}
+
+	return result
+}
+
+// Returns the redacted form of the key, should it be given.
+func redact(key string) string {
+	if key == "" {
+		return ""
+	}
+
+	return redacted
+}
+
+// Returns the redacted form of each of the keys.
+func redactAll(keys []string) []string {
+	result := make([]string, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, redact(key))
+	}
+
+	return result
+}
@@ -1,7 +1,13 @@
 package config_test
 
 import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/ColinSchofield/zai-weather/src/config"
 
@@ -9,48 +15,534 @@ import (
 )
 
 func Test_ConfigUsingDefaultValues(t *testing.T) {
-	cfg, err := config.LoadConfig()
+	// There are no default access keys
+	t.Setenv("PRIMARY_ACCESS_KEY", "a")
+	t.Setenv("FAILOVER_ACCESS_KEY", "b")
+
+	cfg, err := config.LoadConfig("")
 	assert.NoError(t, err)
 	assert.Equal(t, ":8080", cfg.Port)
 	assert.Equal(t, 3, cfg.CacheTTLSeconds)
+	assert.Equal(t, 60, cfg.NegativeCacheTTLSeconds)
+	assert.True(t, cfg.PrimaryEnabled)
 	assert.Equal(t, 3, cfg.PrimaryTimeoutSeconds)
-	assert.Equal(t, "1cadfad44c3387c66d14a12cb33f282e", cfg.PrimaryAccessKey)
+	assert.Empty(t, cfg.PrimaryAccessKeys)
+	assert.Empty(t, cfg.PrimaryAccessKeyFile)
 	assert.Equal(t, "http://api.weatherstack.com/current", cfg.PrimaryEndPoint)
+	assert.Equal(t, 1, cfg.PrimaryBillingDay)
+	assert.Equal(t, 1, cfg.FailoverBillingDay)
+	assert.True(t, cfg.FailoverEnabled)
 	assert.Equal(t, 3, cfg.FailoverTimeoutSeconds)
-	assert.Equal(t, "fe0e197efcdefea9a19e9c4810f2801b", cfg.FailoverAccessKey)
+	assert.Empty(t, cfg.FailoverAccessKeys)
+	assert.Empty(t, cfg.FailoverAccessKeyFile)
+	assert.False(t, cfg.HTTPDebug)
+	assert.Equal(t, 5, cfg.ReloadIntervalSeconds)
 	assert.Equal(t, "http://api.openweathermap.org/data/2.5/weather", cfg.FailoverEndPoint)
+	assert.Equal(t, 0, cfg.PrimaryDailyBudget)
+	assert.Equal(t, 0, cfg.PrimaryMonthlyBudget)
+	assert.Equal(t, 0, cfg.FailoverDailyBudget)
+	assert.Equal(t, 0, cfg.FailoverMonthlyBudget)
+	assert.Equal(t, 0.05, cfg.QuotaReserve)
+	assert.Equal(t, "quota.json", cfg.QuotaFile)
+	assert.Equal(t, 10, cfg.QuotaFlushSeconds)
+	assert.Equal(t, "failover", cfg.KeyRotation)
+	assert.Equal(t, 3600, cfg.KeyCooldownSeconds)
 	assert.Equal(t, uint32(3), cfg.PrimaryRequests)
 	assert.Equal(t, 0.6, cfg.PrimaryFailureRatio)
 	assert.Equal(t, uint32(3), cfg.FailoverRequests)
 	assert.Equal(t, 0.6, cfg.FailoverFailureRatio)
+	assert.Equal(t, "ratio", cfg.PrimaryBreakerPolicy)
+	assert.Equal(t, uint32(5), cfg.PrimaryConsecutiveFailures)
+	assert.Equal(t, uint32(1), cfg.PrimaryHalfOpenRequests)
+	assert.Equal(t, 0, cfg.PrimaryBreakerIntervalSeconds)
+	assert.Equal(t, 60, cfg.PrimaryBreakerOpenSeconds)
+	assert.Equal(t, "ratio", cfg.FailoverBreakerPolicy)
+	assert.Equal(t, uint32(5), cfg.FailoverConsecutiveFailures)
+	assert.Equal(t, uint32(1), cfg.FailoverHalfOpenRequests)
+	assert.Equal(t, 0, cfg.FailoverBreakerIntervalSeconds)
+	assert.Equal(t, 60, cfg.FailoverBreakerOpenSeconds)
+	assert.Equal(t, "failover", cfg.FetchStrategy)
+	assert.Equal(t, []string{"primary", "failover"}, cfg.ProviderOrder)
+	assert.Equal(t, "median", cfg.BlendMethod)
+	assert.Equal(t, float64(5), cfg.BlendOutlierDegrees)
+	assert.Equal(t, float64(1), cfg.PrimaryTrust)
+	assert.Equal(t, float64(1), cfg.FailoverTrust)
+	assert.Equal(t, 0, cfg.DriftIntervalSeconds)
+	assert.Equal(t, []string{"Melbourne", "Sydney", "Brisbane", "Perth", "Adelaide", "Hobart", "Darwin", "Canberra"}, cfg.DriftCities)
+	assert.False(t, cfg.HedgingEnabled)
+	assert.Equal(t, 0.95, cfg.HedgingPercentile)
+	assert.Equal(t, 500, cfg.HedgingDelayMilliseconds)
+	assert.Equal(t, "", cfg.AdminToken)
+	assert.Equal(t, "breakers.json", cfg.AdminBreakerFile)
+	assert.Equal(t, "cache-snapshot.json", cfg.SnapshotFile)
+	assert.Equal(t, 60, cfg.SnapshotIntervalSeconds)
+	assert.Equal(t, 86400, cfg.SnapshotMaxAgeSeconds)
+	assert.Empty(t, cfg.WarmCities)
+	assert.Equal(t, 0, cfg.WarmTopN)
+	assert.Equal(t, 1, cfg.WarmLeadSeconds)
+	assert.Empty(t, cfg.TTLLocations)
+	assert.True(t, cfg.TTLHonorUpstream)
+	assert.Equal(t, 900, cfg.TTLMaxSeconds)
+	assert.Equal(t, 0, cfg.TTLPrimarySeconds)
+	assert.Equal(t, 0, cfg.TTLFailoverSeconds)
+	assert.Equal(t, 0, cfg.TTLStaleMaxAgeSeconds)
+	assert.Equal(t, 5, cfg.StreamRefreshSeconds)
+	assert.Equal(t, 15, cfg.StreamHeartbeatSeconds)
 }
 
 func Test_ConfigFromEnviroment(t *testing.T) {
-	t.Setenv("PORT", "1")
+	t.Setenv("PORT", ":1")
 	t.Setenv("CACHE_TTL_SECONDS", "2")
 	t.Setenv("PRIMARY_TIMEOUT_SECONDS", "3")
 	t.Setenv("PRIMARY_ACCESS_KEY", "4")
-	t.Setenv("PRIMARY_END_POINT", "5")
+	t.Setenv("PRIMARY_END_POINT", "http://5")
 	t.Setenv("FAILOVER_TIMEOUT_SECONDS", "6")
 	t.Setenv("FAILOVER_ACCESS_KEY", "7")
-	t.Setenv("FAILOVER_END_POINT", "8")
+	t.Setenv("FAILOVER_END_POINT", "https://8")
 	t.Setenv("PRIMARY_REQUESTS", "9")
-	t.Setenv("PRIMARY_FAILURE_RATIO", "10")
+	t.Setenv("PRIMARY_FAILURE_RATIO", "0.10")
 	t.Setenv("FAILOVER_REQUESTS", "11")
-	t.Setenv("FAILOVER_FAILURE_RATIO", "12")
+	t.Setenv("FAILOVER_FAILURE_RATIO", "0.12")
+	t.Setenv("HEDGING_ENABLED", "true")
+	t.Setenv("HEDGING_PERCENTILE", "0.13")
+	t.Setenv("HEDGING_DELAY_MILLISECONDS", "14")
+	t.Setenv("NEGATIVE_CACHE_TTL_SECONDS", "15")
+	t.Setenv("PRIMARY_BILLING_DAY", "16")
+	t.Setenv("FETCH_STRATEGY", "blend")
+	t.Setenv("BLEND_METHOD", "weighted")
+	t.Setenv("BLEND_OUTLIER_DEGREES", "19")
+	t.Setenv("PRIMARY_TRUST", "20")
+	t.Setenv("FAILOVER_TRUST", "21")
+	t.Setenv("DRIFT_INTERVAL_SECONDS", "22")
+	t.Setenv("DRIFT_CITIES", "23,24")
+	t.Setenv("PRIMARY_DAILY_BUDGET", "25")
+	t.Setenv("PRIMARY_MONTHLY_BUDGET", "26")
+	t.Setenv("FAILOVER_DAILY_BUDGET", "27")
+	t.Setenv("FAILOVER_MONTHLY_BUDGET", "28")
+	t.Setenv("QUOTA_RESERVE", "0.29")
+	t.Setenv("QUOTA_FILE", "30")
+	t.Setenv("PRIMARY_ACCESS_KEYS", "31,32")
+	t.Setenv("FAILOVER_ACCESS_KEYS", "33")
+	t.Setenv("KEY_ROTATION", "round-robin")
+	t.Setenv("KEY_COOLDOWN_SECONDS", "35")
+	t.Setenv("PRIMARY_ENABLED", "false")
+	t.Setenv("HTTP_DEBUG", "true")
+	t.Setenv("RELOAD_INTERVAL_SECONDS", "36")
+	t.Setenv("PRIMARY_BREAKER_POLICY", "consecutive")
+	t.Setenv("PRIMARY_CONSECUTIVE_FAILURES", "37")
+	t.Setenv("PRIMARY_HALF_OPEN_REQUESTS", "38")
+	t.Setenv("PRIMARY_BREAKER_INTERVAL_SECONDS", "39")
+	t.Setenv("PRIMARY_BREAKER_OPEN_SECONDS", "40")
+	t.Setenv("FAILOVER_BREAKER_POLICY", "consecutive")
+	t.Setenv("FAILOVER_CONSECUTIVE_FAILURES", "41")
+	t.Setenv("FAILOVER_HALF_OPEN_REQUESTS", "42")
+	t.Setenv("FAILOVER_BREAKER_INTERVAL_SECONDS", "43")
+	t.Setenv("FAILOVER_BREAKER_OPEN_SECONDS", "44")
+	t.Setenv("ADMIN_TOKEN", "45-admin-token-45")
+	t.Setenv("ADMIN_BREAKER_FILE", "46")
+	t.Setenv("CACHE_SNAPSHOT_FILE", "47")
+	t.Setenv("CACHE_SNAPSHOT_INTERVAL_SECONDS", "48")
+	t.Setenv("CACHE_SNAPSHOT_MAX_AGE_SECONDS", "49")
+	t.Setenv("WARM_CITIES", "50,51")
+	t.Setenv("WARM_TOP_N", "52")
+	t.Setenv("CACHE_TTL_LOCATIONS", "53=54")
+	t.Setenv("CACHE_TTL_HONOR_UPSTREAM", "false")
+	t.Setenv("CACHE_TTL_MAX_SECONDS", "55")
+	t.Setenv("CACHE_TTL_PRIMARY_SECONDS", "56")
+	t.Setenv("CACHE_TTL_FAILOVER_SECONDS", "57")
+	t.Setenv("CACHE_STALE_MAX_AGE_SECONDS", "58")
+	t.Setenv("STREAM_REFRESH_SECONDS", "59")
+	t.Setenv("STREAM_HEARTBEAT_SECONDS", "60")
+	t.Setenv("QUOTA_FLUSH_SECONDS", "61")
+	t.Setenv("PROVIDER_ORDER", "failover,primary")
+	t.Setenv("FAILOVER_BILLING_DAY", "27")
 
-	cfg, err := config.LoadConfig()
+	cfg, err := config.LoadConfig("")
 	assert.NoError(t, err)
-	assert.Equal(t, "1", cfg.Port)
+	assert.Equal(t, ":1", cfg.Port)
 	assert.Equal(t, 2, cfg.CacheTTLSeconds)
 	assert.Equal(t, 3, cfg.PrimaryTimeoutSeconds)
 	assert.Equal(t, "4", cfg.PrimaryAccessKey)
-	assert.Equal(t, "5", cfg.PrimaryEndPoint)
+	assert.Equal(t, "http://5", cfg.PrimaryEndPoint)
 	assert.Equal(t, 6, cfg.FailoverTimeoutSeconds)
 	assert.Equal(t, "7", cfg.FailoverAccessKey)
-	assert.Equal(t, "8", cfg.FailoverEndPoint)
+	assert.Equal(t, "https://8", cfg.FailoverEndPoint)
 	assert.Equal(t, uint32(9), cfg.PrimaryRequests)
-	assert.Equal(t, float64(10), cfg.PrimaryFailureRatio)
+	assert.Equal(t, 0.10, cfg.PrimaryFailureRatio)
 	assert.Equal(t, uint32(11), cfg.FailoverRequests)
-	assert.Equal(t, float64(12), cfg.FailoverFailureRatio)
+	assert.Equal(t, 0.12, cfg.FailoverFailureRatio)
+	assert.True(t, cfg.HedgingEnabled)
+	assert.Equal(t, 0.13, cfg.HedgingPercentile)
+	assert.Equal(t, 14, cfg.HedgingDelayMilliseconds)
+	assert.Equal(t, 15, cfg.NegativeCacheTTLSeconds)
+	assert.Equal(t, 16, cfg.PrimaryBillingDay)
+	assert.Equal(t, "blend", cfg.FetchStrategy)
+	assert.Equal(t, "weighted", cfg.BlendMethod)
+	assert.Equal(t, float64(19), cfg.BlendOutlierDegrees)
+	assert.Equal(t, float64(20), cfg.PrimaryTrust)
+	assert.Equal(t, float64(21), cfg.FailoverTrust)
+	assert.Equal(t, 22, cfg.DriftIntervalSeconds)
+	assert.Equal(t, []string{"23", "24"}, cfg.DriftCities)
+	assert.Equal(t, 25, cfg.PrimaryDailyBudget)
+	assert.Equal(t, 26, cfg.PrimaryMonthlyBudget)
+	assert.Equal(t, 27, cfg.FailoverDailyBudget)
+	assert.Equal(t, 28, cfg.FailoverMonthlyBudget)
+	assert.Equal(t, 0.29, cfg.QuotaReserve)
+	assert.Equal(t, "30", cfg.QuotaFile)
+	assert.Equal(t, []string{"4", "31", "32"}, cfg.PrimaryKeys())
+	assert.Equal(t, []string{"7", "33"}, cfg.FailoverKeys())
+	assert.Equal(t, "round-robin", cfg.KeyRotation)
+	assert.Equal(t, 35, cfg.KeyCooldownSeconds)
+	assert.False(t, cfg.PrimaryEnabled)
+	assert.True(t, cfg.HTTPDebug)
+	assert.Equal(t, 36, cfg.ReloadIntervalSeconds)
+	assert.Equal(t, "consecutive", cfg.PrimaryBreakerPolicy)
+	assert.Equal(t, uint32(37), cfg.PrimaryConsecutiveFailures)
+	assert.Equal(t, uint32(38), cfg.PrimaryHalfOpenRequests)
+	assert.Equal(t, 39, cfg.PrimaryBreakerIntervalSeconds)
+	assert.Equal(t, 40, cfg.PrimaryBreakerOpenSeconds)
+	assert.Equal(t, "consecutive", cfg.FailoverBreakerPolicy)
+	assert.Equal(t, uint32(41), cfg.FailoverConsecutiveFailures)
+	assert.Equal(t, uint32(42), cfg.FailoverHalfOpenRequests)
+	assert.Equal(t, 43, cfg.FailoverBreakerIntervalSeconds)
+	assert.Equal(t, 44, cfg.FailoverBreakerOpenSeconds)
+	assert.Equal(t, "45-admin-token-45", cfg.AdminToken)
+	assert.Equal(t, "46", cfg.AdminBreakerFile)
+	assert.Equal(t, "47", cfg.SnapshotFile)
+	assert.Equal(t, 48, cfg.SnapshotIntervalSeconds)
+	assert.Equal(t, 49, cfg.SnapshotMaxAgeSeconds)
+	assert.Equal(t, []string{"50", "51"}, cfg.WarmCities)
+	assert.Equal(t, 52, cfg.WarmTopN)
+	assert.Equal(t, []string{"53=54"}, cfg.TTLLocations)
+	assert.False(t, cfg.TTLHonorUpstream)
+	assert.Equal(t, 55, cfg.TTLMaxSeconds)
+	assert.Equal(t, 56, cfg.TTLPrimarySeconds)
+	assert.Equal(t, 57, cfg.TTLFailoverSeconds)
+	assert.Equal(t, 58, cfg.TTLStaleMaxAgeSeconds)
+	assert.Equal(t, 59, cfg.StreamRefreshSeconds)
+	assert.Equal(t, 60, cfg.StreamHeartbeatSeconds)
+	assert.Equal(t, 61, cfg.QuotaFlushSeconds)
+	assert.Equal(t, []string{"failover", "primary"}, cfg.ProviderOrder)
+	assert.Equal(t, 27, cfg.FailoverBillingDay)
+	assert.Equal(t, []string{"4", "31", "32", "7", "33", "45-admin-token-45"}, cfg.Secrets())
+}
+
+func Test_ConfigFromKeyFiles(t *testing.T) {
+	dir := t.TempDir()
+	primaryFile := filepath.Join(dir, "primary")
+	failoverFile := filepath.Join(dir, "failover")
+	assert.NoError(t, os.WriteFile(primaryFile, []byte("one\n two \n\n"), 0o600))
+	assert.NoError(t, os.WriteFile(failoverFile, []byte("three\n"), 0o600))
+	t.Setenv("PRIMARY_ACCESS_KEY_FILE", primaryFile)
+	t.Setenv("FAILOVER_ACCESS_KEY_FILE", failoverFile)
+
+	cfg, err := config.LoadConfig("")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"one", "two"}, cfg.PrimaryKeys())
+	assert.Equal(t, []string{"three"}, cfg.FailoverKeys())
+}
+
+func Test_ConfigFromAdminTokenFile(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "admin")
+	assert.NoError(t, os.WriteFile(tokenFile, []byte(" 0123456789abcdef \n"), 0o600))
+	t.Setenv("PRIMARY_ACCESS_KEY", "a")
+	t.Setenv("FAILOVER_ACCESS_KEY", "b")
+	t.Setenv("ADMIN_TOKEN_FILE", tokenFile)
+
+	cfg, err := config.LoadConfig("")
+	assert.NoError(t, err)
+	assert.Equal(t, "0123456789abcdef", cfg.AdminToken)
+}
+
+func Test_ConfigWithMissingKeyFile(t *testing.T) {
+	t.Setenv("PRIMARY_ACCESS_KEY_FILE", filepath.Join(t.TempDir(), "missing"))
+	t.Setenv("FAILOVER_ACCESS_KEY", "b")
+
+	_, err := config.LoadConfig("")
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
+
+func Test_ConfigWithoutAccessKeys(t *testing.T) {
+	t.Setenv("FAILOVER_ACCESS_KEY", "b")
+
+	_, err := config.LoadConfig("")
+	assert.True(t, errors.Is(err, config.ErrMissingAccessKey))
+	assert.Contains(t, err.Error(), "primary")
+}
+
+func Test_ConfigWithDisabledServiceWithoutAccessKeys(t *testing.T) {
+	t.Setenv("PRIMARY_ENABLED", "false")
+	t.Setenv("FAILOVER_ACCESS_KEY", "b")
+
+	cfg, err := config.LoadConfig("")
+	assert.NoError(t, err)
+	assert.Empty(t, cfg.PrimaryKeys())
+}
+
+func Test_ConfigFromFile(t *testing.T) {
+	for _, path := range []string{"testdata/weather.yaml", "testdata/weather.toml"} {
+		t.Run(path, func(t *testing.T) {
+			cfg, err := config.LoadConfig(path)
+			assert.NoError(t, err)
+			assert.Equal(t, ":9090", cfg.Port)
+			assert.Equal(t, 10, cfg.CacheTTLSeconds)
+			assert.False(t, cfg.PrimaryEnabled)
+			assert.Equal(t, 5, cfg.PrimaryTimeoutSeconds)
+			assert.Equal(t, "http://localhost/stack", cfg.PrimaryEndPoint)
+			assert.Equal(t, []string{"file-key", "second-key"}, cfg.FailoverKeys())
+			assert.Equal(t, 0.5, cfg.FailoverFailureRatio)
+			assert.Equal(t, float64(0), cfg.QuotaReserve)
+			assert.Equal(t, []string{"Perth"}, cfg.DriftCities)
+			// Those not within the file are the defaults
+			assert.Equal(t, 60, cfg.NegativeCacheTTLSeconds)
+			assert.Equal(t, 3, cfg.FailoverTimeoutSeconds)
+		})
+	}
+}
+
+func Test_ConfigFromFileOverriddenByEnviroment(t *testing.T) {
+	t.Setenv("CACHE_TTL_SECONDS", "20")
+	t.Setenv("PRIMARY_ENABLED", "true")
+	t.Setenv("PRIMARY_ACCESS_KEY", "env-key")
+	t.Setenv("FAILOVER_ACCESS_KEYS", "")
+
+	cfg, err := config.LoadConfig("testdata/weather.yaml")
+	assert.NoError(t, err)
+	assert.Equal(t, 20, cfg.CacheTTLSeconds)
+	assert.True(t, cfg.PrimaryEnabled)
+	assert.Equal(t, []string{"env-key"}, cfg.PrimaryKeys())
+	assert.Equal(t, []string{"file-key"}, cfg.FailoverKeys())
+	assert.Equal(t, 5, cfg.PrimaryTimeoutSeconds)
+}
+
+func Test_ConfigFromUnsupportedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "weather.ini")
+	assert.NoError(t, os.WriteFile(path, []byte("port=:9090"), 0o600))
+
+	_, err := config.LoadConfig(path)
+	assert.ErrorContains(t, err, "unsupported configuration file type")
+}
+
+func Test_ConfigWrittenRedacted(t *testing.T) {
+	cfg, err := config.LoadConfig("testdata/weather.yaml")
+	assert.NoError(t, err)
+
+	var out bytes.Buffer
+	assert.NoError(t, cfg.WriteRedacted(&out))
+	assert.NotContains(t, out.String(), "file-key")
+	assert.NotContains(t, out.String(), "second-key")
+	assert.Contains(t, out.String(), "access_key: REDACTED")
+	assert.Contains(t, out.String(), "timeout_seconds: 5")
+	// The configuration itself is left untouched
+	assert.Equal(t, []string{"file-key", "second-key"}, cfg.FailoverKeys())
+}
+
+func Test_ConfigValidation(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		problems []string
+	}{
+		{
+			name:     "zero cache TTL",
+			env:      map[string]string{"CACHE_TTL_SECONDS": "0"},
+			problems: []string{"CACHE_TTL_SECONDS (cache_ttl_seconds): must be at least 1 (was 0)"},
+		},
+		{
+			name:     "port without a colon",
+			env:      map[string]string{"PORT": "8080"},
+			problems: []string{`PORT (port): must be [host]:port, such as :8080 (was "8080")`},
+		},
+		{
+			name:     "port out of range",
+			env:      map[string]string{"PORT": "localhost:70000"},
+			problems: []string{`PORT (port): must be [host]:port`},
+		},
+		{
+			name: "negative timeouts",
+			env:  map[string]string{"PRIMARY_TIMEOUT_SECONDS": "-1", "FAILOVER_TIMEOUT_SECONDS": "0"},
+			problems: []string{
+				"PRIMARY_TIMEOUT_SECONDS (primary.timeout_seconds): must be at least 1 (was -1)",
+				"FAILOVER_TIMEOUT_SECONDS (failover.timeout_seconds): must be at least 1 (was 0)",
+			},
+		},
+		{
+			name: "failure ratios out of range",
+			env:  map[string]string{"PRIMARY_FAILURE_RATIO": "1.5", "FAILOVER_FAILURE_RATIO": "0"},
+			problems: []string{
+				"PRIMARY_FAILURE_RATIO (primary.failure_ratio): must be above 0 and at most 1 (was 1.5)",
+				"FAILOVER_FAILURE_RATIO (failover.failure_ratio): must be above 0 and at most 1 (was 0)",
+			},
+		},
+		{
+			name: "malformed end points",
+			env:  map[string]string{"PRIMARY_END_POINT": "api.weatherstack.com", "FAILOVER_END_POINT": "ftp://host"},
+			problems: []string{
+				`PRIMARY_END_POINT (primary.end_point): must be an http or https URL (was "api.weatherstack.com")`,
+				`FAILOVER_END_POINT (failover.end_point): must be an http or https URL (was "ftp://host")`,
+			},
+		},
+		{
+			name: "unknown breaker policy",
+			env:  map[string]string{"PRIMARY_BREAKER_POLICY": "always", "FAILOVER_BREAKER_OPEN_SECONDS": "0"},
+			problems: []string{
+				`PRIMARY_BREAKER_POLICY (primary.breaker_policy): must be ratio or consecutive (was "always")`,
+				"FAILOVER_BREAKER_OPEN_SECONDS (failover.breaker_open_seconds): must be at least 1 (was 0)",
+			},
+		},
+		{
+			name:     "no requests before tripping",
+			env:      map[string]string{"PRIMARY_REQUESTS": "0"},
+			problems: []string{"PRIMARY_REQUESTS (primary.requests): must be at least 1 (was 0)"},
+		},
+		{
+			name: "billing day not in every month",
+			env:  map[string]string{"PRIMARY_BILLING_DAY": "31", "FAILOVER_BILLING_DAY": "0"},
+			problems: []string{
+				"PRIMARY_BILLING_DAY (primary.billing_day): must be between 1 and 28 (was 31)",
+				"FAILOVER_BILLING_DAY (failover.billing_day): must be between 1 and 28 (was 0)",
+			},
+		},
+		{
+			name: "daily budget above the monthly budget",
+			env:  map[string]string{"FAILOVER_DAILY_BUDGET": "100", "FAILOVER_MONTHLY_BUDGET": "50"},
+			problems: []string{
+				"FAILOVER_DAILY_BUDGET (failover.daily_budget): must not exceed the monthly budget of 50 (was 100)",
+			},
+		},
+		{
+			name:     "quota reserve out of range",
+			env:      map[string]string{"QUOTA_RESERVE": "1"},
+			problems: []string{"QUOTA_RESERVE (quota.reserve): must be at least 0 and below 1 (was 1)"},
+		},
+		{
+			name: "unknown strategies",
+			env:  map[string]string{"KEY_ROTATION": "random", "FETCH_STRATEGY": "fastest", "BLEND_METHOD": "mean"},
+			problems: []string{
+				`KEY_ROTATION (keys.rotation): must be round-robin or failover (was "random")`,
+				`FETCH_STRATEGY (blend.strategy): must be failover or blend (was "fastest")`,
+				`BLEND_METHOD (blend.method): must be median, weighted or outlier (was "mean")`,
+			},
+		},
+		{
+			name: "provider order",
+			env:  map[string]string{"PROVIDER_ORDER": "failover,failover"},
+			problems: []string{
+				"PROVIDER_ORDER (blend.provider_order): must be primary and failover, in either order (was [failover failover])",
+			},
+		},
+		{
+			name: "weighted blend without trust",
+			env: map[string]string{
+				"FETCH_STRATEGY": "blend", "BLEND_METHOD": "weighted", "PRIMARY_TRUST": "0", "FAILOVER_TRUST": "0",
+			},
+			problems: []string{"PRIMARY_TRUST (primary.trust): must be positive, should the failover trust be zero"},
+		},
+		{
+			name:     "drift sampler without cities",
+			env:      map[string]string{"DRIFT_INTERVAL_SECONDS": "60", "DRIFT_CITIES": ""},
+			problems: []string{"DRIFT_CITIES (drift.cities): must be given when the drift sampler is enabled"},
+		},
+		{
+			name: "hedging slower than the primary timeout",
+			env:  map[string]string{"HEDGING_ENABLED": "true", "HEDGING_DELAY_MILLISECONDS": "3000"},
+			problems: []string{
+				"HEDGING_DELAY_MILLISECONDS (hedging.delay_milliseconds): must be less than the primary timeout (was 3000)",
+			},
+		},
+		{
+			name:     "hedging percentile out of range",
+			env:      map[string]string{"HEDGING_PERCENTILE": "95"},
+			problems: []string{"HEDGING_PERCENTILE (hedging.percentile): must be above 0 and below 1 (was 95)"},
+		},
+		{
+			name:     "zero snapshot max age",
+			env:      map[string]string{"CACHE_SNAPSHOT_MAX_AGE_SECONDS": "0"},
+			problems: []string{"CACHE_SNAPSHOT_MAX_AGE_SECONDS (snapshot.max_age_seconds): must be at least 1 (was 0)"},
+		},
+		{
+			name: "warm lead beyond the cache TTL",
+			env:  map[string]string{"WARM_CITIES": "Melbourne", "WARM_LEAD_SECONDS": "3"},
+			problems: []string{
+				"WARM_LEAD_SECONDS (warm.lead_seconds): must be less than the cache TTL, when pre-warming (was 3)",
+			},
+		},
+		{
+			name: "malformed location TTLs",
+			env:  map[string]string{"CACHE_TTL_LOCATIONS": "Darwin=600,Mel[=60,Perth"},
+			problems: []string{
+				`CACHE_TTL_LOCATIONS (ttl.locations): rule "Mel[=60": syntax error in pattern`,
+			},
+		},
+		{
+			name: "TTL limits out of range",
+			env:  map[string]string{"CACHE_TTL_MAX_SECONDS": "2", "CACHE_STALE_MAX_AGE_SECONDS": "-1"},
+			problems: []string{
+				"CACHE_TTL_MAX_SECONDS (ttl.max_seconds): must be at least the cache TTL of 3 (was 2)",
+				"CACHE_STALE_MAX_AGE_SECONDS (ttl.stale_max_age_seconds): must not be negative (was -1)",
+			},
+		},
+		{
+			name: "stream intervals out of range",
+			env:  map[string]string{"STREAM_REFRESH_SECONDS": "0", "STREAM_HEARTBEAT_SECONDS": "-1"},
+			problems: []string{
+				"STREAM_REFRESH_SECONDS (stream.refresh_seconds): must be at least 1 (was 0)",
+				"STREAM_HEARTBEAT_SECONDS (stream.heartbeat_seconds): must be at least 1 (was -1)",
+			},
+		},
+		{
+			name:     "short admin token",
+			env:      map[string]string{"ADMIN_TOKEN": "secret"},
+			problems: []string{"ADMIN_TOKEN (admin.token): must be at least 16 characters (was 6)"},
+		},
+		{
+			name:     "all services disabled",
+			env:      map[string]string{"PRIMARY_ENABLED": "false", "FAILOVER_ENABLED": "false"},
+			problems: []string{"PRIMARY_ENABLED (primary.enabled): must be true, should the failover be disabled"},
+		},
+		{
+			name:     "valid",
+			env:      map[string]string{"PORT": "localhost:9090", "PRIMARY_END_POINT": "https://localhost/current"},
+			problems: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("PRIMARY_ACCESS_KEY", "a")
+			t.Setenv("FAILOVER_ACCESS_KEY", "b")
+			for name, value := range test.env {
+				t.Setenv(name, value)
+			}
+
+			_, err := config.LoadConfig("")
+			if test.problems == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Error(t, err)
+			// All the problems are returned at once (one per line)
+			assert.Len(t, strings.Split(err.Error(), "\n"), len(test.problems))
+			for _, problem := range test.problems {
+				assert.Contains(t, err.Error(), problem)
+			}
+		})
+	}
+}
+
+func Test_ConfigLocationTTLs(t *testing.T) {
+	cfg := &config.WeatherConfig{TTLConfig: config.TTLConfig{TTLLocations: []string{" Darwin = 600", "", "MEL*=300"}}}
+
+	rules, err := cfg.LocationTTLs()
+	assert.NoError(t, err)
+	assert.Equal(t, []config.LocationTTL{
+		{Pattern: "darwin", TTL: 600 * time.Second},
+		{Pattern: "mel*", TTL: 300 * time.Second},
+	}, rules)
+
+	for _, rule := range []string{"Perth", "=60", "Perth=0", "Perth=soon"} {
+		cfg.TTLLocations = []string{rule}
+		_, err := cfg.LocationTTLs()
+		assert.Error(t, err, rule)
+	}
 }
@@ -0,0 +1,52 @@
+package controller
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/ColinSchofield/zai-weather/src/config"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// The details of the problems returned by the admin API, should the request not be authenticated
+	MessageAdminDisabled      = "The admin API is disabled (no admin token is configured)"
+	MessageAdminUnauthorized  = "A valid admin token is required"
+	adminAuthenticationScheme = "Bearer "
+)
+
+// AdminAuth returns the middleware that authenticates each request to the admin API, by the bearer token of the
+// (current) configuration. Without a token, the admin API is disabled.
+func AdminAuth(cfg config.Source, log *logrus.Logger) gin.HandlerFunc {
+	return func(gCtx *gin.Context) {
+		token := cfg.Current().AdminToken
+		if token == "" {
+			abortWithProblem(gCtx, newProblem(ProblemAdminDisabled, http.StatusForbidden, MessageAdminDisabled))
+			return
+		}
+
+		given, found := strings.CutPrefix(gCtx.GetHeader("Authorization"), adminAuthenticationScheme)
+		if !found || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			audit(log, gCtx).Warn("Rejected an unauthenticated admin request")
+			gCtx.Header("WWW-Authenticate", `Bearer realm="admin"`)
+			abortWithProblem(gCtx, newProblem(ProblemAdminUnauthorized, http.StatusUnauthorized, MessageAdminUnauthorized))
+			return
+		}
+
+		gCtx.Next()
+	}
+}
+
+// Returns the entry that audits the admin request (i.e. who made it, and what was requested).
+func audit(log *logrus.Logger, gCtx *gin.Context) *logrus.Entry {
+	return log.WithFields(logrus.Fields{
+		"audit":      true,
+		"client_ip":  gCtx.ClientIP(),
+		"user_agent": gCtx.Request.UserAgent(),
+		"method":     gCtx.Request.Method,
+		"path":       gCtx.Request.URL.Path,
+	})
+}
@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ColinSchofield/zai-weather/src/breaker"
+	"github.com/ColinSchofield/zai-weather/src/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// The actions upon a circuit breaker, given by the admin API
+const (
+	ActionOpen    = "open"    // Force the breaker open
+	ActionClose   = "close"   // Force the breaker closed
+	ActionDisable = "disable" // Disable the weather service
+	ActionRelease = "release" // Release the forced mode (i.e. back to auto)
+	ActionReset   = "reset"   // Close the breaker, clearing its counts
+)
+
+// The modes forced by each action (the reset keeps the mode).
+var actionModes = map[string]breaker.Mode{
+	ActionOpen:    breaker.ForcedOpen,
+	ActionClose:   breaker.ForcedClosed,
+	ActionDisable: breaker.Disabled,
+	ActionRelease: breaker.Auto,
+}
+
+// The BreakerController interface inspects and controls the circuit breakers of the weather services.
+type BreakerController interface {
+	GetBreakers(gCtx *gin.Context)
+	UpdateBreaker(gCtx *gin.Context)
+}
+
+type DefaultBreakerController struct {
+	log      *logrus.Logger
+	breakers breaker.Factory
+}
+
+var _ BreakerController = (*DefaultBreakerController)(nil)
+
+// NewBreakerController returns the default struct for the breaker controller.
+func NewBreakerController(log *logrus.Logger, breakers breaker.Factory) *DefaultBreakerController {
+	return &DefaultBreakerController{
+		log:      log,
+		breakers: breakers,
+	}
+}
+
+// GetBreakers returns a JSON value containing the mode, state and counts of each circuit breaker, along with their
+// most recent changes of state.
+func (b *DefaultBreakerController) GetBreakers(gCtx *gin.Context) {
+	report := model.BreakerReport{
+		Breakers: []model.BreakerStatus{},
+		Events:   b.breakers.Events(),
+	}
+	for _, cb := range b.breakers.Breakers() {
+		report.Breakers = append(report.Breakers, cb.Status())
+	}
+	if report.Events == nil {
+		report.Events = []model.BreakerEvent{}
+	}
+
+	gCtx.JSON(http.StatusOK, report)
+}
+
+// UpdateBreaker applies the action (e.g. open) to the circuit breaker of the id (e.g. primary), returning its status.
+// Each action is audited.
+func (b *DefaultBreakerController) UpdateBreaker(gCtx *gin.Context) {
+	id, action := gCtx.Param("id"), gCtx.Param("action")
+	entry := audit(b.log, gCtx).WithField("breaker", id).WithField("action", action)
+
+	var cb *breaker.Breaker
+	var err error
+	if mode, found := actionModes[action]; found {
+		cb, err = b.breakers.SetMode(id, mode)
+	} else if action == ActionReset {
+		cb, err = b.breakers.Reset(id)
+	} else {
+		entry.Warn("Rejected an unknown action upon a circuit breaker")
+		abortWithProblem(gCtx, newProblem(ProblemBadRequest, http.StatusBadRequest, "Unknown action "+action))
+		return
+	}
+
+	switch {
+	case errors.Is(err, breaker.ErrUnknownBreaker):
+		entry.Warn("Rejected an action upon an unknown circuit breaker")
+		abortWithProblem(gCtx, newProblem(ProblemResourceNotFound, http.StatusNotFound, err.Error()))
+	case err != nil:
+		entry.WithError(err).Error("Failed to apply the action to the circuit breaker")
+		abortWithProblem(gCtx, newProblem(ProblemInternal, http.StatusInternalServerError, err.Error()))
+	default:
+		status := cb.Status()
+		entry.WithField("mode", status.Mode).WithField("state", status.State).
+			Info("Applied the action to the circuit breaker of ", status.Name)
+		gCtx.JSON(http.StatusOK, status)
+	}
+}
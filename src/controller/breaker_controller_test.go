@@ -0,0 +1,165 @@
+package controller_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ColinSchofield/zai-weather/src/breaker"
+	"github.com/ColinSchofield/zai-weather/src/config"
+	"github.com/ColinSchofield/zai-weather/src/controller"
+	"github.com/ColinSchofield/zai-weather/src/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/suite"
+)
+
+const adminToken = "d5a2c0f6e1b94c7a"
+
+type BreakerControllerTestSuite struct {
+	suite.Suite
+
+	cfg      *config.WeatherConfig
+	logs     *test.Hook
+	breakers *breaker.DefaultFactory
+	router   *gin.Engine
+}
+
+func TestBreakerControllerSuite(t *testing.T) {
+	suite.Run(t, new(BreakerControllerTestSuite))
+}
+
+func (s *BreakerControllerTestSuite) SetupTest() {
+	s.cfg = &config.WeatherConfig{AdminConfig: config.AdminConfig{AdminToken: adminToken}}
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	s.logs = test.NewLocal(log)
+	store, _ := breaker.NewFileStore("")
+	s.breakers = breaker.NewFactory(log, store)
+	s.breakers.New("primary", "Weather Stack (primary)", func() breaker.Settings {
+		return breaker.Settings{Policy: breaker.Ratio, Requests: 3, FailureRatio: 0.6, HalfOpenRequests: 1}
+	})
+
+	breakerController := controller.NewBreakerController(log, s.breakers)
+	s.router = gin.New()
+	s.router.Use(controller.Problems(log))
+	admin := s.router.Group("admin", controller.AdminAuth(s.cfg, log))
+	admin.GET("breakers", breakerController.GetBreakers)
+	admin.POST("breakers/:id/:action", breakerController.UpdateBreaker)
+}
+
+func (s *BreakerControllerTestSuite) request(method, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	record := httptest.NewRecorder()
+	s.router.ServeHTTP(record, req)
+
+	return record
+}
+
+func (s *BreakerControllerTestSuite) Test_GetBreakers() {
+	// When
+	record := s.request(http.MethodGet, "/admin/breakers", adminToken)
+	// Then
+	s.Assert().Equal(http.StatusOK, record.Code)
+	var report model.BreakerReport
+	s.Require().NoError(json.Unmarshal(record.Body.Bytes(), &report))
+	s.Require().Len(report.Breakers, 1)
+	s.Assert().Equal("primary", report.Breakers[0].ID)
+	s.Assert().Equal("Weather Stack (primary)", report.Breakers[0].Name)
+	s.Assert().Equal("auto", report.Breakers[0].Mode)
+	s.Assert().Equal("closed", report.Breakers[0].State)
+	s.Assert().Nil(report.Breakers[0].SuspendedUntil)
+	s.Assert().NotNil(report.Events)
+}
+
+func (s *BreakerControllerTestSuite) Test_GetSuspendedBreaker() {
+	// Given
+	until := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	s.breakers.Breakers()[0].Suspend(until)
+	// When
+	record := s.request(http.MethodGet, "/admin/breakers", adminToken)
+	// Then
+	s.Assert().Equal(http.StatusOK, record.Code)
+	var report model.BreakerReport
+	s.Require().NoError(json.Unmarshal(record.Body.Bytes(), &report))
+	s.Require().NotNil(report.Breakers[0].SuspendedUntil)
+	s.Assert().True(until.Equal(*report.Breakers[0].SuspendedUntil))
+}
+
+func (s *BreakerControllerTestSuite) Test_ForceOpen() {
+	// When
+	record := s.request(http.MethodPost, "/admin/breakers/primary/open", adminToken)
+	// Then
+	s.Assert().Equal(http.StatusOK, record.Code)
+	var status model.BreakerStatus
+	s.Require().NoError(json.Unmarshal(record.Body.Bytes(), &status))
+	s.Assert().Equal("forced-open", status.Mode)
+	s.Assert().Equal(breaker.ForcedOpen, s.breakers.Breakers()[0].Mode())
+	entry := s.logs.LastEntry()
+	s.Assert().Equal(true, entry.Data["audit"])
+	s.Assert().Equal("open", entry.Data["action"])
+	s.Assert().Equal("primary", entry.Data["breaker"])
+}
+
+func (s *BreakerControllerTestSuite) Test_EachAction() {
+	for action, mode := range map[string]string{
+		controller.ActionClose:   "forced-closed",
+		controller.ActionDisable: "disabled",
+		controller.ActionRelease: "auto",
+		controller.ActionReset:   "auto",
+	} {
+		// When
+		record := s.request(http.MethodPost, "/admin/breakers/primary/"+action, adminToken)
+		// Then
+		s.Assert().Equal(http.StatusOK, record.Code, action)
+		var status model.BreakerStatus
+		s.Require().NoError(json.Unmarshal(record.Body.Bytes(), &status))
+		s.Assert().Equal(mode, status.Mode, action)
+		s.breakers.SetMode("primary", breaker.Auto)
+	}
+}
+
+func (s *BreakerControllerTestSuite) Test_UnknownBreaker() {
+	// When
+	record := s.request(http.MethodPost, "/admin/breakers/unknown/open", adminToken)
+	// Then
+	s.Assert().Equal(http.StatusNotFound, record.Code)
+	s.Assert().Equal(logrus.WarnLevel, s.logs.LastEntry().Level)
+}
+
+func (s *BreakerControllerTestSuite) Test_UnknownAction() {
+	// When
+	record := s.request(http.MethodPost, "/admin/breakers/primary/explode", adminToken)
+	// Then
+	s.Assert().Equal(http.StatusBadRequest, record.Code)
+	s.Assert().Equal(breaker.Auto, s.breakers.Breakers()[0].Mode())
+}
+
+func (s *BreakerControllerTestSuite) Test_Unauthenticated() {
+	for _, token := range []string{"", "wrong-token-0123456789"} {
+		// When
+		record := s.request(http.MethodPost, "/admin/breakers/primary/open", token)
+		// Then
+		s.Assert().Equal(http.StatusUnauthorized, record.Code)
+		s.Assert().NotEmpty(record.Header().Get("WWW-Authenticate"))
+		s.Assert().Equal(true, s.logs.LastEntry().Data["audit"])
+	}
+	s.Assert().Equal(breaker.Auto, s.breakers.Breakers()[0].Mode(), "The breaker is untouched")
+}
+
+func (s *BreakerControllerTestSuite) Test_AdminDisabledWithoutToken() {
+	// Given
+	s.cfg.AdminToken = ""
+	// When
+	record := s.request(http.MethodGet, "/admin/breakers", "")
+	// Then
+	s.Assert().Equal(http.StatusForbidden, record.Code)
+}
@@ -0,0 +1,155 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/ColinSchofield/zai-weather/src/cache"
+	"github.com/ColinSchofield/zai-weather/src/model"
+	"github.com/ColinSchofield/zai-weather/src/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// The detail of the problem returned when purging the cache, without saying what to purge
+	MessagePurgeUnscoped = "Give either a prefix, or all=true, to purge the cache"
+	// The detail of the problem returned when the location is not in the cache
+	MessageNotCached = "The location is not in the cache"
+)
+
+// The Refresher interface fetches the weather information of a location through the weather services, bypassing
+// (and then updating) the cache.
+type Refresher interface {
+	Refresh(ctx context.Context, location string) (*model.Weather, error)
+}
+
+// The CacheController interface inspects and purges the cache of the weather information.
+type CacheController interface {
+	GetEntries(gCtx *gin.Context)
+	GetEntry(gCtx *gin.Context)
+	PurgeEntry(gCtx *gin.Context)
+	PurgeEntries(gCtx *gin.Context)
+	RefreshEntry(gCtx *gin.Context)
+}
+
+type DefaultCacheController struct {
+	log           *logrus.Logger
+	weatherCache  cache.Weather
+	notFoundCache cache.Negative
+	refresher     Refresher
+}
+
+var _ CacheController = (*DefaultCacheController)(nil)
+
+// NewCacheController returns the default struct for the cache controller.
+func NewCacheController(
+	log *logrus.Logger,
+	weatherCache cache.Weather,
+	notFoundCache cache.Negative,
+	refresher Refresher,
+) *DefaultCacheController {
+	return &DefaultCacheController{
+		log:           log,
+		weatherCache:  weatherCache,
+		notFoundCache: notFoundCache,
+		refresher:     refresher,
+	}
+}
+
+// GetEntries returns a JSON value listing the location, source and age of each entry of the cache.
+func (c *DefaultCacheController) GetEntries(gCtx *gin.Context) {
+	entries := []model.CacheEntry{}
+	for _, entry := range c.weatherCache.Entries() {
+		entries = append(entries, cacheEntry(entry, false))
+	}
+
+	gCtx.JSON(http.StatusOK, entries)
+}
+
+// GetEntry returns a JSON value containing the entry of the location, along with its weather information.
+func (c *DefaultCacheController) GetEntry(gCtx *gin.Context) {
+	entry, found := c.weatherCache.Lookup(gCtx.Param("location"))
+	if !found {
+		abortWithProblem(gCtx, newProblem(ProblemResourceNotFound, http.StatusNotFound, MessageNotCached))
+		return
+	}
+
+	gCtx.JSON(http.StatusOK, cacheEntry(entry, true))
+}
+
+// PurgeEntry removes the entry of the location from the cache, and forgets whether it could not be found. Each purge is
+// audited.
+func (c *DefaultCacheController) PurgeEntry(gCtx *gin.Context) {
+	location := gCtx.Param("location")
+	purged := 0
+	if c.weatherCache.Delete(location) {
+		purged = 1
+	}
+	forgotten := 0
+	if c.notFoundCache.Delete(location) {
+		forgotten = 1
+	}
+
+	audit(c.log, gCtx).WithField("location", location).WithField("purged", purged).WithField("forgotten", forgotten).
+		Info("Purged the cache")
+	gCtx.JSON(http.StatusOK, model.CachePurge{Purged: purged})
+}
+
+// PurgeEntries removes the entries whose location starts with the prefix (ignoring case), or all the entries given
+// all=true, forgetting likewise the locations that could not be found. Each purge is audited.
+func (c *DefaultCacheController) PurgeEntries(gCtx *gin.Context) {
+	entry := audit(c.log, gCtx)
+	var purged, forgotten int
+	if prefix := gCtx.Query("prefix"); prefix != "" {
+		purged = c.weatherCache.DeletePrefix(prefix)
+		forgotten = c.notFoundCache.DeletePrefix(prefix)
+		entry = entry.WithField("prefix", prefix)
+	} else if gCtx.Query("all") == "true" {
+		purged = c.weatherCache.Flush()
+		forgotten = c.notFoundCache.Flush()
+		entry = entry.WithField("all", true)
+	} else {
+		abortWithProblem(gCtx, newProblem(ProblemBadRequest, http.StatusBadRequest, MessagePurgeUnscoped))
+		return
+	}
+
+	entry.WithField("purged", purged).WithField("forgotten", forgotten).Info("Purged the cache")
+	gCtx.JSON(http.StatusOK, model.CachePurge{Purged: purged})
+}
+
+// RefreshEntry fetches the weather information of the location through the weather services (as configured), and
+// caches it, returning the refreshed entry. Each refresh is audited.
+func (c *DefaultCacheController) RefreshEntry(gCtx *gin.Context) {
+	location := gCtx.Param("location")
+	entry := audit(c.log, gCtx).WithField("location", location)
+
+	if _, err := c.refresher.Refresh(gCtx.Request.Context(), location); err != nil {
+		entry.WithError(err).Warn("Failed to refresh the cache")
+		abortWithProblem(gCtx, upstreamFailure(location, service.KindOf(err)).problem())
+		return
+	}
+
+	cached, _ := c.weatherCache.Lookup(location)
+	entry.WithField("source", cached.Source).Info("Refreshed the cache")
+	gCtx.JSON(http.StatusOK, cacheEntry(cached, true))
+}
+
+// Returns the entry of the cache, with (or without) its weather information.
+func cacheEntry(entry cache.Entry, withWeather bool) model.CacheEntry {
+	result := model.CacheEntry{
+		Location:   entry.Key,
+		Source:     entry.Source,
+		StoredAt:   entry.StoredAt,
+		AgeSeconds: int(time.Since(entry.StoredAt).Seconds()),
+		TTLSeconds: int(entry.TTL.Seconds()),
+		Fresh:      entry.Fresh,
+	}
+	if weather, ok := entry.Value.(*model.Weather); ok && withWeather {
+		result.Weather = weather
+	}
+
+	return result
+}
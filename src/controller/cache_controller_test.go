@@ -0,0 +1,194 @@
+package controller_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ColinSchofield/zai-weather/src/cache"
+	"github.com/ColinSchofield/zai-weather/src/config"
+	"github.com/ColinSchofield/zai-weather/src/controller"
+	"github.com/ColinSchofield/zai-weather/src/model"
+	"github.com/ColinSchofield/zai-weather/src/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/suite"
+)
+
+// The stubRefresher caches the weather information given (or fails with the error).
+type stubRefresher struct {
+	weatherCache cache.Weather
+	weather      *model.Weather
+	err          error
+}
+
+func (r *stubRefresher) Refresh(_ context.Context, location string) (*model.Weather, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	r.weatherCache.Set(location, r.weather, "failover", 0)
+
+	return r.weather, nil
+}
+
+type CacheControllerTestSuite struct {
+	suite.Suite
+
+	logs          *test.Hook
+	weatherCache  *cache.DefaultWeatherCache
+	notFoundCache *cache.DefaultNegativeCache
+	refresher     *stubRefresher
+	router        *gin.Engine
+}
+
+func TestCacheControllerSuite(t *testing.T) {
+	suite.Run(t, new(CacheControllerTestSuite))
+}
+
+func (s *CacheControllerTestSuite) SetupTest() {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	s.logs = test.NewLocal(log)
+	s.weatherCache = cache.NewWeatherCache(time.Minute)
+	s.weatherCache.Set("Melbourne", &model.Weather{Data: &model.Data{Temperature: 10}}, "primary", 0)
+	s.weatherCache.Set("Melton", &model.Weather{Data: &model.Data{Temperature: 11}}, "primary", 0)
+	s.weatherCache.Set("Sydney", &model.Weather{Data: &model.Data{Temperature: 20}}, "blend", 0)
+	s.notFoundCache = cache.NewNegativeCache(time.Minute)
+	s.notFoundCache.Add("Melbourn")
+	s.notFoundCache.Add("Sydny")
+	s.refresher = &stubRefresher{weatherCache: s.weatherCache, weather: &model.Weather{Data: &model.Data{Temperature: 12}}}
+
+	cfg := &config.WeatherConfig{AdminConfig: config.AdminConfig{AdminToken: adminToken}}
+	cacheController := controller.NewCacheController(log, s.weatherCache, s.notFoundCache, s.refresher)
+	s.router = gin.New()
+	s.router.Use(controller.Problems(log))
+	admin := s.router.Group("admin", controller.AdminAuth(cfg, log))
+	admin.GET("cache", cacheController.GetEntries)
+	admin.DELETE("cache", cacheController.PurgeEntries)
+	admin.GET("cache/:location", cacheController.GetEntry)
+	admin.DELETE("cache/:location", cacheController.PurgeEntry)
+	admin.POST("cache/:location/refresh", cacheController.RefreshEntry)
+}
+
+func (s *CacheControllerTestSuite) request(method, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	record := httptest.NewRecorder()
+	s.router.ServeHTTP(record, req)
+
+	return record
+}
+
+func (s *CacheControllerTestSuite) Test_GetEntries() {
+	// When
+	record := s.request(http.MethodGet, "/admin/cache")
+	// Then
+	s.Assert().Equal(http.StatusOK, record.Code)
+	var entries []model.CacheEntry
+	s.Require().NoError(json.Unmarshal(record.Body.Bytes(), &entries))
+	s.Require().Len(entries, 3)
+	s.Assert().Equal("Melbourne", entries[0].Location)
+	s.Assert().Equal("primary", entries[0].Source)
+	s.Assert().True(entries[0].Fresh)
+	s.Assert().Nil(entries[0].Weather, "The list omits the weather information")
+	s.Assert().Equal("blend", entries[2].Source)
+}
+
+func (s *CacheControllerTestSuite) Test_GetEntry() {
+	// When
+	record := s.request(http.MethodGet, "/admin/cache/Sydney")
+	// Then
+	s.Assert().Equal(http.StatusOK, record.Code)
+	var entry model.CacheEntry
+	s.Require().NoError(json.Unmarshal(record.Body.Bytes(), &entry))
+	s.Assert().Equal(20, entry.Weather.Data.Temperature)
+	// When
+	record = s.request(http.MethodGet, "/admin/cache/Perth")
+	// Then
+	s.Assert().Equal(http.StatusNotFound, record.Code)
+}
+
+func (s *CacheControllerTestSuite) Test_PurgeEntry() {
+	// When
+	record := s.request(http.MethodDelete, "/admin/cache/Sydney")
+	// Then
+	s.Assert().Equal(http.StatusOK, record.Code)
+	s.Assert().JSONEq(`{"purged":1}`, record.Body.String())
+	_, found := s.weatherCache.GetIgnoreTTL("Sydney")
+	s.Assert().False(found)
+	s.Assert().Equal(true, s.logs.LastEntry().Data["audit"])
+	s.Assert().Equal("Sydney", s.logs.LastEntry().Data["location"])
+}
+
+func (s *CacheControllerTestSuite) Test_PurgeEntryForgetsItWasNotFound() {
+	// When
+	record := s.request(http.MethodDelete, "/admin/cache/Sydny")
+	// Then
+	s.Assert().Equal(http.StatusOK, record.Code)
+	s.Assert().JSONEq(`{"purged":0}`, record.Body.String())
+	s.Assert().False(s.notFoundCache.Contains("Sydny"), "It may be found once fetched again")
+	s.Assert().Equal(1, s.logs.LastEntry().Data["forgotten"])
+}
+
+func (s *CacheControllerTestSuite) Test_PurgeByPrefix() {
+	// When
+	record := s.request(http.MethodDelete, "/admin/cache?prefix=mel")
+	// Then
+	s.Assert().Equal(http.StatusOK, record.Code)
+	s.Assert().JSONEq(`{"purged":2}`, record.Body.String())
+	s.Assert().Len(s.weatherCache.Entries(), 1)
+	s.Assert().False(s.notFoundCache.Contains("Melbourn"))
+	s.Assert().True(s.notFoundCache.Contains("Sydny"))
+}
+
+func (s *CacheControllerTestSuite) Test_PurgeAll() {
+	// When
+	record := s.request(http.MethodDelete, "/admin/cache?all=true")
+	// Then
+	s.Assert().Equal(http.StatusOK, record.Code)
+	s.Assert().JSONEq(`{"purged":3}`, record.Body.String())
+	s.Assert().Empty(s.weatherCache.Entries())
+	s.Assert().False(s.notFoundCache.Contains("Sydny"))
+}
+
+func (s *CacheControllerTestSuite) Test_PurgeWithoutScope() {
+	// When
+	record := s.request(http.MethodDelete, "/admin/cache")
+	// Then
+	s.Assert().Equal(http.StatusBadRequest, record.Code)
+	s.Assert().Len(s.weatherCache.Entries(), 3, "Nothing is purged")
+	s.Assert().True(s.notFoundCache.Contains("Melbourn"))
+}
+
+func (s *CacheControllerTestSuite) Test_RefreshEntry() {
+	// When
+	record := s.request(http.MethodPost, "/admin/cache/Melbourne/refresh")
+	// Then
+	s.Assert().Equal(http.StatusOK, record.Code)
+	var entry model.CacheEntry
+	s.Require().NoError(json.Unmarshal(record.Body.Bytes(), &entry))
+	s.Assert().Equal("failover", entry.Source)
+	s.Assert().Equal(12, entry.Weather.Data.Temperature)
+	s.Assert().Equal("Refreshed the cache", s.logs.LastEntry().Message)
+}
+
+func (s *CacheControllerTestSuite) Test_RefreshEntryFailure() {
+	for kind, status := range map[service.ErrorKind]int{
+		service.KindNotFound:    http.StatusNotFound,
+		service.KindUnavailable: http.StatusServiceUnavailable,
+		service.KindBadResponse: http.StatusBadGateway,
+	} {
+		// Given
+		s.refresher.err = &service.FetchError{Kind: kind}
+		// When
+		record := s.request(http.MethodPost, "/admin/cache/Melbourne/refresh")
+		// Then
+		s.Assert().Equal(status, record.Code, kind.String())
+	}
+}
@@ -0,0 +1,157 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ColinSchofield/zai-weather/src/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/render"
+)
+
+const (
+	// The formats the weather information may be rendered in (see the format parameter)
+	FormatJSON = "json"
+	FormatXML  = "xml"
+	FormatCSV  = "csv"
+	FormatText = "text"
+
+	// The detail of the problem returned, should none of the formats be acceptable
+	MessageNotAcceptable = "Give a format of json, xml, csv or text (or Accept application/json, application/xml, " +
+		"text/csv or text/plain)"
+)
+
+// The formats of the media types that may be accepted. Of the wildcards, text/* gives plain text, the others JSON.
+var mediaTypeFormats = map[string]string{
+	"application/json": FormatJSON,
+	"application/xml":  FormatXML,
+	"text/xml":         FormatXML,
+	"text/csv":         FormatCSV,
+	"text/plain":       FormatText,
+	"application/*":    FormatJSON,
+	"text/*":           FormatText,
+	"*/*":              FormatJSON,
+}
+
+// The columns of the CSV rendering (following a header row).
+var csvHeader = []string{
+	"location", "temperature_degrees", "wind_speed", "message", "source", "observed_at", "fetched_at", "age_seconds",
+}
+
+// Returns the format to render the weather information in: that of the format parameter, otherwise the most preferred
+// of the Accept header (JSON without either). Returns false should none of the formats be acceptable.
+func negotiate(gCtx *gin.Context) (string, bool) {
+	if format, found := gCtx.GetQuery("format"); found {
+		format = strings.ToLower(format)
+		switch format {
+		case FormatJSON, FormatXML, FormatCSV, FormatText:
+			return format, true
+		default:
+			return "", false
+		}
+	}
+
+	accept := ""
+	if gCtx.Request != nil {
+		accept = gCtx.GetHeader("Accept")
+	}
+	if strings.TrimSpace(accept) == "" {
+		return FormatJSON, true
+	}
+
+	type accepted struct {
+		mediaType string
+		quality   float64
+	}
+	var ranges []accepted
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		candidate := accepted{mediaType: strings.ToLower(strings.TrimSpace(params[0])), quality: 1}
+		for _, param := range params[1:] {
+			if value, found := strings.CutPrefix(strings.TrimSpace(param), "q="); found {
+				candidate.quality, _ = strconv.ParseFloat(value, 64) // An invalid quality is not acceptable
+			}
+		}
+		if candidate.quality > 0 {
+			ranges = append(ranges, candidate)
+		}
+	}
+	// The most preferred first, and of these, the most specific (i.e. without wildcards)
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].quality != ranges[j].quality {
+			return ranges[i].quality > ranges[j].quality
+		}
+		return strings.Count(ranges[i].mediaType, "*") < strings.Count(ranges[j].mediaType, "*")
+	})
+
+	for _, r := range ranges {
+		if format, found := mediaTypeFormats[r.mediaType]; found {
+			return format, true
+		}
+	}
+
+	return "", false
+}
+
+// Returns the renderer of the weather information (of the location) in the format.
+func renderWeather(format, location string, weather model.Weather) render.Render {
+	switch format {
+	case FormatXML:
+		return render.XML{Data: weather}
+	case FormatCSV:
+		return render.Data{ContentType: "text/csv; charset=utf-8", Data: weatherCSV(location, weather)}
+	case FormatText:
+		return render.Data{ContentType: "text/plain; charset=utf-8", Data: []byte(weatherText(location, weather))}
+	default:
+		return render.JSON{Data: weather}
+	}
+}
+
+// Returns the weather information as CSV: a header row, followed by the row of the location.
+func weatherCSV(location string, weather model.Weather) []byte {
+	row := []string{location, "", "", weather.Message, "", "", "", ""}
+	if weather.Data != nil {
+		row[1], row[2] = strconv.Itoa(weather.Data.Temperature), strconv.Itoa(weather.Data.WindSpeed)
+	}
+	if freshness := weather.Freshness; freshness != nil {
+		row[4] = freshness.Source
+		if freshness.ObservedAt != nil {
+			row[5] = freshness.ObservedAt.UTC().Format(time.RFC3339)
+		}
+		row[6] = freshness.FetchedAt.UTC().Format(time.RFC3339)
+		row[7] = strconv.Itoa(freshness.AgeSeconds)
+	}
+
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	_ = writer.WriteAll([][]string{csvHeader, row}) // Writing to a buffer does not fail
+
+	return buffer.Bytes()
+}
+
+// Returns the weather information as a one line summary, e.g. "Melbourne: 29°C, wind 20 km/h (Request successful,
+// 420s old)".
+func weatherText(location string, weather model.Weather) string {
+	summary := location + ":"
+	if weather.Data != nil {
+		summary += fmt.Sprintf(" %d°C, wind %d km/h", weather.Data.Temperature, weather.Data.WindSpeed)
+	}
+	summary += " (" + weather.Message
+	if weather.Freshness != nil {
+		summary += fmt.Sprintf(", %ds old", weather.Freshness.AgeSeconds)
+	}
+
+	return summary + ")\n"
+}
+
+// Report that none of the formats are acceptable.
+func notAcceptable(gCtx *gin.Context) {
+	abortWithProblem(gCtx, newProblem(ProblemNotAcceptable, http.StatusNotAcceptable, MessageNotAcceptable))
+}
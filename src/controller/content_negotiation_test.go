@@ -0,0 +1,133 @@
+package controller_test
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ColinSchofield/zai-weather/src/config"
+	"github.com/ColinSchofield/zai-weather/src/controller"
+	mock "github.com/ColinSchofield/zai-weather/src/mock"
+	"github.com/ColinSchofield/zai-weather/src/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+	"github.com/sony/gobreaker/v2"
+	"github.com/stretchr/testify/suite"
+)
+
+type ContentNegotiationTestSuite struct {
+	suite.Suite
+
+	mockPrimary *mock.MockWeatherFetcher
+	router      *gin.Engine
+}
+
+func TestContentNegotiationSuite(t *testing.T) {
+	suite.Run(t, new(ContentNegotiationTestSuite))
+}
+
+func (s *ContentNegotiationTestSuite) SetupTest() {
+	ctrl := gomock.NewController(s.T())
+	s.mockPrimary = mock.NewMockWeatherFetcher(ctrl)
+	weatherController := controller.NewWeatherController(
+		&config.WeatherConfig{CacheTTLSeconds: 60},
+		logrus.New(),
+		s.mockPrimary,
+		mock.NewMockWeatherFetcher(ctrl),
+		gobreaker.NewCircuitBreaker[any](gobreaker.Settings{Name: "primary"}),
+		gobreaker.NewCircuitBreaker[any](gobreaker.Settings{Name: "failover"}),
+	)
+	s.router = gin.New()
+	s.router.Use(controller.Problems(logrus.New()))
+	s.router.GET("v1/weather", weatherController.GetWeather)
+}
+
+func (s *ContentNegotiationTestSuite) request(path, accept string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	record := httptest.NewRecorder()
+	s.router.ServeHTTP(record, req)
+
+	return record
+}
+
+func (s *ContentNegotiationTestSuite) Test_Formats() {
+	// Given
+	weather := &model.Weather{Data: &model.Data{Temperature: 10, WindSpeed: 15}}
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Sydney").Return(weather, nil)
+	// When
+	record := s.request("/v1/weather?city=Sydney", "")
+	// Then
+	s.Assert().Equal(http.StatusOK, record.Code)
+	s.Assert().Equal("application/json; charset=utf-8", record.Header().Get("Content-Type"), "JSON by default")
+	s.Assert().Equal("Accept", record.Header().Get("Vary"))
+	jsonETag := record.Header().Get("ETag")
+	// When
+	record = s.request("/v1/weather?city=Sydney&format=xml", "text/csv")
+	// Then
+	s.Assert().Equal("application/xml; charset=utf-8", record.Header().Get("Content-Type"), "The format, over Accept")
+	var fromXML model.Weather
+	s.Require().NoError(xml.Unmarshal(record.Body.Bytes(), &fromXML))
+	s.Assert().Equal("weather", fromXML.XMLName.Local)
+	s.Assert().Equal(model.Data{Temperature: 10, WindSpeed: 15}, *fromXML.Data)
+	s.Assert().Equal("primary", fromXML.Freshness.Source)
+	s.Assert().NotEqual(jsonETag, record.Header().Get("ETag"), "Each format has its own ETag")
+	// When
+	record = s.request("/v1/weather?city=Sydney", "text/csv")
+	// Then
+	s.Assert().Equal("text/csv; charset=utf-8", record.Header().Get("Content-Type"))
+	lines := strings.Split(strings.TrimSpace(record.Body.String()), "\n")
+	s.Require().Len(lines, 2)
+	s.Assert().Equal("location,temperature_degrees,wind_speed,message,source,observed_at,fetched_at,age_seconds", lines[0])
+	s.Assert().Regexp(`^Sydney,10,15,Request successful \(cached\),primary,,[0-9TZ:-]+,0$`, lines[1])
+	// When
+	record = s.request("/v1/weather?city=Sydney", "text/plain")
+	// Then
+	s.Assert().Equal("text/plain; charset=utf-8", record.Header().Get("Content-Type"))
+	s.Assert().Equal("Sydney: 10°C, wind 15 km/h (Request successful (cached), 0s old)\n", record.Body.String())
+}
+
+func (s *ContentNegotiationTestSuite) Test_AcceptPreferences() {
+	// Given
+	weather := &model.Weather{Data: &model.Data{Temperature: 10, WindSpeed: 15}}
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Sydney").Return(weather, nil)
+	for accept, contentType := range map[string]string{
+		"application/xml;q=0.5, text/csv":  "text/csv; charset=utf-8",
+		"*/*, text/plain":                  "text/plain; charset=utf-8",
+		"image/png, */*;q=0.1":             "application/json; charset=utf-8",
+		"text/*":                           "text/plain; charset=utf-8",
+		"text/csv;q=0, application/xml":    "application/xml; charset=utf-8",
+		"application/json;q=0.9, text/xml": "application/xml; charset=utf-8",
+	} {
+		// When
+		record := s.request("/v1/weather?city=Sydney", accept)
+		// Then
+		s.Assert().Equal(http.StatusOK, record.Code, accept)
+		s.Assert().Equal(contentType, record.Header().Get("Content-Type"), accept)
+	}
+}
+
+func (s *ContentNegotiationTestSuite) Test_NotAcceptable() {
+	for _, test := range []struct{ path, accept string }{
+		{"/v1/weather?city=Sydney", "image/png"},
+		{"/v1/weather?city=Sydney", "text/csv;q=0"},
+		{"/v1/weather?city=Sydney&format=yaml", ""},
+	} {
+		// When
+		record := s.request(test.path, test.accept)
+		// Then
+		s.Assert().Equal(http.StatusNotAcceptable, record.Code, test)
+		s.Assert().Equal(controller.ContentTypeProblem, record.Header().Get("Content-Type"), test)
+		var problem model.Problem
+		s.Require().NoError(json.Unmarshal(record.Body.Bytes(), &problem))
+		s.Assert().Equal(controller.ProblemNotAcceptable, problem.Type, test)
+		s.Assert().Equal(controller.MessageNotAcceptable, problem.Detail, test)
+	}
+}
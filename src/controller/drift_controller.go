@@ -0,0 +1,32 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/ColinSchofield/zai-weather/src/drift"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The DriftController interface reports on how far the weather services drift apart.
+type DriftController interface {
+	GetDrift(gCtx *gin.Context)
+}
+
+type DefaultDriftController struct {
+	sampler drift.Sampler
+}
+
+var _ DriftController = (*DefaultDriftController)(nil)
+
+// NewDriftController returns the default struct for the drift controller.
+func NewDriftController(sampler drift.Sampler) *DefaultDriftController {
+	return &DefaultDriftController{
+		sampler: sampler,
+	}
+}
+
+// GetDrift returns a JSON value containing the bias and variance of each weather service, from their consensus.
+func (d *DefaultDriftController) GetDrift(gCtx *gin.Context) {
+	gCtx.JSON(http.StatusOK, d.sampler.Report())
+}
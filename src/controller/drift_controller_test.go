@@ -0,0 +1,31 @@
+package controller_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ColinSchofield/zai-weather/src/controller"
+	"github.com/ColinSchofield/zai-weather/src/drift"
+	"github.com/ColinSchofield/zai-weather/src/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GetDrift(t *testing.T) {
+	// Given
+	sampler := drift.NewSampler(logrus.New(), []string{"Melbourne"}, drift.Provider{Name: "primary"})
+	record := httptest.NewRecorder()
+	gCtx, _ := gin.CreateTestContext(record)
+	// When
+	controller.NewDriftController(sampler).GetDrift(gCtx)
+	// Then
+	assert.Equal(t, http.StatusOK, record.Code)
+	var report model.DriftReport
+	assert.NoError(t, json.Unmarshal(record.Body.Bytes(), &report))
+	assert.Equal(t, []string{"Melbourne"}, report.Cities)
+	assert.Equal(t, "primary", report.Providers[0].Provider)
+}
@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ColinSchofield/zai-weather/src/cache"
+	"github.com/ColinSchofield/zai-weather/src/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The Warning of a stale response, whose weather services failed to refresh it (see RFC 7234).
+const warningStale = `110 - "Response is Stale", 111 - "Revalidation Failed"`
+
+// The HTTP caching headers of a response (see RFC 9111), derived from its cache entry.
+type caching struct {
+	etag         string
+	lastModified time.Time
+}
+
+// Write the caching headers of the cache entry: its max age is what remains of its TTL (none once stale), the (weak)
+// ETag is over its data (and the variant, e.g. the format it is rendered in), and it was last modified when observed
+// (or otherwise fetched).
+func writeCaching(gCtx *gin.Context, entry cache.Entry, stale bool, variant string) caching {
+	weather := entry.Value.(*model.Weather)
+	result := caching{etag: etag(weather, variant), lastModified: fetchedAt(entry)}
+	if !weather.ObservedAt.IsZero() {
+		result.lastModified = weather.ObservedAt
+	}
+
+	header := gCtx.Writer.Header()
+	if stale {
+		header.Set("Cache-Control", "public, max-age=0, must-revalidate")
+		header.Set("Warning", warningStale)
+	} else {
+		remaining := max(entry.TTL-time.Since(entry.StoredAt), 0).Round(time.Second)
+		header.Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(remaining.Seconds())))
+	}
+	if result.etag != "" {
+		header.Set("ETag", result.etag)
+	}
+	header.Set("Last-Modified", result.lastModified.UTC().Format(http.TimeFormat))
+
+	return result
+}
+
+// Returns true should the caller's copy still be current, by its If-None-Match (or otherwise, its If-Modified-Since).
+func (c caching) notModified(req *http.Request) bool {
+	if req == nil {
+		return false
+	}
+	if match := req.Header.Get("If-None-Match"); match != "" {
+		for _, tag := range strings.Split(match, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/") // Compared weakly, as is allowed for a GET
+			if tag == "*" || (c.etag != "" && tag == strings.TrimPrefix(c.etag, "W/")) {
+				return true
+			}
+		}
+		return false
+	}
+
+	since, err := http.ParseTime(req.Header.Get("If-Modified-Since"))
+	return err == nil && !c.lastModified.Truncate(time.Second).After(since)
+}
+
+// Returns the weak ETag over the data of the weather information, suffixed by its variant (so that each of its
+// representations has its own ETag), or empty should it have no data. It is weak as the body also carries what changes
+// while the data does not (e.g. its age and message), so the bodies of the same ETag are equivalent, not identical.
+func etag(weather *model.Weather, variant string) string {
+	if weather.Data == nil {
+		return ""
+	}
+	content, err := json.Marshal(weather.Data)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(content)
+
+	tag := hex.EncodeToString(sum[:8])
+	if variant != "" {
+		tag += "-" + variant
+	}
+
+	return `W/"` + tag + `"`
+}
@@ -0,0 +1,128 @@
+package controller_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ColinSchofield/zai-weather/src/config"
+	"github.com/ColinSchofield/zai-weather/src/controller"
+	mock "github.com/ColinSchofield/zai-weather/src/mock"
+	"github.com/ColinSchofield/zai-weather/src/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+	"github.com/sony/gobreaker/v2"
+	"github.com/stretchr/testify/suite"
+)
+
+type HTTPCachingTestSuite struct {
+	suite.Suite
+
+	mockPrimary  *mock.MockWeatherFetcher
+	mockFailover *mock.MockWeatherFetcher
+	router       *gin.Engine
+}
+
+func TestHTTPCachingSuite(t *testing.T) {
+	suite.Run(t, new(HTTPCachingTestSuite))
+}
+
+func (s *HTTPCachingTestSuite) SetupTest() {
+	ctrl := gomock.NewController(s.T())
+	s.mockPrimary = mock.NewMockWeatherFetcher(ctrl)
+	s.mockFailover = mock.NewMockWeatherFetcher(ctrl)
+	cfg := &config.WeatherConfig{
+		CacheTTLSeconds: 1,
+		TTLConfig:       config.TTLConfig{TTLHonorUpstream: true, TTLMaxSeconds: 900, TTLPrimarySeconds: 60},
+	}
+	weatherController := controller.NewWeatherController(
+		cfg,
+		logrus.New(),
+		s.mockPrimary,
+		s.mockFailover,
+		gobreaker.NewCircuitBreaker[any](gobreaker.Settings{Name: "primary"}),
+		gobreaker.NewCircuitBreaker[any](gobreaker.Settings{Name: "failover"}),
+	)
+	s.router = gin.New()
+	s.router.Use(controller.Problems(logrus.New()))
+	s.router.GET("v1/weather", weatherController.GetWeather)
+}
+
+func (s *HTTPCachingTestSuite) request(header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/v1/weather?city=Melbourne", nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	record := httptest.NewRecorder()
+	s.router.ServeHTTP(record, req)
+
+	return record
+}
+
+func (s *HTTPCachingTestSuite) Test_HeadersOfALiveResponse() {
+	// Given
+	observed := time.Now().UTC().Add(-20 * time.Second).Truncate(time.Second)
+	weather := &model.Weather{Data: &model.Data{Temperature: 10, WindSpeed: 15}, ObservedAt: observed}
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(weather, nil)
+	// When
+	record := s.request(nil)
+	// Then
+	s.Assert().Equal(http.StatusOK, record.Code)
+	s.Assert().Regexp(`^public, max-age=(39|40|41)$`, record.Header().Get("Cache-Control"),
+		"Until the next observation of the primary")
+	s.Assert().Regexp(`^W/"[0-9a-f]{16}"$`, record.Header().Get("ETag"), "Weak, as the age of the body changes")
+	s.Assert().Equal(observed.Format(http.TimeFormat), record.Header().Get("Last-Modified"), "When observed")
+	s.Assert().Empty(record.Header().Get("Warning"))
+}
+
+func (s *HTTPCachingTestSuite) Test_NotModified() {
+	// Given
+	weather := &model.Weather{Data: &model.Data{Temperature: 10, WindSpeed: 15}}
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(weather, nil)
+	etag := s.request(nil).Header().Get("ETag")
+	// When
+	record := s.request(http.Header{"If-None-Match": {`"other", ` + etag}})
+	// Then
+	s.Assert().Equal(http.StatusNotModified, record.Code)
+	s.Assert().Empty(record.Body.String())
+	s.Assert().Equal(etag, record.Header().Get("ETag"))
+	s.Assert().Regexp(`^public, max-age=(59|60)$`, record.Header().Get("Cache-Control"), "What remains of the TTL")
+	// When
+	record = s.request(http.Header{"If-None-Match": {strings.TrimPrefix(etag, "W/")}})
+	// Then
+	s.Assert().Equal(http.StatusNotModified, record.Code, "Compared weakly")
+	// When
+	record = s.request(http.Header{"If-None-Match": {`"other"`}})
+	// Then
+	s.Assert().Equal(http.StatusOK, record.Code, "The ETag has changed")
+	// When
+	record = s.request(http.Header{"If-Modified-Since": {time.Now().UTC().Add(time.Minute).Format(http.TimeFormat)}})
+	// Then
+	s.Assert().Equal(http.StatusNotModified, record.Code)
+	// When
+	record = s.request(http.Header{"If-Modified-Since": {time.Now().UTC().Add(-time.Hour).Format(http.TimeFormat)}})
+	// Then
+	s.Assert().Equal(http.StatusOK, record.Code, "Modified since")
+}
+
+func (s *HTTPCachingTestSuite) Test_StaleFallbackIsMarked() {
+	// Given
+	weather := &model.Weather{Data: &model.Data{Temperature: 10, WindSpeed: 15}, MaxAge: time.Second}
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(weather, nil)
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(nil, errors.New("Server is down!"))
+	s.mockFailover.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(nil, errors.New("Server is down!"))
+	s.request(nil)
+	time.Sleep(1100 * time.Millisecond)
+	// When
+	record := s.request(nil)
+	// Then
+	s.Assert().Equal(http.StatusOK, record.Code)
+	s.Assert().Equal("public, max-age=0, must-revalidate", record.Header().Get("Cache-Control"))
+	s.Assert().Contains(record.Header().Get("Warning"), "110")
+	s.Assert().NotEmpty(record.Header().Get("ETag"))
+}
@@ -0,0 +1,150 @@
+package controller
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"regexp"
+
+	"github.com/ColinSchofield/zai-weather/src/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// The media type of the problems (see RFC 7807)
+	ContentTypeProblem = "application/problem+json"
+
+	// The header of the correlation ID, which is given back to the caller (and may be given by the caller)
+	HeaderCorrelationID = "X-Correlation-ID"
+	headerRequestID     = "X-Request-ID"
+	correlationIDKey    = "correlation_id"
+
+	// The types of the problems, relative to the service. Those of the weather services are named after the codes of
+	// the v2 API.
+	problemTypes             = "/problems/"
+	ProblemInvalidLocation   = problemTypes + "invalid-location"
+	ProblemNotFound          = problemTypes + "location-not-found"
+	ProblemUnavailable       = problemTypes + "upstream-unavailable"
+	ProblemRateLimited       = problemTypes + "upstream-rate-limited"
+	ProblemUnauthorized      = problemTypes + "upstream-unauthorized"
+	ProblemBadGateway        = problemTypes + "upstream-bad-response"
+	ProblemAdminDisabled     = problemTypes + "admin-disabled"
+	ProblemAdminUnauthorized = problemTypes + "admin-unauthorized"
+	ProblemBadRequest        = problemTypes + "bad-request"
+	ProblemNotAcceptable     = problemTypes + "not-acceptable"
+	ProblemResourceNotFound  = problemTypes + "not-found"
+	ProblemInternal          = problemTypes + "internal"
+)
+
+var problemTitles = map[string]string{
+	ProblemInvalidLocation:   "Invalid location",
+	ProblemNotFound:          "Location not found",
+	ProblemUnavailable:       "Weather services unavailable",
+	ProblemRateLimited:       "Weather services rate limited",
+	ProblemUnauthorized:      "Weather services unauthorized",
+	ProblemBadGateway:        "Weather services returned an invalid response",
+	ProblemAdminDisabled:     "Admin API disabled",
+	ProblemAdminUnauthorized: "Admin token required",
+	ProblemBadRequest:        "Bad request",
+	ProblemNotAcceptable:     "Not acceptable",
+	ProblemResourceNotFound:  "Not found",
+	ProblemInternal:          "Internal error",
+}
+
+// A correlation ID given by the caller is only reused should it be safe to log (and to echo back).
+var correlationIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// The ProblemError is attached to the gin context by a failed handler (see gCtx.Error), for the Problems middleware
+// to render.
+type ProblemError struct {
+	Problem model.Problem
+}
+
+func (e *ProblemError) Error() string {
+	return e.Problem.Title + ": " + e.Problem.Detail
+}
+
+// CorrelationID returns the middleware that gives each request a correlation ID: the one given by the caller (as an
+// X-Correlation-ID, or an X-Request-ID), otherwise a random one. It is returned to the caller as an X-Correlation-ID.
+func CorrelationID() gin.HandlerFunc {
+	return func(gCtx *gin.Context) {
+		id := gCtx.GetHeader(HeaderCorrelationID)
+		if !correlationIDPattern.MatchString(id) {
+			id = gCtx.GetHeader(headerRequestID)
+		}
+		if !correlationIDPattern.MatchString(id) {
+			id = newCorrelationID()
+		}
+		gCtx.Set(correlationIDKey, id)
+		gCtx.Header(HeaderCorrelationID, id)
+
+		gCtx.Next()
+	}
+}
+
+// Problems returns the middleware that renders the last error of a request as application/problem+json (unless a
+// response was already written). An error that is not a ProblemError is logged, and rendered as an internal error.
+func Problems(log *logrus.Logger) gin.HandlerFunc {
+	return func(gCtx *gin.Context) {
+		gCtx.Next()
+		if len(gCtx.Errors) == 0 || gCtx.Writer.Written() {
+			return
+		}
+
+		var problemErr *ProblemError
+		err := gCtx.Errors.Last().Err
+		if !errors.As(err, &problemErr) {
+			problemErr = &ProblemError{newProblem(ProblemInternal, http.StatusInternalServerError, "")}
+		}
+		problem := problemErr.Problem
+		if gCtx.Request != nil {
+			problem.Instance = gCtx.Request.URL.RequestURI()
+		}
+		problem.CorrelationID = correlationID(gCtx)
+		if problem.Status >= http.StatusInternalServerError {
+			log.WithError(err).WithField(correlationIDKey, problem.CorrelationID).Error("Failed to handle the request")
+		}
+
+		gCtx.Header("Content-Type", ContentTypeProblem)
+		gCtx.JSON(problem.Status, problem)
+	}
+}
+
+// RouteNotFound reports a request for an unknown route as a problem.
+func RouteNotFound(gCtx *gin.Context) {
+	abortWithProblem(gCtx, newProblem(ProblemResourceNotFound, http.StatusNotFound, "No such route"))
+}
+
+// Returns the problem of the type given, with its title.
+func newProblem(problemType string, status int, detail string) model.Problem {
+	return model.Problem{Type: problemType, Title: problemTitles[problemType], Status: status, Detail: detail}
+}
+
+// Attach the problem to the gin context (for the Problems middleware to render), skipping the remaining handlers.
+func abortWithProblem(gCtx *gin.Context, problem model.Problem) {
+	_ = gCtx.Error(&ProblemError{Problem: problem})
+	gCtx.Abort()
+}
+
+// Returns the correlation ID of the request, or a new one should the CorrelationID middleware not have given one.
+func correlationID(gCtx *gin.Context) string {
+	if id := gCtx.GetString(correlationIDKey); id != "" {
+		return id
+	}
+	id := newCorrelationID()
+	gCtx.Set(correlationIDKey, id)
+	gCtx.Header(HeaderCorrelationID, id)
+
+	return id
+}
+
+// Returns a random correlation ID.
+func newCorrelationID() string {
+	var id [16]byte
+	_, _ = rand.Read(id[:])
+
+	return hex.EncodeToString(id[:])
+}
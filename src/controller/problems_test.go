@@ -0,0 +1,184 @@
+package controller_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ColinSchofield/zai-weather/src/config"
+	"github.com/ColinSchofield/zai-weather/src/controller"
+	mock "github.com/ColinSchofield/zai-weather/src/mock"
+	"github.com/ColinSchofield/zai-weather/src/model"
+	"github.com/ColinSchofield/zai-weather/src/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+	"github.com/sony/gobreaker/v2"
+	"github.com/stretchr/testify/suite"
+)
+
+type ProblemsTestSuite struct {
+	suite.Suite
+
+	mockPrimary  *mock.MockWeatherFetcher
+	mockFailover *mock.MockWeatherFetcher
+	router       *gin.Engine
+}
+
+func TestProblemsSuite(t *testing.T) {
+	suite.Run(t, new(ProblemsTestSuite))
+}
+
+func (s *ProblemsTestSuite) SetupTest() {
+	ctrl := gomock.NewController(s.T())
+	s.mockPrimary = mock.NewMockWeatherFetcher(ctrl)
+	s.mockFailover = mock.NewMockWeatherFetcher(ctrl)
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	cfg := &config.WeatherConfig{CacheTTLSeconds: 1}
+	weatherController := controller.NewWeatherController(
+		cfg,
+		log,
+		s.mockPrimary,
+		s.mockFailover,
+		gobreaker.NewCircuitBreaker[any](gobreaker.Settings{Name: "primary"}),
+		gobreaker.NewCircuitBreaker[any](gobreaker.Settings{Name: "failover"}),
+	)
+	s.router = gin.New()
+	s.router.Use(controller.CorrelationID(), controller.Problems(log))
+	s.router.NoRoute(controller.RouteNotFound)
+	s.router.GET("v1/weather", weatherController.GetWeather)
+	s.router.GET("v2/weather", weatherController.GetWeatherV2)
+	s.router.GET("fail", func(gCtx *gin.Context) { _ = gCtx.Error(errors.New("Unexpected!")) })
+	s.router.GET("admin", controller.AdminAuth(cfg, log), func(gCtx *gin.Context) { gCtx.Status(http.StatusOK) })
+}
+
+func (s *ProblemsTestSuite) request(path string, header http.Header) (*httptest.ResponseRecorder, model.Problem) {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	record := httptest.NewRecorder()
+	s.router.ServeHTTP(record, req)
+	var problem model.Problem
+	s.Require().NoError(json.Unmarshal(record.Body.Bytes(), &problem))
+
+	return record, problem
+}
+
+func (s *ProblemsTestSuite) Test_CorrelationID() {
+	// When
+	record, problem := s.request("/v1/weather?city=Sydney%21", nil)
+	// Then
+	s.Assert().Equal(http.StatusBadRequest, record.Code)
+	s.Assert().Equal(controller.ContentTypeProblem, record.Header().Get("Content-Type"))
+	s.Assert().Regexp(`^[0-9a-f]{32}$`, problem.CorrelationID, "A random correlation ID")
+	s.Assert().Equal(problem.CorrelationID, record.Header().Get(controller.HeaderCorrelationID))
+	// When
+	record, problem = s.request("/v1/weather?city=Sydney%21", http.Header{"X-Correlation-Id": {"abc-123"}})
+	// Then
+	s.Assert().Equal("abc-123", problem.CorrelationID, "The caller's correlation ID")
+	s.Assert().Equal("abc-123", record.Header().Get(controller.HeaderCorrelationID))
+	// When
+	_, problem = s.request("/v1/weather?city=Sydney%21", http.Header{"X-Request-Id": {"req.42"}})
+	// Then
+	s.Assert().Equal("req.42", problem.CorrelationID, "The caller's request ID")
+	// When
+	_, problem = s.request("/v1/weather?city=Sydney%21", http.Header{"X-Correlation-Id": {"<script>"}})
+	// Then
+	s.Assert().Regexp(`^[0-9a-f]{32}$`, problem.CorrelationID, "Unsafe correlation IDs are replaced")
+}
+
+func (s *ProblemsTestSuite) Test_InvalidLocation() {
+	for _, path := range []string{
+		"/v1/weather?city=",
+		"/v1/weather?city=%20Sydney",
+		"/v1/weather?city=Sydney%3Bdrop",
+		"/v1/weather?city=" + strings.Repeat("a", 101),
+	} {
+		// When
+		record, problem := s.request(path, nil)
+		// Then
+		s.Assert().Equal(http.StatusBadRequest, record.Code, path)
+		s.Assert().Equal(controller.ProblemInvalidLocation, problem.Type, path)
+		s.Assert().Equal("Invalid location", problem.Title, path)
+		s.Assert().Equal(controller.MessageInvalid, problem.Message, path)
+		s.Assert().Equal(path, problem.Instance, path)
+	}
+	// Given
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Kalgoorlie-Boulder").
+		Return(&model.Weather{Data: &model.Data{Temperature: 30}}, nil)
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "St. Kilda, O'Connor").
+		Return(&model.Weather{Data: &model.Data{Temperature: 20}}, nil)
+	// When
+	record := httptest.NewRecorder()
+	s.router.ServeHTTP(record, httptest.NewRequest(http.MethodGet, "/v1/weather?city=Kalgoorlie-Boulder", nil))
+	// Then
+	s.Assert().Equal(http.StatusOK, record.Code)
+	// When
+	record = httptest.NewRecorder()
+	s.router.ServeHTTP(record, httptest.NewRequest(http.MethodGet, "/v1/weather?city=St.+Kilda%2C+O%27Connor", nil))
+	// Then
+	s.Assert().Equal(http.StatusOK, record.Code)
+}
+
+func (s *ProblemsTestSuite) Test_UpstreamFailures() {
+	// Given
+	unauthorized := &service.FetchError{Kind: service.KindUnauthorized, Err: errors.New("Invalid key!")}
+	rateLimited := &service.FetchError{Kind: service.KindRateLimited, RetryAfter: 5 * time.Second, Err: errors.New("429")}
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Perth").Return(nil, unauthorized)
+	s.mockFailover.EXPECT().FetchWeather(gomock.Any(), "Perth").Return(nil, errors.New("Malformed!"))
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Hobart").Return(nil, unauthorized)
+	s.mockFailover.EXPECT().FetchWeather(gomock.Any(), "Hobart").Return(nil, rateLimited)
+	// When
+	record, problem := s.request("/v2/weather?city=Perth", nil)
+	// Then
+	s.Assert().Equal(http.StatusServiceUnavailable, record.Code, "Unavailable, over unauthorized")
+	s.Assert().Equal(controller.ProblemUnavailable, problem.Type)
+	// When
+	record, problem = s.request("/v2/weather?city=Hobart", nil)
+	// Then
+	s.Assert().Equal(http.StatusServiceUnavailable, record.Code)
+	s.Assert().Equal(controller.ProblemRateLimited, problem.Type, "Rate limited, over unauthorized")
+	s.Assert().Equal(controller.CodeRateLimited, problem.Error.Code)
+	s.Assert().Equal("5", record.Header().Get("Retry-After"))
+}
+
+func (s *ProblemsTestSuite) Test_UpstreamUnauthorized() {
+	// Given
+	unauthorized := &service.FetchError{Kind: service.KindUnauthorized, Err: errors.New("Invalid key!")}
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Perth").Return(nil, unauthorized)
+	s.mockFailover.EXPECT().FetchWeather(gomock.Any(), "Perth").Return(nil, unauthorized)
+	// When
+	record, problem := s.request("/v1/weather?city=Perth", nil)
+	// Then
+	s.Assert().Equal(http.StatusBadGateway, record.Code)
+	s.Assert().Equal(controller.ProblemUnauthorized, problem.Type)
+	s.Assert().Equal(controller.MessageBadGateway, problem.Message)
+}
+
+func (s *ProblemsTestSuite) Test_OtherErrors() {
+	// When
+	record, problem := s.request("/fail", nil)
+	// Then
+	s.Assert().Equal(http.StatusInternalServerError, record.Code)
+	s.Assert().Equal(controller.ProblemInternal, problem.Type)
+	s.Assert().Empty(problem.Detail, "Without the details of the error")
+	// When
+	record, problem = s.request("/nowhere", nil)
+	// Then
+	s.Assert().Equal(http.StatusNotFound, record.Code)
+	s.Assert().Equal(controller.ProblemResourceNotFound, problem.Type)
+	// When
+	record, problem = s.request("/admin", nil)
+	// Then
+	s.Assert().Equal(http.StatusForbidden, record.Code)
+	s.Assert().Equal(controller.ProblemAdminDisabled, problem.Type)
+	s.Assert().NotEmpty(problem.CorrelationID)
+}
@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/ColinSchofield/zai-weather/src/keys"
+	"github.com/ColinSchofield/zai-weather/src/model"
+	"github.com/ColinSchofield/zai-weather/src/quota"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The StatusController interface reports on the status of the service.
+type StatusController interface {
+	GetStatus(gCtx *gin.Context)
+	GetKeys(gCtx *gin.Context)
+}
+
+type DefaultStatusController struct {
+	trackers []quota.Tracker
+	rings    []keys.Ring
+}
+
+var _ StatusController = (*DefaultStatusController)(nil)
+
+// NewStatusController returns the default struct for the status controller.
+func NewStatusController(trackers []quota.Tracker, rings []keys.Ring) *DefaultStatusController {
+	return &DefaultStatusController{
+		trackers: trackers,
+		rings:    rings,
+	}
+}
+
+// GetStatus returns a JSON value containing the remaining budget of each weather service.
+func (s *DefaultStatusController) GetStatus(gCtx *gin.Context) {
+	status := model.Status{
+		Status: "ok",
+		Quotas: []model.QuotaStatus{},
+	}
+	for _, tracker := range s.trackers {
+		status.Quotas = append(status.Quotas, tracker.Status())
+	}
+
+	gCtx.JSON(http.StatusOK, status)
+}
+
+// GetKeys returns a JSON value containing the health and usage of the access keys (which are masked) of each weather
+// service. These are only given to the admin API.
+func (s *DefaultStatusController) GetKeys(gCtx *gin.Context) {
+	report := model.KeyReport{Keys: []model.KeyStatus{}}
+	for _, ring := range s.rings {
+		report.Keys = append(report.Keys, ring.Status()...)
+	}
+
+	gCtx.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,52 @@
+package controller_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ColinSchofield/zai-weather/src/controller"
+	"github.com/ColinSchofield/zai-weather/src/keys"
+	"github.com/ColinSchofield/zai-weather/src/model"
+	"github.com/ColinSchofield/zai-weather/src/quota"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GetStatus(t *testing.T) {
+	// Given
+	store := quota.NewFileStore(logrus.New(), "")
+	tracker := quota.NewTracker(logrus.New(), store, "primary", 10, 100, 1, 0)
+	tracker.Record()
+	ring := keys.NewRing("primary", keys.Failover, "1cadfad44c3387c66d14a12cb33f282e")
+	record := httptest.NewRecorder()
+	gCtx, _ := gin.CreateTestContext(record)
+	// When
+	controller.NewStatusController([]quota.Tracker{tracker}, []keys.Ring{ring}).GetStatus(gCtx)
+	// Then
+	assert.Equal(t, http.StatusOK, record.Code)
+	var status model.Status
+	assert.NoError(t, json.Unmarshal(record.Body.Bytes(), &status))
+	assert.Equal(t, "primary", status.Quotas[0].Provider)
+	assert.Equal(t, 9, status.Quotas[0].DailyRemaining)
+	assert.Equal(t, 99, status.Quotas[0].MonthlyRemaining)
+	assert.NotContains(t, record.Body.String(), "282e", "the access keys are only given to the admin API")
+}
+
+func Test_GetKeys(t *testing.T) {
+	// Given
+	ring := keys.NewRing("primary", keys.Failover, "1cadfad44c3387c66d14a12cb33f282e")
+	record := httptest.NewRecorder()
+	gCtx, _ := gin.CreateTestContext(record)
+	// When
+	controller.NewStatusController(nil, []keys.Ring{ring}).GetKeys(gCtx)
+	// Then
+	assert.Equal(t, http.StatusOK, record.Code)
+	var report model.KeyReport
+	assert.NoError(t, json.Unmarshal(record.Body.Bytes(), &report))
+	assert.Equal(t, "****************************282e", report.Keys[0].Key, "the access key is masked")
+	assert.True(t, report.Keys[0].Healthy)
+}
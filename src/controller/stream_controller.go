@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/ColinSchofield/zai-weather/src/config"
+	"github.com/ColinSchofield/zai-weather/src/model"
+	"github.com/ColinSchofield/zai-weather/src/stream"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// The events of the stream: the weather information, or the problem should it not be found
+	EventWeather = "weather"
+	EventProblem = "problem"
+
+	// The heartbeat is a comment (which the event source of a browser ignores), keeping idle connections open
+	heartbeat = ": heartbeat\n\n"
+)
+
+// The StreamController interface streams the updates of the weather information as Server-Sent Events.
+type StreamController interface {
+	GetWeatherStream(gCtx *gin.Context)
+}
+
+type DefaultStreamController struct {
+	cfg config.Source
+	hub stream.Hub
+}
+
+var _ StreamController = (*DefaultStreamController)(nil)
+
+// NewStreamController returns the default struct for the stream controller.
+func NewStreamController(cfg config.Source, hub stream.Hub) *DefaultStreamController {
+	return &DefaultStreamController{
+		cfg: cfg,
+		hub: hub,
+	}
+}
+
+// GetWeatherStream subscribes the caller to the weather information of the city, sending an event whenever it changes
+// (starting with its current value), and a heartbeat at the interval, until the caller disconnects.
+func (s *DefaultStreamController) GetWeatherStream(gCtx *gin.Context) {
+	location := gCtx.DefaultQuery("city", config.DefaultLocation)
+	if !validLocation(location) {
+		abortWithProblem(gCtx, invalidLocation(location).problemV1())
+		return
+	}
+
+	updates, cancel := s.hub.Subscribe(location)
+	defer cancel()
+	ticker := time.NewTicker(time.Duration(max(s.cfg.Current().StreamHeartbeatSeconds, 1)) * time.Second)
+	defer ticker.Stop()
+
+	gCtx.Header("Cache-Control", "no-cache")
+	gCtx.Header("X-Accel-Buffering", "no") // Nor buffered by a proxy
+	gCtx.Stream(func(w io.Writer) bool {
+		select {
+		case <-gCtx.Request.Context().Done():
+			return false
+		case update, open := <-updates:
+			if !open {
+				return false
+			}
+			if update.Err != nil {
+				gCtx.SSEvent(EventProblem, s.problem(gCtx, update.Err))
+			} else {
+				gCtx.SSEvent(EventWeather, update.Weather)
+			}
+			return true
+		case <-ticker.C:
+			_, err := io.WriteString(w, heartbeat)
+			return err == nil
+		}
+	})
+}
+
+// Returns the problem of the update that failed, as of the request.
+func (s *DefaultStreamController) problem(gCtx *gin.Context, err error) model.Problem {
+	problem := newProblem(ProblemInternal, http.StatusInternalServerError, "")
+	var problemErr *ProblemError
+	if errors.As(err, &problemErr) {
+		problem = problemErr.Problem
+	}
+	problem.Instance = gCtx.Request.URL.RequestURI()
+	problem.CorrelationID = correlationID(gCtx)
+
+	return problem
+}
@@ -0,0 +1,150 @@
+package controller_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ColinSchofield/zai-weather/src/config"
+	"github.com/ColinSchofield/zai-weather/src/controller"
+	"github.com/ColinSchofield/zai-weather/src/metrics"
+	mock "github.com/ColinSchofield/zai-weather/src/mock"
+	"github.com/ColinSchofield/zai-weather/src/model"
+	"github.com/ColinSchofield/zai-weather/src/service"
+	"github.com/ColinSchofield/zai-weather/src/stream"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+	"github.com/sony/gobreaker/v2"
+	"github.com/stretchr/testify/suite"
+)
+
+type StreamControllerTestSuite struct {
+	suite.Suite
+
+	mockPrimary  *mock.MockWeatherFetcher
+	mockFailover *mock.MockWeatherFetcher
+	hub          *stream.DefaultHub
+	server       *httptest.Server
+}
+
+func TestStreamControllerSuite(t *testing.T) {
+	suite.Run(t, new(StreamControllerTestSuite))
+}
+
+func (s *StreamControllerTestSuite) SetupTest() {
+	ctrl := gomock.NewController(s.T())
+	s.mockPrimary = mock.NewMockWeatherFetcher(ctrl)
+	s.mockFailover = mock.NewMockWeatherFetcher(ctrl)
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	cfg := &config.WeatherConfig{
+		CacheTTLSeconds: 60,
+		StreamConfig:    config.StreamConfig{StreamRefreshSeconds: 1, StreamHeartbeatSeconds: 1},
+	}
+	weatherController := controller.NewWeatherController(
+		cfg,
+		log,
+		s.mockPrimary,
+		s.mockFailover,
+		gobreaker.NewCircuitBreaker[any](gobreaker.Settings{Name: "primary"}),
+		gobreaker.NewCircuitBreaker[any](gobreaker.Settings{Name: "failover"}),
+	)
+	s.hub = stream.NewHub(cfg, log, weatherController)
+	router := gin.New()
+	router.Use(controller.CorrelationID(), controller.Problems(log))
+	router.GET("v1/weather/stream", controller.NewStreamController(cfg, s.hub).GetWeatherStream)
+	s.server = httptest.NewServer(router)
+}
+
+func (s *StreamControllerTestSuite) TearDownTest() {
+	s.hub.Close()
+	s.server.Close()
+}
+
+// Returns the next event of the stream (its name and data), or the heartbeat (as the name).
+func (s *StreamControllerTestSuite) next(reader *bufio.Reader) (string, string) {
+	var name, data string
+	for {
+		line, err := reader.ReadString('\n')
+		s.Require().NoError(err)
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && (name != "" || data != ""):
+			return name, data
+		case strings.HasPrefix(line, ": "):
+			name = strings.TrimPrefix(line, ": ")
+		case strings.HasPrefix(line, "event:"):
+			name = strings.TrimPrefix(line, "event:")
+		case strings.HasPrefix(line, "data:"):
+			data = strings.TrimPrefix(line, "data:")
+		}
+	}
+}
+
+func (s *StreamControllerTestSuite) Test_StreamsTheWeather() {
+	// Given
+	weather := &model.Weather{Data: &model.Data{Temperature: 10, WindSpeed: 15}}
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Sydney").Return(weather, nil)
+	// When
+	resp, err := http.Get(s.server.URL + "/v1/weather/stream?city=Sydney")
+	s.Require().NoError(err)
+	reader := bufio.NewReader(resp.Body)
+	// Then
+	s.Assert().Equal(http.StatusOK, resp.StatusCode)
+	s.Assert().Equal("text/event-stream", resp.Header.Get("Content-Type"))
+	s.Assert().Equal("no-cache", resp.Header.Get("Cache-Control"))
+	name, data := s.next(reader)
+	s.Assert().Equal(controller.EventWeather, name)
+	var fromEvent model.Weather
+	s.Require().NoError(json.Unmarshal([]byte(data), &fromEvent))
+	s.Assert().Equal(model.Data{Temperature: 10, WindSpeed: 15}, *fromEvent.Data)
+	s.Assert().Equal(controller.MessageSuccess, fromEvent.Message)
+	// When
+	name, _ = s.next(reader)
+	// Then
+	s.Assert().Equal("heartbeat", name, "Unchanged (as it is within its TTL), so only the heartbeat")
+	s.Assert().Equal(1.0, testutil.ToFloat64(metrics.StreamSubscribers))
+	// When
+	s.Require().NoError(resp.Body.Close())
+	// Then
+	s.Assert().Eventually(func() bool {
+		return testutil.ToFloat64(metrics.StreamSubscribers) == 0
+	}, 3*time.Second, 50*time.Millisecond, "Unsubscribed, once disconnected")
+}
+
+func (s *StreamControllerTestSuite) Test_StreamsTheProblem() {
+	// Given
+	notFound := &service.FetchError{Kind: service.KindNotFound, Err: io.EOF}
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Nowhere").Return(nil, notFound)
+	s.mockFailover.EXPECT().FetchWeather(gomock.Any(), "Nowhere").Return(nil, notFound)
+	// When
+	resp, err := http.Get(s.server.URL + "/v1/weather/stream?city=Nowhere")
+	s.Require().NoError(err)
+	defer resp.Body.Close()
+	// Then
+	name, data := s.next(bufio.NewReader(resp.Body))
+	s.Assert().Equal(controller.EventProblem, name)
+	var problem model.Problem
+	s.Require().NoError(json.Unmarshal([]byte(data), &problem))
+	s.Assert().Equal(controller.ProblemNotFound, problem.Type)
+	s.Assert().Equal("/v1/weather/stream?city=Nowhere", problem.Instance)
+	s.Assert().Equal(resp.Header.Get(controller.HeaderCorrelationID), problem.CorrelationID)
+}
+
+func (s *StreamControllerTestSuite) Test_InvalidLocation() {
+	// When
+	resp, err := http.Get(s.server.URL + "/v1/weather/stream?city=%3Cscript%3E")
+	s.Require().NoError(err)
+	defer resp.Body.Close()
+	// Then
+	s.Assert().Equal(http.StatusBadRequest, resp.StatusCode)
+	s.Assert().Equal(controller.ContentTypeProblem, resp.Header.Get("Content-Type"))
+}
@@ -3,17 +3,28 @@ package controller
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
 	"time"
+	"unicode/utf8"
 
+	"github.com/ColinSchofield/zai-weather/src/blend"
+	"github.com/ColinSchofield/zai-weather/src/breaker"
 	"github.com/ColinSchofield/zai-weather/src/cache"
 	"github.com/ColinSchofield/zai-weather/src/config"
+	"github.com/ColinSchofield/zai-weather/src/latency"
+	"github.com/ColinSchofield/zai-weather/src/metrics"
 	"github.com/ColinSchofield/zai-weather/src/model"
+	"github.com/ColinSchofield/zai-weather/src/quota"
 	"github.com/ColinSchofield/zai-weather/src/service"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/render"
 	"github.com/sirupsen/logrus"
-	"github.com/sony/gobreaker"
 )
 
 const (
@@ -22,34 +33,62 @@ const (
 	MessageSuccessCache = "Request successful (cached)"
 	MessageFailureCache = "Request failure (cache is stale)"
 	MessageFailure      = "Location could not be found"
+	MessageInvalid      = "Location is invalid"
+	MessageUnavailable  = "Weather services are unavailable"
+	MessageBadGateway   = "Weather services returned an invalid response"
+
+	// The longest location (in characters) that is accepted
+	maxLocationLength = 100
+
+	// The Retry-After given to the caller, when the weather services did not say how long to wait for
+	defaultRetryAfter = 30 * time.Second
+	// The longest Retry-After given to the caller (e.g. rather than the weeks until a budget is renewed)
+	maxRetryAfter = time.Hour
+
+	// The fetch strategy that queries all the weather services concurrently, blending their readings
+	StrategyBlend = "blend"
+	// The source of the blended weather information (within the cache)
+	sourceBlend = "blend"
+
+	// The latencies (of each weather service) used to derive the hedging delay
+	latencySamples    = 100
+	latencyMinSamples = 10
 )
 
+// A location starts with a letter or digit, followed by letters, digits, spaces and the punctuation of place names.
+var locationPattern = regexp.MustCompile(`^[\p{L}\p{N}][\p{L}\p{M}\p{N} .,'-]*$`)
+
 // The WeatherController interface provides access to the current weather conditions.
 type WeatherController interface {
 	GetWeather(gCtx *gin.Context)
+	GetWeatherV2(gCtx *gin.Context)
 }
 
 type DefaultWeatherController struct {
-	cfg        *config.WeatherConfig
+	cfg        config.Source
 	log        *logrus.Logger
 	primary    service.WeatherFetcher
 	failover   service.WeatherFetcher
-	cbPrimary  *gobreaker.CircuitBreaker
-	cbFailover *gobreaker.CircuitBreaker
+	cbPrimary  breaker.CircuitBreaker
+	cbFailover breaker.CircuitBreaker
 
-	weatherCache *cache.DefaultWeatherCache
+	weatherCache    *cache.DefaultWeatherCache
+	notFoundCache   *cache.DefaultNegativeCache
+	ttlPolicy       cache.TTLPolicy
+	primaryLatency  latency.Tracker
+	failoverLatency latency.Tracker
 }
 
 var _ WeatherController = (*DefaultWeatherController)(nil)
 
 // NewWeatherController returns the default struct for the weather controller.
 func NewWeatherController(
-	cfg *config.WeatherConfig,
+	cfg config.Source,
 	log *logrus.Logger,
 	primary service.WeatherFetcher,
 	failover service.WeatherFetcher,
-	cbPrimary *gobreaker.CircuitBreaker,
-	cbFailover *gobreaker.CircuitBreaker,
+	cbPrimary breaker.CircuitBreaker,
+	cbFailover breaker.CircuitBreaker,
 ) *DefaultWeatherController {
 	return &DefaultWeatherController{
 		cfg:        cfg,
@@ -59,76 +98,554 @@ func NewWeatherController(
 		cbPrimary:  cbPrimary,
 		cbFailover: cbFailover,
 
-		weatherCache: cache.NewWeatherCache(time.Duration(cfg.CacheTTLSeconds) * time.Second),
+		weatherCache:    cache.NewWeatherCache(time.Duration(cfg.Current().CacheTTLSeconds) * time.Second),
+		notFoundCache:   cache.NewNegativeCache(time.Duration(cfg.Current().NegativeCacheTTLSeconds) * time.Second),
+		ttlPolicy:       cache.NewTTLPolicy(cfg),
+		primaryLatency:  latency.NewTracker(latencySamples, latencyMinSamples),
+		failoverLatency: latency.NewTracker(latencySamples, latencyMinSamples),
 	}
 }
 
+// Reconfigure applies the TTLs of the caches, once the configuration is reloaded.
+func (w *DefaultWeatherController) Reconfigure(cfg *config.WeatherConfig) {
+	w.weatherCache.SetTTL(time.Duration(cfg.CacheTTLSeconds) * time.Second)
+	w.notFoundCache.SetTTL(time.Duration(cfg.NegativeCacheTTLSeconds) * time.Second)
+}
+
 // GetWeather returns a JSON value containing the temperature (in degrees celsius) and the wind speed (in km/hr).
-// TODO Add Prometheus metrics for monitoring and observability.
 //
 // The handling of the primary and fail-over 3rd party servers, is done using the circuit breaker design pattern.
 //
 // See https://en.wikipedia.org/wiki/Circuit_breaker_design_pattern.
 func (w *DefaultWeatherController) GetWeather(gCtx *gin.Context) {
-	location := gCtx.DefaultQuery("city", "Melbourne")
-
-	// Load the weather information, if possible, from the cache.
-	if cached, found := w.weatherCache.Get(location); found {
-		weather := cached.(*model.Weather)
-		weather.Message = MessageSuccessCache
-		gCtx.JSON(http.StatusOK, weather)
+	format, acceptable := negotiate(gCtx)
+	gCtx.Header("Vary", "Accept")
+	if !acceptable {
+		notAcceptable(gCtx)
 		return
 	}
 
-	// Fetch from primary service.
-	if ok := w.fetchWeather(gCtx, w.cfg.PrimaryTimeoutSeconds, w.cbPrimary, location, w.primary); ok {
+	res := w.resolve(gCtx.DefaultQuery("city", config.DefaultLocation))
+	if res.failed() {
+		respondFailure(gCtx, res, res.problemV1())
 		return
 	}
 
-	// Fetch from fail-over service.
-	if ok := w.fetchWeather(gCtx, w.cfg.FailoverTimeoutSeconds, w.cbFailover, location, w.failover); ok {
-		return
+	weather := res.weatherV1()
+	variant := format
+	if format == FormatJSON {
+		variant = "" // The ETag of JSON predates the other formats
 	}
+	respondEntry(gCtx, res.entry, res.source == SourceStale, variant, renderWeather(format, res.location, weather))
+}
 
-	// Fallback to cached values.
-	if fallback, found := w.weatherCache.GetIgnoreTTL(location); found {
-		weather := fallback.(*model.Weather)
-		weather.Message = MessageFailureCache
-		gCtx.JSON(http.StatusOK, fallback)
-		return
+// Current returns the weather information of the location as the v1 API would (i.e. through the cache), or should
+// it not be found, a ProblemError.
+func (w *DefaultWeatherController) Current(location string) (model.Weather, error) {
+	res := w.resolve(location)
+	if res.failed() {
+		return model.Weather{}, &ProblemError{Problem: res.problemV1()}
+	}
+
+	return res.weatherV1(), nil
+}
+
+// Resolve the weather information of the (valid) location: from the cache, otherwise through the weather services,
+// and should these fail, from the (stale) cache. The failure is reported, should none of these succeed.
+func (w *DefaultWeatherController) resolve(location string) result {
+	if !validLocation(location) {
+		return invalidLocation(location)
+	}
+
+	// Load the weather information, if possible, from the cache.
+	if entry, found := w.weatherCache.Lookup(location); found && entry.Fresh {
+		return result{location: location, source: SourceCache, entry: entry}
+	}
+
+	// Answer locally, if the location was recently found to be invalid.
+	if w.notFoundCache.Contains(location) {
+		metrics.NegativeCacheHits.Inc()
+		return notFound(location)
+	}
+
+	weather, source, errs := w.fetchChain(context.Background(), location)
+	if weather != nil {
+		return result{location: location, source: SourceLive, entry: w.store(location, source, weather)}
+	}
+
+	// Fallback to cached values (unless these are too stale).
+	if entry, found := w.stale(location); found {
+		return result{location: location, source: SourceStale, entry: entry}
+	}
+
+	return w.failure(location, errs)
+}
+
+// Cache returns the cache of the weather information (i.e. for its administration).
+func (w *DefaultWeatherController) Cache() cache.Weather {
+	return w.weatherCache
+}
+
+// NotFoundCache returns the cache of the locations that could not be found (i.e. for its administration).
+func (w *DefaultWeatherController) NotFoundCache() cache.Negative {
+	return w.notFoundCache
+}
+
+// Refresh fetches the weather information of the location through the weather services (bypassing the caches),
+// caching it should it be found. A location is only reported as not found, should every weather service that
+// answered say so.
+func (w *DefaultWeatherController) Refresh(ctx context.Context, location string) (*model.Weather, error) {
+	weather, source, errs := w.fetchChain(ctx, location)
+	if weather != nil {
+		w.store(location, source, weather)
+		return weather, nil
 	}
 
-	// Assume that the location is invalid.
-	weather := model.Weather{
-		Status:  http.StatusNotFound,
-		Message: MessageFailure,
+	if err := locationNotFound(errs); err != nil {
+		w.notFoundCache.Add(location)
+		return nil, err
 	}
-	gCtx.JSON(http.StatusNotFound, weather)
+	// The weather services failed (i.e. it is not that the location could not be found)
+	var failures []error
+	for _, err := range errs {
+		if !service.IsNotFound(err) {
+			failures = append(failures, err)
+		}
+	}
+	return nil, errors.Join(failures...)
+}
+
+// Fetch the weather information using the configured strategy, returning it along with its source (i.e. the weather
+// service, or the blend), or the errors of each weather service should none succeed.
+func (w *DefaultWeatherController) fetchChain(ctx context.Context, location string) (*model.Weather, string, []error) {
+	if w.cfg.Current().FetchStrategy == StrategyBlend {
+		// Fetch from all the weather services concurrently, blending their readings.
+		weather, errs := w.blendWeather(ctx, location)
+		return weather, sourceBlend, errs
+	} else if w.cfg.Current().HedgingEnabled {
+		// Fetch from the first service, hedging with the second service should the first be slow.
+		return w.hedgeWeather(ctx, location)
+	}
+
+	// Fetch from each service in the provider order (the primary, then the fail-over, by default).
+	var errs []error
+	for _, s := range w.services() {
+		weather, err := w.fetch(ctx, s.timeout, s.cb, location, s.fetcher)
+		if err == nil {
+			return weather, s.cb.Name(), nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, "", errs
+}
+
+// Fetch the weather information from the first service in the provider order. Should it not have answered within the
+// hedging delay (or have failed), the second service is fired in parallel. The first successful answer wins and the
+// loser is cancelled.
+func (w *DefaultWeatherController) hedgeWeather(
+	ctx context.Context,
+	location string,
+) (*model.Weather, string, []error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	services := w.services()
+	first, second := services[0], services[1]
+	type result struct {
+		weather *model.Weather
+		source  string
+		err     error
+	}
+	results := make(chan result, 2)
+	go func() {
+		start := time.Now()
+		weather, err := w.fetch(ctx, first.timeout, first.cb, location, first.fetcher)
+		// A fetch cancelled by the winning hedge is sampled too (its latency is at least this long), otherwise only
+		// the fast answers would be sampled, lowering the delay until every request is hedged.
+		if err == nil || errors.Is(err, context.Canceled) {
+			first.latency.Record(time.Since(start))
+		}
+		results <- result{weather, first.cb.Name(), err}
+	}()
+
+	hedging := false
+	hedge := func() {
+		hedging = true
+		go func() {
+			weather, err := w.fetch(ctx, second.timeout, second.cb, location, second.fetcher)
+			results <- result{weather, second.cb.Name(), err}
+		}()
+	}
+
+	delay := time.NewTimer(w.hedgeDelay(first.latency))
+	defer delay.Stop()
+
+	var errs []error
+	for pending := 1; pending > 0; {
+		select {
+		case <-delay.C:
+			if !hedging {
+				w.log.WithField("location", location).Info("Hedging the slow ", first.cb.Name())
+				hedge()
+				pending++
+			}
+		case res := <-results:
+			pending--
+			if res.err == nil {
+				return res.weather, res.source, nil
+			}
+			errs = append(errs, res.err)
+			if !hedging {
+				hedge()
+				pending++
+			}
+		}
+	}
+
+	return nil, "", errs
+}
+
+// Fetch the weather information from all the weather services concurrently (skipping those with an open circuit
+// breaker), and blend their readings using the configured method. The first in the provider order is the one kept by
+// the outlier method (should it have answered).
+func (w *DefaultWeatherController) blendWeather(ctx context.Context, location string) (*model.Weather, []error) {
+	cfg := w.cfg.Current()
+	providers := w.services()
+
+	weathers := make([]*model.Weather, len(providers))
+	errs := make([]error, len(providers))
+	var wg sync.WaitGroup
+	for i, p := range providers {
+		wg.Add(1)
+		go func(i int, p weatherService) {
+			defer wg.Done()
+			weathers[i], errs[i] = w.fetch(ctx, p.timeout, p.cb, location, p.fetcher)
+		}(i, p)
+	}
+	wg.Wait()
+
+	var readings []blend.Reading
+	var failures []error
+	var observedAt, fetchedAt time.Time
+	for i, p := range providers {
+		if errs[i] != nil {
+			failures = append(failures, errs[i])
+			continue
+		}
+		values := weathers[i].Values
+		if values == nil {
+			values = &model.Values{
+				Temperature: float64(weathers[i].Data.Temperature), WindSpeed: float64(weathers[i].Data.WindSpeed),
+			}
+		}
+		readings = append(readings, blend.Reading{
+			Reading: model.Reading{Provider: p.cb.Name(), Values: *values},
+			Trust:   p.trust,
+		})
+		// The blend is only as recent as its oldest observation
+		observed := weathers[i].ObservedAt
+		if !observed.IsZero() && (observedAt.IsZero() || observed.Before(observedAt)) {
+			observedAt = observed
+		}
+		if weathers[i].FetchedAt.After(fetchedAt) {
+			fetchedAt = weathers[i].FetchedAt
+		}
+	}
+	if len(readings) == 0 {
+		return nil, failures
+	}
+
+	blender := blend.Blender{
+		Method:         blend.Method(cfg.BlendMethod),
+		Reference:      providers[0].cb.Name(),
+		OutlierDegrees: cfg.BlendOutlierDegrees,
+	}
+	weather, err := blender.Blend(readings)
+	if err != nil {
+		w.log.WithError(err).WithField("location", location).Error("Failed to blend the readings")
+		return nil, append(failures, err)
+	}
+	weather.ObservedAt, weather.FetchedAt = observedAt, fetchedAt
+
+	return weather, nil
+}
+
+// The hedging delay is the configured percentile of the latencies of the service hedged, or the configured delay
+// should there not be enough samples yet.
+func (w *DefaultWeatherController) hedgeDelay(latencies latency.Tracker) time.Duration {
+	if delay, ok := latencies.Percentile(w.cfg.Current().HedgingPercentile); ok {
+		return delay
+	}
+
+	return time.Duration(w.cfg.Current().HedgingDelayMilliseconds) * time.Millisecond
 }
 
-// Fetch the weather information from a weather service.
-func (w *DefaultWeatherController) fetchWeather(
-	gCtx *gin.Context,
+// A weatherService is fetched through its circuit breaker, within its timeout.
+type weatherService struct {
+	timeout int
+	cb      breaker.CircuitBreaker
+	fetcher service.WeatherFetcher
+	trust   float64
+	latency latency.Tracker
+}
+
+// Returns the weather services in the provider order (the primary first, unless the failover is given first).
+func (w *DefaultWeatherController) services() []weatherService {
+	cfg := w.cfg.Current()
+	primary := weatherService{cfg.PrimaryTimeoutSeconds, w.cbPrimary, w.primary, cfg.PrimaryTrust, w.primaryLatency}
+	failover := weatherService{cfg.FailoverTimeoutSeconds, w.cbFailover, w.failover, cfg.FailoverTrust, w.failoverLatency}
+	if len(cfg.ProviderOrder) > 0 && cfg.ProviderOrder[0] == cache.ProviderFailover {
+		return []weatherService{failover, primary}
+	}
+
+	return []weatherService{primary, failover}
+}
+
+// Fetch the weather information from a weather service, through its circuit breaker.
+func (w *DefaultWeatherController) fetch(
+	ctx context.Context,
 	timeout int,
-	cb *gobreaker.CircuitBreaker,
+	cb breaker.CircuitBreaker,
 	location string,
 	fetcher service.WeatherFetcher,
-) bool {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+) (*model.Weather, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
 	defer cancel()
 
-	if res, err := cb.Execute(func() (interface{}, error) {
+	res, err := cb.Execute(func() (interface{}, error) {
 		return fetcher.FetchWeather(ctx, location)
-	}); err != nil {
+	})
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			w.log.WithField("location", location).Debug("Cancelled the fetch from ", cb.Name())
+			return nil, err
+		}
+		// Skipped locally (rather than failed by the weather service), so neither counted nor warned of
+		if errors.Is(err, service.ErrDisabled) || errors.Is(err, breaker.ErrForcedOpen) ||
+			errors.Is(err, breaker.ErrSuspended) || errors.Is(err, quota.ErrBudgetExhausted) {
+			return nil, err
+		}
+
+		kind := service.KindOf(err)
 		w.log.WithError(err).WithField("location", location).Warn("Failed to fetch from ", cb.Name())
-		// TODO Add in some metrics here (perhaps based upon cb.Counts())
-		return false
-	} else {
-		weather := res.(*model.Weather)
-		weather.Status = http.StatusOK
-		weather.Message = MessageSuccess
-		w.weatherCache.Set(location, weather)
-		gCtx.JSON(http.StatusOK, weather)
-		return true
+		metrics.ProviderErrors.WithLabelValues(cb.Name(), kind.String()).Inc()
+		if after := service.RetryAfterOf(err); kind == service.KindQuotaExceeded && after > 0 {
+			w.suspend(cb, time.Now().Add(after))
+		}
+		return nil, err
+	}
+
+	return res.(*model.Weather), nil
+}
+
+// Suspend the weather service (i.e. its quota is exhausted) until the time given, through its circuit breaker (should
+// it be suspendable), so that an operator may lift the suspension by forcing its mode or resetting it.
+func (w *DefaultWeatherController) suspend(cb breaker.CircuitBreaker, until time.Time) {
+	if suspender, ok := cb.(breaker.Suspender); ok {
+		w.log.WithField("until", until).Warn("Suspending ", cb.Name())
+		suspender.Suspend(until)
+	}
+}
+
+// Return the body (rendered from the cache entry) to the caller along with the caching headers of the entry (and its
+// variant), or only the headers (as a 304 Not Modified) should the caller's copy still be current.
+func respondEntry(gCtx *gin.Context, entry cache.Entry, stale bool, variant string, body render.Render) {
+	if writeCaching(gCtx, entry, stale, variant).notModified(gCtx.Request) {
+		gCtx.Status(http.StatusNotModified)
+		gCtx.Writer.WriteHeaderNow()
+		return
+	}
+
+	gCtx.Render(http.StatusOK, body)
+}
+
+// Report the failure to the caller as the problem (rendered by the Problems middleware), along with when to retry
+// (should the weather services be unavailable).
+func respondFailure(gCtx *gin.Context, res result, problem model.Problem) {
+	if res.retryAfter > 0 {
+		gCtx.Header("Retry-After", strconv.Itoa(int(res.retryAfter.Seconds())))
+	}
+	abortWithProblem(gCtx, problem)
+}
+
+// Returns a copy of the weather information of the cache entry, along with its freshness (as of now).
+func withFreshness(entry cache.Entry) model.Weather {
+	weather := *entry.Value.(*model.Weather)
+	freshness := &model.Freshness{Source: entry.Source, FetchedAt: fetchedAt(entry)}
+	since := freshness.FetchedAt
+	if !weather.ObservedAt.IsZero() {
+		observed := weather.ObservedAt
+		freshness.ObservedAt, since = &observed, observed
+	}
+	freshness.AgeSeconds = max(int(time.Since(since).Seconds()), 0)
+	weather.Freshness = freshness
+
+	return weather
+}
+
+// Returns when the weather information of the cache entry was fetched (or otherwise, stored).
+func fetchedAt(entry cache.Entry) time.Time {
+	if fetched := entry.Value.(*model.Weather).FetchedAt; !fetched.IsZero() {
+		return fetched
+	}
+
+	return entry.StoredAt
+}
+
+// Cache the weather information from the source given for the TTL of the policy, returning its cache entry.
+func (w *DefaultWeatherController) store(location, source string, weather *model.Weather) cache.Entry {
+	weather.Status = http.StatusOK
+	weather.Message = MessageSuccess
+	ttl := w.ttlPolicy.TTL(location, w.provider(source), weather)
+	w.weatherCache.Set(location, weather, source, ttl)
+
+	return cache.Entry{Key: location, Value: weather, Source: source, StoredAt: time.Now(), TTL: ttl, Fresh: true}
+}
+
+// Returns the weather service of the source, as known to the TTL policy (empty for the blend).
+func (w *DefaultWeatherController) provider(source string) string {
+	switch source {
+	case w.cbPrimary.Name():
+		return cache.ProviderPrimary
+	case w.cbFailover.Name():
+		return cache.ProviderFailover
+	default:
+		return ""
 	}
 }
+
+// Returns the cache entry (past its TTL), unless it is older than the stale max age.
+func (w *DefaultWeatherController) stale(location string) (cache.Entry, bool) {
+	entry, found := w.weatherCache.Lookup(location)
+	if !found {
+		return cache.Entry{}, false
+	}
+	maxAge := time.Duration(w.cfg.Current().TTLStaleMaxAgeSeconds) * time.Second
+	if maxAge > 0 && time.Since(entry.StoredAt) > maxAge {
+		return cache.Entry{}, false
+	}
+
+	return entry, true
+}
+
+// Returns why the weather information could not be returned. The location is only deemed to be invalid if every
+// weather service that answered could not find it, otherwise the weather services failed and the caller should
+// retry. Of these failures, the one the caller is most likely to act upon is reported (e.g. being rate limited, over
+// being unavailable).
+func (w *DefaultWeatherController) failure(location string, errs []error) result {
+	if locationNotFound(errs) != nil {
+		w.notFoundCache.Add(location)
+		return notFound(location)
+	}
+
+	worst := service.KindBadResponse
+	var retryAfter time.Duration
+	for _, err := range errs {
+		kind := service.KindOf(err)
+		if kind == service.KindNotFound {
+			continue
+		}
+		if upstreamFailures[kind].rank > upstreamFailures[worst].rank {
+			worst = kind
+		}
+		// The caller may retry once the first of the weather services is available again (a weather service that did
+		// not say when may be available by the default)
+		after := service.RetryAfterOf(err)
+		if after <= 0 {
+			after = defaultRetryAfter
+		}
+		if retryAfter == 0 || after < retryAfter {
+			retryAfter = after
+		}
+	}
+	if retryAfter == 0 {
+		retryAfter = defaultRetryAfter
+	}
+	retryAfter = min(retryAfter, maxRetryAfter)
+
+	res := upstreamFailure(location, worst)
+	res.retryAfter = retryAfter
+
+	return res
+}
+
+// Returns the error of a weather service that could not find the location, should every weather service that
+// answered say so (a disabled weather service does not answer), otherwise nil. A weather service that failed in any
+// other way may have found it (e.g. OpenWeatherMap only searches Australia, so misses the cities of other countries).
+func locationNotFound(errs []error) error {
+	var notFound error
+	for _, err := range errs {
+		switch {
+		case service.IsNotFound(err):
+			notFound = err
+		case !errors.Is(err, service.ErrDisabled):
+			return nil
+		}
+	}
+
+	return notFound
+}
+
+// Returns the failure of the weather services, by the kind of their error.
+func upstreamFailure(location string, kind service.ErrorKind) result {
+	if kind == service.KindNotFound {
+		return notFound(location)
+	}
+	failure := upstreamFailures[kind]
+
+	return result{
+		location: location,
+		status:   failure.status,
+		message:  failure.message,
+		detail:   fmt.Sprintf(failure.detail, location),
+		code:     failure.code,
+	}
+}
+
+// The failures of the weather services (other than not finding the location), by the kind of their errors.
+var upstreamFailures = map[service.ErrorKind]struct {
+	rank    int
+	status  int
+	message string
+	code    string
+	detail  string
+}{
+	service.KindBadResponse: {0, http.StatusBadGateway, MessageBadGateway, CodeBadGateway,
+		"The weather services returned an invalid response for %s"},
+	service.KindUnauthorized: {1, http.StatusBadGateway, MessageBadGateway, CodeUnauthorized,
+		"The weather services rejected the access keys of the service, when asked for %s"},
+	service.KindUnavailable: {2, http.StatusServiceUnavailable, MessageUnavailable, CodeUnavailable,
+		"The weather services are unavailable, and %s is not in the cache"},
+	service.KindQuotaExceeded: {3, http.StatusServiceUnavailable, MessageUnavailable, CodeRateLimited,
+		"The quota of the weather services is exhausted, and %s is not in the cache"},
+	service.KindRateLimited: {3, http.StatusServiceUnavailable, MessageUnavailable, CodeRateLimited,
+		"The weather services are rate limiting the service, and %s is not in the cache"},
+}
+
+// Returns the failure of a location that could not be found.
+func notFound(location string) result {
+	return result{
+		location: location,
+		status:   http.StatusNotFound,
+		message:  MessageFailure,
+		detail:   location + " could not be found by the weather services",
+		code:     CodeNotFound,
+	}
+}
+
+// Returns the failure of a location that is not valid.
+func invalidLocation(location string) result {
+	return result{
+		location: location,
+		status:   http.StatusBadRequest,
+		message:  MessageInvalid,
+		detail: fmt.Sprintf("The city %q must be up to %d letters, digits, spaces and the punctuation .,'-",
+			location, maxLocationLength),
+		code: CodeInvalidLocation,
+	}
+}
+
+// Returns true should the location be worth asking the weather services for (i.e. it is a plausible place name).
+func validLocation(location string) bool {
+	return utf8.RuneCountInString(location) <= maxLocationLength && locationPattern.MatchString(location)
+}
@@ -2,21 +2,28 @@ package controller_test
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
+	"github.com/ColinSchofield/zai-weather/src/breaker"
+	"github.com/ColinSchofield/zai-weather/src/cache"
 	"github.com/ColinSchofield/zai-weather/src/config"
 	"github.com/ColinSchofield/zai-weather/src/controller"
+	"github.com/ColinSchofield/zai-weather/src/metrics"
 	mock "github.com/ColinSchofield/zai-weather/src/mock"
 	"github.com/ColinSchofield/zai-weather/src/model"
+	"github.com/ColinSchofield/zai-weather/src/quota"
+	"github.com/ColinSchofield/zai-weather/src/service"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang/mock/gomock"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/sirupsen/logrus"
-	"github.com/sony/gobreaker"
+	"github.com/sony/gobreaker/v2"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -27,8 +34,8 @@ type ControllerTestSuite struct {
 	ctx          context.Context
 	log          *logrus.Logger
 	cfg          *config.WeatherConfig
-	cbP          *gobreaker.CircuitBreaker
-	cbF          *gobreaker.CircuitBreaker
+	cbP          *gobreaker.CircuitBreaker[any]
+	cbF          *gobreaker.CircuitBreaker[any]
 	mockPrimary  *mock.MockWeatherFetcher
 	mockFailover *mock.MockWeatherFetcher
 	controller   controller.WeatherController
@@ -47,12 +54,13 @@ func (s *ControllerTestSuite) SetupTest() {
 	s.cfg = &config.WeatherConfig{
 		CacheTTLSeconds: 1,
 	}
-	s.cbP = gobreaker.NewCircuitBreaker(gobreaker.Settings{
+	s.cbP = gobreaker.NewCircuitBreaker[any](gobreaker.Settings{
+		Name: "primary",
 		ReadyToTrip: func(counts gobreaker.Counts) bool {
 			return counts.TotalFailures >= 1
 		},
 	})
-	s.cbF = gobreaker.NewCircuitBreaker(gobreaker.Settings{})
+	s.cbF = gobreaker.NewCircuitBreaker[any](gobreaker.Settings{Name: "failover"})
 	s.mockPrimary = mock.NewMockWeatherFetcher(s.ctrl)
 	s.mockFailover = mock.NewMockWeatherFetcher(s.ctrl)
 	s.controller = controller.NewWeatherController(
@@ -67,6 +75,12 @@ func (s *ControllerTestSuite) SetupTest() {
 	s.gCtx, _ = gin.CreateTestContext(s.record)
 }
 
+// Get the weather, as the Problems middleware would (rendering the failure of the request).
+func (s *ControllerTestSuite) getWeather(gCtx *gin.Context) {
+	s.controller.GetWeather(gCtx)
+	controller.Problems(s.log)(gCtx)
+}
+
 func (s *ControllerTestSuite) Test_HappyPath() {
 	// Given
 	mockResponse := &model.Weather{
@@ -77,7 +91,7 @@ func (s *ControllerTestSuite) Test_HappyPath() {
 	}
 	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(mockResponse, nil)
 	// When
-	s.controller.GetWeather(s.gCtx)
+	s.getWeather(s.gCtx)
 	// Then
 	s.Assert().Equal(http.StatusOK, s.record.Code, "HTTP status of 200")
 	s.Assert().NotNil(s.record.Body)
@@ -93,12 +107,12 @@ func (s *ControllerTestSuite) Test_HappyPathThenReadsFromTheCache() {
 	}
 	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(mockResponse, nil)
 	// When
-	s.controller.GetWeather(s.gCtx)
+	s.getWeather(s.gCtx)
 	// Then
 	s.Assert().Equal(http.StatusOK, s.record.Code, "HTTP status of 200")
 	s.Assert().NotNil(s.record.Body)
 	// When
-	s.controller.GetWeather(s.gCtx)
+	s.getWeather(s.gCtx)
 	// Then
 	s.Assert().Equal(http.StatusOK, s.record.Code, "HTTP status of 200")
 	s.Assert().NotNil(s.record.Body)
@@ -115,12 +129,32 @@ func (s *ControllerTestSuite) Test_FailedPrimaryFailoverSuccess() {
 	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(nil, errors.New("Server is down!"))
 	s.mockFailover.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(mockResponse, nil)
 	// When
-	s.controller.GetWeather(s.gCtx)
+	s.getWeather(s.gCtx)
 	// Then
 	s.Assert().Equal(http.StatusOK, s.record.Code, "HTTP status of 200")
 	s.Assert().NotNil(s.record.Body)
 }
 
+func (s *ControllerTestSuite) Test_ProviderOrderFetchesTheFailoverFirst() {
+	// Given
+	s.cfg.ProviderOrder = []string{"failover", "primary"}
+	mockResponse := &model.Weather{Data: &model.Data{Temperature: 10, WindSpeed: 15}}
+	gomock.InOrder(
+		s.mockFailover.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(nil, errors.New("Server is down!")),
+		s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(mockResponse, nil),
+	)
+	// When
+	s.getWeather(s.gCtx)
+	// Then
+	s.Assert().Equal(http.StatusOK, s.record.Code, "HTTP status of 200")
+	// When
+	s.cfg.ProviderOrder = []string{"primary", "failover"}
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Sydney").Return(mockResponse, nil)
+	_, err := s.controller.(*controller.DefaultWeatherController).Refresh(s.ctx, "Sydney")
+	// Then
+	s.Assert().NoError(err, "The reloaded order is applied, so the failover is not fetched")
+}
+
 func (s *ControllerTestSuite) Test_BothPrimaryAndFailoverFail() {
 	// Given
 	mockResponse := &model.Weather{
@@ -133,9 +167,9 @@ func (s *ControllerTestSuite) Test_BothPrimaryAndFailoverFail() {
 	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(nil, errors.New("Server is down!"))
 	s.mockFailover.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(nil, errors.New("Server is down!"))
 	// When
-	s.controller.GetWeather(s.gCtx)
+	s.getWeather(s.gCtx)
 	time.Sleep(1100 * time.Millisecond)
-	s.controller.GetWeather(s.gCtx)
+	s.getWeather(s.gCtx)
 	// Then
 	s.Assert().Equal(http.StatusOK, s.record.Code, "HTTP status of 200 (falback to the cache!)")
 }
@@ -145,9 +179,152 @@ func (s *ControllerTestSuite) Test_BothPrimaryAndFailoverFailSoFallbackToCache()
 	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(nil, errors.New("Server is down!"))
 	s.mockFailover.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(nil, errors.New("Server is down!"))
 	// When
-	s.controller.GetWeather(s.gCtx)
+	s.getWeather(s.gCtx)
+	// Then
+	s.Assert().Equal(http.StatusServiceUnavailable, s.record.Code, "HTTP status of 503 (as nothing is in the cache!)")
+	s.Assert().Equal("30", s.record.Header().Get("Retry-After"))
+}
+
+func (s *ControllerTestSuite) Test_StaleFallbackIsLimitedByItsMaxAge() {
+	// Given
+	s.cfg.TTLStaleMaxAgeSeconds = 3600
+	weatherCache := s.controller.(*controller.DefaultWeatherController).Cache()
+	weatherCache.Restore(cache.Entry{Key: "Melbourne", Value: &model.Weather{}, StoredAt: time.Now().Add(-2 * time.Hour)})
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(nil, errors.New("Server is down!"))
+	s.mockFailover.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Times(2).Return(nil, errors.New("Server is down!"))
+	// When
+	s.getWeather(s.gCtx)
+	// Then
+	s.Assert().Equal(http.StatusServiceUnavailable, s.record.Code, "HTTP status of 503 (the cache is too stale)")
+	// When
+	s.cfg.TTLStaleMaxAgeSeconds = 0
+	s.record = httptest.NewRecorder()
+	s.gCtx, _ = gin.CreateTestContext(s.record)
+	s.getWeather(s.gCtx)
+	// Then
+	s.Assert().Equal(http.StatusOK, s.record.Code, "HTTP status of 200 (without a limit)")
+}
+
+func (s *ControllerTestSuite) Test_CachedForTheTTLOfThePolicy() {
+	// Given
+	s.cfg.TTLPrimarySeconds = 60
+	s.cfg.TTLFailoverSeconds = 120
+	s.cfg.TTLMaxSeconds = 900
+	s.cfg.TTLHonorUpstream = true
+	s.cfg.TTLLocations = []string{"Darwin=600"}
+	refresher := s.controller.(*controller.DefaultWeatherController)
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(&model.Weather{Data: &model.Data{}}, nil)
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Sydney").Return(nil, errors.New("Server is down!"))
+	s.mockFailover.EXPECT().FetchWeather(gomock.Any(), "Sydney").
+		Return(&model.Weather{Data: &model.Data{}, MaxAge: 300 * time.Second}, nil)
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Darwin").Return(&model.Weather{Data: &model.Data{}}, nil)
+	// When (Sydney trips the primary last)
+	for _, location := range []string{"Melbourne", "Darwin", "Sydney"} {
+		_, err := refresher.Refresh(s.ctx, location)
+		s.Require().NoError(err)
+	}
+	// Then
+	for location, ttl := range map[string]time.Duration{
+		"Melbourne": 60 * time.Second,  // The TTL of the primary
+		"Sydney":    300 * time.Second, // The max age of the failover
+		"Darwin":    600 * time.Second, // The TTL of the location
+	} {
+		entry, found := refresher.Cache().Lookup(location)
+		s.Require().True(found, location)
+		s.Assert().Equal(ttl, entry.TTL, location)
+	}
+}
+
+func (s *ControllerTestSuite) Test_FreshnessOfTheResponse() {
+	// Given
+	fetched := time.Now().UTC().Add(-time.Second)
+	observed := fetched.Add(-90 * time.Second)
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(&model.Weather{
+		Data: &model.Data{Temperature: 10, WindSpeed: 15}, ObservedAt: observed, FetchedAt: fetched,
+	}, nil)
+	// When
+	s.getWeather(s.gCtx)
+	// Then
+	var weather model.Weather
+	s.Require().NoError(json.Unmarshal(s.record.Body.Bytes(), &weather))
+	s.Require().NotNil(weather.Freshness)
+	s.Assert().Equal("primary", weather.Freshness.Source)
+	s.Assert().True(observed.Equal(*weather.Freshness.ObservedAt))
+	s.Assert().True(fetched.Equal(weather.Freshness.FetchedAt))
+	s.Assert().InDelta(91, weather.Freshness.AgeSeconds, 1, "Since it was observed")
+	// When (the cached value has aged)
+	time.Sleep(1100 * time.Millisecond)
+	s.record = httptest.NewRecorder()
+	s.gCtx, _ = gin.CreateTestContext(s.record)
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(nil, errors.New("Server is down!"))
+	s.mockFailover.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(nil, errors.New("Server is down!"))
+	s.getWeather(s.gCtx)
+	// Then
+	s.Require().NoError(json.Unmarshal(s.record.Body.Bytes(), &weather))
+	s.Assert().Equal(controller.MessageFailureCache, weather.Message)
+	s.Assert().InDelta(92, weather.Freshness.AgeSeconds, 1)
+}
+
+func (s *ControllerTestSuite) Test_LocationCouldNotBeFound() {
+	// Given
+	notFound := &service.FetchError{Kind: service.KindNotFound, Err: errors.New("Unknown city!")}
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(nil, notFound)
+	s.mockFailover.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(nil, notFound)
+	// When
+	s.getWeather(s.gCtx)
+	// Then
+	s.Assert().Equal(http.StatusNotFound, s.record.Code, "HTTP status of 404 (as neither could find it)")
+	s.Assert().Empty(s.record.Header().Get("Retry-After"))
+}
+
+func (s *ControllerTestSuite) Test_LocationNotFoundByOneWhileTheOtherIsUnavailable() {
+	// Given
+	notFound := &service.FetchError{Kind: service.KindNotFound, Err: errors.New("Unknown city!")}
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(nil, notFound)
+	s.mockFailover.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(nil, errors.New("Server is down!"))
+	// When
+	s.getWeather(s.gCtx)
+	// Then
+	s.Assert().Equal(http.StatusServiceUnavailable, s.record.Code, "HTTP status of 503 (as the failover may know it)")
+	s.Assert().Equal("30", s.record.Header().Get("Retry-After"))
+}
+
+func (s *ControllerTestSuite) Test_BadResponsesAreABadGateway() {
+	// Given
+	badResponse := &service.FetchError{Kind: service.KindBadResponse, RetryAfter: 2 * time.Minute, Err: errors.New("Garbage!")}
+	unauthorized := &service.FetchError{Kind: service.KindUnauthorized, RetryAfter: time.Minute, Err: errors.New("Who?")}
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(nil, badResponse)
+	s.mockFailover.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(nil, unauthorized)
+	// When
+	s.getWeather(s.gCtx)
+	// Then
+	s.Assert().Equal(http.StatusBadGateway, s.record.Code, "HTTP status of 502")
+	s.Assert().Equal("60", s.record.Header().Get("Retry-After"), "the Retry-After of the weather service is used")
+}
+
+func (s *ControllerTestSuite) Test_RetryAfterOfAnExhaustedAndAnUnavailableService() {
+	// Given
+	exhausted := &service.FetchError{Kind: service.KindQuotaExceeded, RetryAfter: 21 * 24 * time.Hour, Err: errors.New("Pay up!")}
+	unavailable := &service.FetchError{Kind: service.KindUnavailable, Err: errors.New("Server is down!")}
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(nil, exhausted)
+	s.mockFailover.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(nil, unavailable)
+	// When
+	s.getWeather(s.gCtx)
+	// Then
+	s.Assert().Equal(http.StatusServiceUnavailable, s.record.Code, "HTTP status of 503")
+	s.Assert().Equal("30", s.record.Header().Get("Retry-After"), "the unavailable failover may be back by the default")
+}
+
+func (s *ControllerTestSuite) Test_RetryAfterIsCapped() {
+	// Given
+	exhausted := &service.FetchError{Kind: service.KindQuotaExceeded, RetryAfter: 21 * 24 * time.Hour, Err: errors.New("Pay up!")}
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(nil, exhausted)
+	s.mockFailover.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(nil, exhausted)
+	// When
+	s.getWeather(s.gCtx)
 	// Then
-	s.Assert().Equal(http.StatusNotFound, s.record.Code, "HTTP status of 404 (as nothing is in the cache!)")
+	s.Assert().Equal(http.StatusServiceUnavailable, s.record.Code, "HTTP status of 503")
+	s.Assert().Equal("3600", s.record.Header().Get("Retry-After"), "at most an hour, rather than weeks")
 }
 
 func (s *ControllerTestSuite) Test_PrimaryFailsAndCircuitBreakerIsTripped() {
@@ -161,14 +338,267 @@ func (s *ControllerTestSuite) Test_PrimaryFailsAndCircuitBreakerIsTripped() {
 	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(nil, errors.New("Server is down!"))
 	s.mockFailover.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Times(2).Return(mockResponse, nil)
 	// When
-	s.controller.GetWeather(s.gCtx)
+	s.getWeather(s.gCtx)
 	// Then
 	s.Assert().Equal(http.StatusOK, s.record.Code, "HTTP status of 200")
 	s.Assert().NotNil(s.record.Body)
 	// When
 	time.Sleep(1100 * time.Millisecond)
-	s.controller.GetWeather(s.gCtx)
+	s.getWeather(s.gCtx)
 	// Then
 	s.Assert().Equal(http.StatusOK, s.record.Code, "HTTP status of 200")
 	s.Assert().NotNil(s.record.Body)
 }
+
+func (s *ControllerTestSuite) Test_HedgingSlowPrimaryFailoverWins() {
+	// Given
+	s.cfg.HedgingEnabled = true
+	s.cfg.HedgingDelayMilliseconds = 50
+	s.cfg.PrimaryTimeoutSeconds = 3
+	s.cfg.FailoverTimeoutSeconds = 3
+	mockResponse := &model.Weather{
+		Data: &model.Data{
+			Temperature: 10,
+			WindSpeed:   15,
+		},
+	}
+	cancelled := make(chan struct{})
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Melbourne").DoAndReturn(
+		func(ctx context.Context, location string) (*model.Weather, error) {
+			<-ctx.Done()
+			close(cancelled)
+			return nil, ctx.Err()
+		})
+	s.mockFailover.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(mockResponse, nil)
+	// When
+	start := time.Now()
+	s.getWeather(s.gCtx)
+	// Then
+	s.Assert().Equal(http.StatusOK, s.record.Code, "HTTP status of 200")
+	s.Assert().Less(time.Since(start), time.Second, "the failover answered without waiting for the primary")
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		s.Fail("the slow primary was not cancelled")
+	}
+}
+
+func (s *ControllerTestSuite) Test_HedgingFastPrimaryDoesNotHedge() {
+	// Given
+	s.cfg.HedgingEnabled = true
+	s.cfg.HedgingDelayMilliseconds = 500
+	s.cfg.PrimaryTimeoutSeconds = 3
+	mockResponse := &model.Weather{
+		Data: &model.Data{
+			Temperature: 10,
+			WindSpeed:   15,
+		},
+	}
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(mockResponse, nil)
+	// When
+	s.getWeather(s.gCtx)
+	// Then
+	s.Assert().Equal(http.StatusOK, s.record.Code, "HTTP status of 200 (and the failover was never called)")
+}
+
+func (s *ControllerTestSuite) Test_HedgingPrimaryFailsBeforeTheDelay() {
+	// Given
+	s.cfg.HedgingEnabled = true
+	s.cfg.HedgingDelayMilliseconds = 5000
+	s.cfg.PrimaryTimeoutSeconds = 3
+	s.cfg.FailoverTimeoutSeconds = 3
+	mockResponse := &model.Weather{
+		Data: &model.Data{
+			Temperature: 10,
+			WindSpeed:   15,
+		},
+	}
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(nil, errors.New("Server is down!"))
+	s.mockFailover.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(mockResponse, nil)
+	// When
+	start := time.Now()
+	s.getWeather(s.gCtx)
+	// Then
+	s.Assert().Equal(http.StatusOK, s.record.Code, "HTTP status of 200")
+	s.Assert().Less(time.Since(start), time.Second, "the failover was fired without waiting for the delay")
+}
+
+func (s *ControllerTestSuite) Test_UnknownLocationIsAnsweredFromTheNegativeCache() {
+	// Given
+	s.cfg.NegativeCacheTTLSeconds = 60
+	s.controller = controller.NewWeatherController(s.cfg, s.log, s.mockPrimary, s.mockFailover, s.cbP, s.cbF)
+	notFound := &service.FetchError{Kind: service.KindNotFound, Err: errors.New("Unknown city!")}
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(nil, notFound)
+	s.mockFailover.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(nil, notFound)
+	hits := testutil.ToFloat64(metrics.NegativeCacheHits)
+	// When
+	s.getWeather(s.gCtx)
+	// Then
+	s.Assert().Equal(http.StatusNotFound, s.record.Code, "HTTP status of 404")
+	// When
+	record := httptest.NewRecorder()
+	gCtx, _ := gin.CreateTestContext(record)
+	s.getWeather(gCtx)
+	// Then
+	s.Assert().Equal(http.StatusNotFound, record.Code, "HTTP status of 404 (without calling either service)")
+	s.Assert().Equal(hits+1, testutil.ToFloat64(metrics.NegativeCacheHits))
+}
+
+func (s *ControllerTestSuite) Test_PrimaryQuotaExceededIsSuspended() {
+	// Given
+	store, _ := breaker.NewFileStore("")
+	breakers := breaker.NewFactory(s.log, store)
+	cbP := breakers.New("primary", "primary", func() breaker.Settings {
+		return breaker.Settings{Policy: breaker.Consecutive, ConsecutiveFailures: 5, OpenTimeout: time.Minute}
+	})
+	s.controller = controller.NewWeatherController(s.cfg, s.log, s.mockPrimary, s.mockFailover, cbP, s.cbF)
+	quotaExceeded := &service.FetchError{Kind: service.KindQuotaExceeded, RetryAfter: time.Hour, Err: errors.New("Pay up!")}
+	mockResponse := &model.Weather{
+		Data: &model.Data{
+			Temperature: 10,
+			WindSpeed:   15,
+		},
+	}
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(nil, quotaExceeded)
+	s.mockFailover.EXPECT().FetchWeather(gomock.Any(), gomock.Any()).Times(2).Return(mockResponse, nil)
+	// When
+	s.getWeather(s.gCtx)
+	// Then
+	s.Assert().Equal(http.StatusOK, s.record.Code, "HTTP status of 200")
+	s.Assert().NotNil(cbP.Status().SuspendedUntil, "Suspended, while its state is still closed")
+	s.Assert().Equal("closed", cbP.Status().State)
+	// When
+	record := httptest.NewRecorder()
+	gCtx, _ := gin.CreateTestContext(record)
+	gCtx.Request = httptest.NewRequest(http.MethodGet, "/v1/weather?city=Sydney", nil)
+	s.getWeather(gCtx)
+	// Then
+	s.Assert().Equal(http.StatusOK, record.Code, "HTTP status of 200 (without calling the suspended primary)")
+	// When
+	_, err := breakers.SetMode("primary", breaker.ForcedClosed)
+	s.Require().NoError(err)
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Perth").Return(mockResponse, nil)
+	_, err = s.controller.(*controller.DefaultWeatherController).Refresh(s.ctx, "Perth")
+	// Then
+	s.Assert().NoError(err, "Forced closed, so the primary is called again")
+	s.Assert().Nil(cbP.Status().SuspendedUntil)
+}
+
+func (s *ControllerTestSuite) Test_PrimaryOverBudgetIsSkippedQuietly() {
+	// Given
+	overBudget := &service.FetchError{Kind: service.KindQuotaExceeded, RetryAfter: time.Hour, Err: quota.ErrBudgetExhausted}
+	mockResponse := &model.Weather{Data: &model.Data{Temperature: 10, WindSpeed: 15}}
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(nil, overBudget)
+	s.mockFailover.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(mockResponse, nil)
+	errs := testutil.ToFloat64(metrics.ProviderErrors.WithLabelValues("primary", "quota exceeded"))
+	// When
+	s.getWeather(s.gCtx)
+	// Then
+	s.Assert().Equal(http.StatusOK, s.record.Code, "HTTP status of 200")
+	s.Assert().Equal(errs, testutil.ToFloat64(metrics.ProviderErrors.WithLabelValues("primary", "quota exceeded")),
+		"Skipped locally, so not counted as an error of the weather service")
+}
+
+func (s *ControllerTestSuite) Test_BlendStrategyQueriesAllServices() {
+	// Given
+	s.cfg.FetchStrategy = controller.StrategyBlend
+	s.cfg.BlendMethod = "median"
+	observed := time.Now().UTC().Add(-10 * time.Minute).Truncate(time.Second)
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(&model.Weather{
+		Data: &model.Data{Temperature: 10, WindSpeed: 15}, ObservedAt: observed.Add(5 * time.Minute),
+	}, nil)
+	s.mockFailover.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(&model.Weather{
+		Data: &model.Data{Temperature: 14, WindSpeed: 25}, ObservedAt: observed,
+	}, nil)
+	// When
+	s.getWeather(s.gCtx)
+	// Then
+	s.Assert().Equal(http.StatusOK, s.record.Code, "HTTP status of 200")
+	var weather model.Weather
+	s.Assert().NoError(json.Unmarshal(s.record.Body.Bytes(), &weather))
+	s.Assert().Equal(model.Data{Temperature: 12, WindSpeed: 20}, *weather.Data)
+	s.Assert().Len(weather.Blend.Readings, 2)
+	s.Assert().Equal(float64(4), weather.Blend.Disagreement)
+	s.Assert().Equal("blend", weather.Freshness.Source)
+	s.Assert().True(observed.Equal(*weather.Freshness.ObservedAt), "The oldest of the observations")
+}
+
+func (s *ControllerTestSuite) Test_BlendStrategySkipsTheFailedServices() {
+	// Given
+	s.cfg.FetchStrategy = controller.StrategyBlend
+	s.cfg.BlendMethod = "median"
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(nil, errors.New("Server is down!"))
+	s.mockFailover.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(&model.Weather{
+		Data: &model.Data{Temperature: 14, WindSpeed: 25},
+	}, nil)
+	// When
+	s.getWeather(s.gCtx)
+	// Then
+	s.Assert().Equal(http.StatusOK, s.record.Code, "HTTP status of 200")
+	var weather model.Weather
+	s.Assert().NoError(json.Unmarshal(s.record.Body.Bytes(), &weather))
+	s.Assert().Equal(model.Data{Temperature: 14, WindSpeed: 25}, *weather.Data)
+	s.Assert().Len(weather.Blend.Readings, 1)
+}
+
+func (s *ControllerTestSuite) Test_BlendOutlierKeepsTheFirstInTheProviderOrder() {
+	// Given
+	s.cfg.FetchStrategy = controller.StrategyBlend
+	s.cfg.BlendMethod = "outlier"
+	s.cfg.BlendOutlierDegrees = 5
+	s.cfg.ProviderOrder = []string{"failover", "primary"}
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(&model.Weather{
+		Data: &model.Data{Temperature: 10, WindSpeed: 15}, Values: &model.Values{Temperature: 10.4, WindSpeed: 15.2},
+	}, nil)
+	s.mockFailover.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(&model.Weather{
+		Data: &model.Data{Temperature: 12, WindSpeed: 25}, Values: &model.Values{Temperature: 12.6, WindSpeed: 25.1},
+	}, nil)
+	// When
+	s.getWeather(s.gCtx)
+	// Then
+	s.Assert().Equal(http.StatusOK, s.record.Code, "HTTP status of 200")
+	var weather model.Weather
+	s.Assert().NoError(json.Unmarshal(s.record.Body.Bytes(), &weather))
+	s.Assert().Equal(model.Data{Temperature: 13, WindSpeed: 25}, *weather.Data, "The failover, rounded")
+	s.Assert().Equal(model.Values{Temperature: 10.4, WindSpeed: 15.2}, weather.Blend.Readings[1].Values,
+		"The untruncated values of the primary")
+}
+
+func (s *ControllerTestSuite) Test_RefreshBypassesTheCache() {
+	// Given
+	weatherController := s.controller.(*controller.DefaultWeatherController)
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(nil, errors.New("Server is down!"))
+	s.mockFailover.EXPECT().FetchWeather(gomock.Any(), "Melbourne").
+		Return(&model.Weather{Data: &model.Data{Temperature: 10}}, nil)
+	s.mockFailover.EXPECT().FetchWeather(gomock.Any(), "Melbourne").
+		Return(&model.Weather{Data: &model.Data{Temperature: 11}}, nil)
+	s.getWeather(s.gCtx)
+	// When
+	weather, err := weatherController.Refresh(s.ctx, "Melbourne")
+	// Then
+	s.Require().NoError(err)
+	s.Assert().Equal(11, weather.Data.Temperature)
+	entry, found := weatherController.Cache().Lookup("Melbourne")
+	s.Assert().True(found)
+	s.Assert().Equal("failover", entry.Source, "The primary breaker is open")
+	s.Assert().Equal(weather, entry.Value)
+}
+
+func (s *ControllerTestSuite) Test_RefreshNotFound() {
+	// Given
+	weatherController := s.controller.(*controller.DefaultWeatherController)
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Nowhere").
+		Return(nil, &service.FetchError{Kind: service.KindNotFound})
+	s.mockFailover.EXPECT().FetchWeather(gomock.Any(), gomock.Any()).Times(2).
+		Return(nil, &service.FetchError{Kind: service.KindNotFound})
+	// When
+	_, err := weatherController.Refresh(s.ctx, "Nowhere")
+	// Then
+	s.Assert().True(service.IsNotFound(err), "Neither could find the location")
+	_, found := weatherController.Cache().Lookup("Nowhere")
+	s.Assert().False(found)
+	// When
+	_, err = weatherController.Refresh(s.ctx, "Somewhere")
+	// Then
+	s.Assert().False(service.IsNotFound(err), "The primary breaker is open, and the primary may know the location")
+}
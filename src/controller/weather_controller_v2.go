@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"strings"
+	"time"
+
+	"github.com/ColinSchofield/zai-weather/src/cache"
+	"github.com/ColinSchofield/zai-weather/src/config"
+	"github.com/ColinSchofield/zai-weather/src/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/render"
+)
+
+const (
+	// The sources of the weather information (see the v2 API)
+	SourceLive  = "live"  // Fetched from the weather services
+	SourceCache = "cache" // Within its TTL
+	SourceStale = "stale" // Past its TTL, as the weather services failed
+
+	// The codes of the errors (see the v2 API)
+	CodeInvalidLocation = "invalid_location"
+	CodeNotFound        = "location_not_found"
+	CodeUnavailable     = "upstream_unavailable"
+	CodeRateLimited     = "upstream_rate_limited"
+	CodeUnauthorized    = "upstream_unauthorized"
+	CodeBadGateway      = "upstream_bad_response"
+)
+
+// The result of a weather request, before it is rendered by a version of the API. The request failed should it have
+// no source.
+type result struct {
+	location string
+	source   string
+	entry    cache.Entry
+
+	status     int
+	message    string
+	detail     string
+	code       string
+	retryAfter time.Duration
+}
+
+// Returns true should the weather information not have been found.
+func (r result) failed() bool {
+	return r.source == ""
+}
+
+// Returns the problem of the failed request, whose type is named after its code.
+func (r result) problem() model.Problem {
+	return newProblem(problemTypes+strings.ReplaceAll(r.code, "_", "-"), r.status, r.detail)
+}
+
+// Returns the problem of the failed request, along with its message (as the v1 API returns it).
+func (r result) problemV1() model.Problem {
+	problem := r.problem()
+	problem.Message = r.message
+
+	return problem
+}
+
+// Returns the weather information (with its freshness), along with the message of its source (as the v1 API returns
+// it).
+func (r result) weatherV1() model.Weather {
+	weather := withFreshness(r.entry)
+	weather.Message = map[string]string{
+		SourceLive:  MessageSuccess,
+		SourceCache: MessageSuccessCache,
+		SourceStale: MessageFailureCache,
+	}[r.source]
+
+	return weather
+}
+
+// GetWeatherV2 returns the weather information within the envelope of the v2 API, telling where it came from (live,
+// cache or stale), and failures as a problem with the error (and its stable code) of the envelope.
+func (w *DefaultWeatherController) GetWeatherV2(gCtx *gin.Context) {
+	res := w.resolve(gCtx.DefaultQuery("city", config.DefaultLocation))
+	location := model.Location{Name: res.location}
+	if res.failed() {
+		problem := res.problem()
+		problem.Location, problem.Error = &location, &model.APIError{Code: res.code, Detail: res.detail}
+		respondFailure(gCtx, res, problem)
+		return
+	}
+
+	weather := withFreshness(res.entry)
+	envelope := model.WeatherV2{Source: res.source, Location: location}
+	envelope.Data, envelope.Blend, envelope.Freshness = weather.Data, weather.Blend, weather.Freshness
+	respondEntry(gCtx, res.entry, res.source == SourceStale, "", render.JSON{Data: envelope})
+}
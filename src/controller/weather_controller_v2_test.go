@@ -0,0 +1,149 @@
+package controller_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ColinSchofield/zai-weather/src/config"
+	"github.com/ColinSchofield/zai-weather/src/controller"
+	mock "github.com/ColinSchofield/zai-weather/src/mock"
+	"github.com/ColinSchofield/zai-weather/src/model"
+	"github.com/ColinSchofield/zai-weather/src/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+	"github.com/sony/gobreaker/v2"
+	"github.com/stretchr/testify/suite"
+)
+
+type WeatherV2TestSuite struct {
+	suite.Suite
+
+	mockPrimary  *mock.MockWeatherFetcher
+	mockFailover *mock.MockWeatherFetcher
+	router       *gin.Engine
+}
+
+func TestWeatherV2Suite(t *testing.T) {
+	suite.Run(t, new(WeatherV2TestSuite))
+}
+
+func (s *WeatherV2TestSuite) SetupTest() {
+	ctrl := gomock.NewController(s.T())
+	s.mockPrimary = mock.NewMockWeatherFetcher(ctrl)
+	s.mockFailover = mock.NewMockWeatherFetcher(ctrl)
+	weatherController := controller.NewWeatherController(
+		&config.WeatherConfig{CacheTTLSeconds: 1},
+		logrus.New(),
+		s.mockPrimary,
+		s.mockFailover,
+		gobreaker.NewCircuitBreaker[any](gobreaker.Settings{Name: "primary"}),
+		gobreaker.NewCircuitBreaker[any](gobreaker.Settings{Name: "failover"}),
+	)
+	s.router = gin.New()
+	s.router.Use(controller.Problems(logrus.New()))
+	s.router.GET("v1/weather", weatherController.GetWeather)
+	s.router.GET("v2/weather", weatherController.GetWeatherV2)
+}
+
+func (s *WeatherV2TestSuite) request(path string) (*httptest.ResponseRecorder, model.WeatherV2) {
+	record := httptest.NewRecorder()
+	s.router.ServeHTTP(record, httptest.NewRequest(http.MethodGet, path, nil))
+	var envelope model.WeatherV2
+	s.Require().NoError(json.Unmarshal(record.Body.Bytes(), &envelope))
+
+	return record, envelope
+}
+
+func (s *WeatherV2TestSuite) problem(path string) (*httptest.ResponseRecorder, model.Problem) {
+	record := httptest.NewRecorder()
+	s.router.ServeHTTP(record, httptest.NewRequest(http.MethodGet, path, nil))
+	var problem model.Problem
+	s.Require().NoError(json.Unmarshal(record.Body.Bytes(), &problem))
+
+	return record, problem
+}
+
+func (s *WeatherV2TestSuite) Test_LiveThenCacheThenStale() {
+	// Given
+	weather := &model.Weather{Data: &model.Data{Temperature: 10, WindSpeed: 15}}
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Sydney").Return(weather, nil)
+	// When
+	record, envelope := s.request("/v2/weather?city=Sydney")
+	// Then
+	s.Assert().Equal(http.StatusOK, record.Code)
+	s.Assert().Equal(controller.SourceLive, envelope.Source)
+	s.Assert().Equal(model.Location{Name: "Sydney"}, envelope.Location)
+	s.Assert().Equal(model.Data{Temperature: 10, WindSpeed: 15}, *envelope.Data)
+	s.Assert().Equal("primary", envelope.Freshness.Source)
+	s.Assert().NotContains(record.Body.String(), "message", "Without the transport concerns of v1")
+	// When
+	_, envelope = s.request("/v2/weather?city=Sydney")
+	// Then
+	s.Assert().Equal(controller.SourceCache, envelope.Source)
+	// When
+	time.Sleep(1100 * time.Millisecond)
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Sydney").Return(nil, errors.New("Server is down!"))
+	s.mockFailover.EXPECT().FetchWeather(gomock.Any(), "Sydney").Return(nil, errors.New("Server is down!"))
+	record, envelope = s.request("/v2/weather?city=Sydney")
+	// Then
+	s.Assert().Equal(http.StatusOK, record.Code)
+	s.Assert().Equal(controller.SourceStale, envelope.Source)
+	s.Assert().Equal(10, envelope.Data.Temperature)
+}
+
+func (s *WeatherV2TestSuite) Test_Errors() {
+	// Given
+	notFound := &service.FetchError{Kind: service.KindNotFound, Err: errors.New("Unknown city!")}
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Nowhere").Return(nil, notFound)
+	s.mockFailover.EXPECT().FetchWeather(gomock.Any(), "Nowhere").Return(nil, notFound)
+	// When
+	record, problem := s.problem("/v2/weather?city=Nowhere")
+	// Then
+	s.Assert().Equal(http.StatusNotFound, record.Code)
+	s.Assert().Equal(controller.ContentTypeProblem, record.Header().Get("Content-Type"))
+	s.Assert().Equal(controller.ProblemNotFound, problem.Type)
+	s.Assert().Equal("/v2/weather?city=Nowhere", problem.Instance)
+	s.Assert().Equal(&model.APIError{Code: controller.CodeNotFound, Detail: problem.Detail}, problem.Error)
+	s.Assert().Equal("Nowhere could not be found by the weather services", problem.Detail)
+	s.Assert().Equal("Nowhere", problem.Location.Name)
+	s.Assert().NotContains(record.Body.String(), "data")
+	// Given
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(nil, errors.New("Server is down!"))
+	s.mockFailover.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(nil, errors.New("Server is down!"))
+	// When
+	record, problem = s.problem("/v2/weather")
+	// Then
+	s.Assert().Equal(http.StatusServiceUnavailable, record.Code)
+	s.Assert().Equal(controller.CodeUnavailable, problem.Error.Code)
+	s.Assert().Equal(controller.ProblemUnavailable, problem.Type)
+	s.Assert().Equal("Melbourne", problem.Location.Name, "The default location")
+	s.Assert().Equal("30", record.Header().Get("Retry-After"))
+}
+
+func (s *WeatherV2TestSuite) Test_V1KeepsItsFields() {
+	// Given
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(nil, errors.New("Server is down!"))
+	s.mockFailover.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(nil, errors.New("Server is down!"))
+	// When
+	record, problem := s.problem("/v1/weather")
+	// Then
+	s.Assert().Equal(http.StatusServiceUnavailable, record.Code)
+	s.Assert().Equal(http.StatusServiceUnavailable, problem.Status)
+	s.Assert().Equal(controller.MessageUnavailable, problem.Message)
+	s.Assert().Nil(problem.Error, "Without the error of v2")
+	// Given
+	weather := &model.Weather{Data: &model.Data{Temperature: 10, WindSpeed: 15}}
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Sydney").Return(weather, nil)
+	// When
+	record = httptest.NewRecorder()
+	s.router.ServeHTTP(record, httptest.NewRequest(http.MethodGet, "/v1/weather?city=Sydney", nil))
+	// Then
+	s.Assert().Regexp(`^\{"status":200,"message":"Request successful","data":\{"temperature_degrees":10,"wind_speed":15\},`+
+		`"freshness":\{"source":"primary","fetched_at":"[^"]+","age_seconds":0\}\}$`, record.Body.String())
+}
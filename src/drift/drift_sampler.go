@@ -0,0 +1,194 @@
+// The package drift periodically samples all the weather services, to report how far they drift apart.
+package drift
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ColinSchofield/zai-weather/src/breaker"
+	"github.com/ColinSchofield/zai-weather/src/metrics"
+	"github.com/ColinSchofield/zai-weather/src/model"
+	"github.com/ColinSchofield/zai-weather/src/service"
+
+	"github.com/sirupsen/logrus"
+)
+
+// The drift.Sampler interface samples the weather services, reporting on their drift.
+type Sampler interface {
+	Run(ctx context.Context, interval time.Duration)
+	Sample(ctx context.Context)
+	Report() model.DriftReport
+}
+
+// A Provider is a weather service to be sampled, through its circuit breaker (so that a service whose breaker is open,
+// or forced open, is not called).
+type Provider struct {
+	Name    string
+	Fetcher service.WeatherFetcher
+	Breaker breaker.CircuitBreaker
+	Timeout func() time.Duration // Read on each sample, as the configuration may be reloaded
+}
+
+type DefaultSampler struct {
+	log       *logrus.Logger
+	cities    []string
+	providers []Provider
+
+	mu        sync.Mutex
+	rounds    int
+	updatedAt *time.Time
+	stats     map[string]*providerStats
+}
+
+var _ Sampler = (*DefaultSampler)(nil)
+
+// The running mean and variance (using Welford's algorithm) of the deltas from the consensus.
+type runningStats struct {
+	count int
+	mean  float64
+	m2    float64
+}
+
+type providerStats struct {
+	temperature runningStats
+	windSpeed   runningStats
+}
+
+// NewSampler returns the default struct for the drift sampler.
+func NewSampler(log *logrus.Logger, cities []string, providers ...Provider) *DefaultSampler {
+	stats := make(map[string]*providerStats)
+	for _, provider := range providers {
+		stats[provider.Name] = &providerStats{}
+	}
+
+	return &DefaultSampler{
+		log:       log,
+		cities:    cities,
+		providers: providers,
+		stats:     stats,
+	}
+}
+
+// Run samples the weather services at the given interval, until the context is done.
+func (d *DefaultSampler) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.Sample(ctx)
+		}
+	}
+}
+
+// Sample queries all the weather services for each of the cities, recording the delta of each service from their
+// consensus. A city is skipped, should fewer than two of the weather services have answered.
+func (d *DefaultSampler) Sample(ctx context.Context) {
+	for _, city := range d.cities {
+		readings := d.fetchAll(ctx, city)
+		if len(readings) < 2 {
+			d.log.WithField("location", city).Warn("Too few weather services answered to sample their drift")
+			continue
+		}
+
+		var temperature, windSpeed float64
+		for _, data := range readings {
+			temperature += float64(data.Temperature)
+			windSpeed += float64(data.WindSpeed)
+		}
+		temperature /= float64(len(readings))
+		windSpeed /= float64(len(readings))
+
+		d.mu.Lock()
+		for name, data := range readings {
+			d.stats[name].temperature.add(float64(data.Temperature) - temperature)
+			d.stats[name].windSpeed.add(float64(data.WindSpeed) - windSpeed)
+		}
+		d.mu.Unlock()
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now()
+	d.rounds++
+	d.updatedAt = &now
+	for name, stats := range d.stats {
+		metrics.ProviderBias.WithLabelValues(name, "temperature").Set(stats.temperature.mean)
+		metrics.ProviderBias.WithLabelValues(name, "wind_speed").Set(stats.windSpeed.mean)
+		metrics.ProviderVariance.WithLabelValues(name, "temperature").Set(stats.temperature.variance())
+		metrics.ProviderVariance.WithLabelValues(name, "wind_speed").Set(stats.windSpeed.variance())
+	}
+}
+
+// Report returns the bias and variance of each weather service, from the consensus of all the services.
+func (d *DefaultSampler) Report() model.DriftReport {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	report := model.DriftReport{
+		Cities:    d.cities,
+		Rounds:    d.rounds,
+		UpdatedAt: d.updatedAt,
+		Providers: []model.ProviderDrift{},
+	}
+	for _, provider := range d.providers {
+		stats := d.stats[provider.Name]
+		report.Providers = append(report.Providers, model.ProviderDrift{
+			Provider:    provider.Name,
+			Samples:     stats.temperature.count,
+			Temperature: model.Deviation{Bias: stats.temperature.mean, Variance: stats.temperature.variance()},
+			WindSpeed:   model.Deviation{Bias: stats.windSpeed.mean, Variance: stats.windSpeed.variance()},
+		})
+	}
+
+	return report
+}
+
+// Fetch the city from all the weather services concurrently (skipping those whose circuit breaker is open), returning
+// the readings of those that answered.
+func (d *DefaultSampler) fetchAll(ctx context.Context, city string) map[string]model.Data {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	readings := make(map[string]model.Data)
+	for _, provider := range d.providers {
+		wg.Add(1)
+		go func(provider Provider) {
+			defer wg.Done()
+			fetchCtx, cancel := context.WithTimeout(ctx, provider.Timeout())
+			defer cancel()
+
+			res, err := provider.Breaker.Execute(func() (interface{}, error) {
+				return provider.Fetcher.FetchWeather(fetchCtx, city)
+			})
+			if err != nil {
+				d.log.WithError(err).WithField("location", city).Debug("Failed to sample ", provider.Name)
+				return
+			}
+			mu.Lock()
+			readings[provider.Name] = *res.(*model.Weather).Data
+			mu.Unlock()
+		}(provider)
+	}
+	wg.Wait()
+
+	return readings
+}
+
+func (r *runningStats) add(value float64) {
+	r.count++
+	delta := value - r.mean
+	r.mean += delta / float64(r.count)
+	r.m2 += delta * (value - r.mean)
+}
+
+func (r *runningStats) variance() float64 {
+	if r.count < 2 {
+		return 0
+	}
+
+	return r.m2 / float64(r.count-1)
+}
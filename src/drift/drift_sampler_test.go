@@ -0,0 +1,130 @@
+package drift_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ColinSchofield/zai-weather/src/breaker"
+	"github.com/ColinSchofield/zai-weather/src/drift"
+	mock "github.com/ColinSchofield/zai-weather/src/mock"
+	"github.com/ColinSchofield/zai-weather/src/model"
+
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/suite"
+)
+
+type DriftSamplerTestSuite struct {
+	suite.Suite
+
+	ctx          context.Context
+	mockPrimary  *mock.MockWeatherFetcher
+	mockFailover *mock.MockWeatherFetcher
+	breakers     *breaker.DefaultFactory
+	timeout      time.Duration
+	sampler      drift.Sampler
+}
+
+func TestDriftSamplerSuite(t *testing.T) {
+	suite.Run(t, new(DriftSamplerTestSuite))
+}
+
+func (s *DriftSamplerTestSuite) SetupTest() {
+	ctrl := gomock.NewController(s.T())
+	s.ctx = context.Background()
+	s.mockPrimary = mock.NewMockWeatherFetcher(ctrl)
+	s.mockFailover = mock.NewMockWeatherFetcher(ctrl)
+	breakerStore, _ := breaker.NewFileStore("")
+	s.breakers = breaker.NewFactory(logrus.New(), breakerStore)
+	settings := func() breaker.Settings {
+		return breaker.Settings{Policy: breaker.Consecutive, ConsecutiveFailures: 5, HalfOpenRequests: 1}
+	}
+	s.timeout = time.Second
+	timeout := func() time.Duration { return s.timeout }
+	s.sampler = drift.NewSampler(logrus.New(), []string{"Melbourne", "Sydney"},
+		drift.Provider{
+			Name: "primary", Fetcher: s.mockPrimary, Breaker: s.breakers.New("primary", "primary", settings),
+			Timeout: timeout,
+		},
+		drift.Provider{
+			Name: "failover", Fetcher: s.mockFailover, Breaker: s.breakers.New("failover", "failover", settings),
+			Timeout: timeout,
+		},
+	)
+}
+
+func (s *DriftSamplerTestSuite) Test_NothingSampledYet() {
+	// When
+	report := s.sampler.Report()
+	// Then
+	s.Assert().Equal(0, report.Rounds)
+	s.Assert().Nil(report.UpdatedAt)
+	s.Assert().Len(report.Providers, 2)
+}
+
+func (s *DriftSamplerTestSuite) Test_BiasAndVarianceFromTheConsensus() {
+	// Given
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(weather(12, 10), nil)
+	s.mockFailover.EXPECT().FetchWeather(gomock.Any(), "Melbourne").Return(weather(10, 20), nil)
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), "Sydney").Return(weather(24, 10), nil)
+	s.mockFailover.EXPECT().FetchWeather(gomock.Any(), "Sydney").Return(weather(20, 20), nil)
+	// When
+	s.sampler.Sample(s.ctx)
+	report := s.sampler.Report()
+	// Then
+	s.Assert().Equal(1, report.Rounds)
+	s.Assert().NotNil(report.UpdatedAt)
+	primary, failover := report.Providers[0], report.Providers[1]
+	s.Assert().Equal("primary", primary.Provider)
+	s.Assert().Equal(2, primary.Samples)
+	s.Assert().Equal(1.5, primary.Temperature.Bias, "the primary runs warmer")
+	s.Assert().Equal(0.5, primary.Temperature.Variance)
+	s.Assert().Equal(-5.0, primary.WindSpeed.Bias)
+	s.Assert().Equal(-1.5, failover.Temperature.Bias)
+	s.Assert().Equal(5.0, failover.WindSpeed.Bias)
+}
+
+func (s *DriftSamplerTestSuite) Test_CitiesWithTooFewReadingsAreSkipped() {
+	// Given
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), gomock.Any()).Times(2).Return(weather(12, 10), nil)
+	s.mockFailover.EXPECT().FetchWeather(gomock.Any(), gomock.Any()).Times(2).Return(nil, errors.New("Server is down!"))
+	// When
+	s.sampler.Sample(s.ctx)
+	report := s.sampler.Report()
+	// Then
+	s.Assert().Equal(1, report.Rounds)
+	s.Assert().Equal(0, report.Providers[0].Samples)
+}
+
+func (s *DriftSamplerTestSuite) Test_OpenBreakersAreSkipped() {
+	// Given
+	_, _ = s.breakers.SetMode("failover", breaker.ForcedOpen)
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), gomock.Any()).Times(2).Return(weather(12, 10), nil)
+	// When
+	s.sampler.Sample(s.ctx)
+	report := s.sampler.Report()
+	// Then
+	s.Assert().Equal(0, report.Providers[1].Samples, "the failover is not called")
+}
+
+func (s *DriftSamplerTestSuite) Test_TimeoutIsReadOnEachSample() {
+	// Given
+	s.timeout = time.Millisecond
+	s.mockPrimary.EXPECT().FetchWeather(gomock.Any(), gomock.Any()).Times(2).Return(weather(12, 10), nil)
+	s.mockFailover.EXPECT().FetchWeather(gomock.Any(), gomock.Any()).Times(2).DoAndReturn(
+		func(ctx context.Context, _ string) (*model.Weather, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+	// When
+	s.sampler.Sample(s.ctx)
+	report := s.sampler.Report()
+	// Then
+	s.Assert().Equal(0, report.Providers[1].Samples, "the failover timed out")
+}
+
+func weather(temperature, windSpeed int) *model.Weather {
+	return &model.Weather{Data: &model.Data{Temperature: temperature, WindSpeed: windSpeed}}
+}
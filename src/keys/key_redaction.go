@@ -0,0 +1,93 @@
+package keys
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// The RedactionHook masks the access keys within every log entry (i.e. its message and fields), such as the request
+// URLs of the weather services found within errors and the HTTP debug output.
+type RedactionHook struct {
+	mu       sync.RWMutex
+	keys     []string
+	replacer *strings.Replacer
+}
+
+var _ logrus.Hook = (*RedactionHook)(nil)
+
+// NewRedactionHook returns a hook that masks the (non empty) access keys given.
+func NewRedactionHook(keys ...string) *RedactionHook {
+	hook := &RedactionHook{}
+	hook.AddKeys(keys...)
+
+	return hook
+}
+
+// AddKeys masks further access keys (i.e. when the configuration is reloaded). The keys that were replaced are still
+// masked, as these may yet be found within the logs of the requests in flight.
+func (h *RedactionHook) AddKeys(keys ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, key := range keys {
+		if key = strings.TrimSpace(key); key != "" && !h.redacts(key) {
+			h.keys = append(h.keys, key)
+		}
+	}
+	var pairs []string
+	for _, key := range h.keys {
+		pairs = append(pairs, key, Mask(key))
+	}
+	h.replacer = strings.NewReplacer(pairs...)
+}
+
+// Levels returns all the log levels, as a key must never be logged.
+func (h *RedactionHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire masks the access keys within the log entry.
+func (h *RedactionHook) Fire(entry *logrus.Entry) error {
+	entry.Message = h.Redact(entry.Message)
+
+	// The fields are copied, as these may be shared with other entries
+	data := make(logrus.Fields, len(entry.Data))
+	for name, value := range entry.Data {
+		switch v := value.(type) {
+		case string:
+			data[name] = h.Redact(v)
+		case error:
+			data[name] = errors.New(h.Redact(v.Error()))
+		case fmt.Stringer:
+			data[name] = h.Redact(v.String())
+		default:
+			data[name] = value
+		}
+	}
+	entry.Data = data
+
+	return nil
+}
+
+// Redact returns the text with the access keys masked.
+func (h *RedactionHook) Redact(text string) string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.replacer.Replace(text)
+}
+
+// Returns true if the key is already masked.
+func (h *RedactionHook) redacts(key string) bool {
+	for _, redacted := range h.keys {
+		if redacted == key {
+			return true
+		}
+	}
+
+	return false
+}
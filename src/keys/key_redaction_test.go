@@ -0,0 +1,26 @@
+package keys
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RedactionOfTheLogs(t *testing.T) {
+	// Given
+	var out bytes.Buffer
+	log := logrus.New()
+	log.SetOutput(&out)
+	log.AddHook(NewRedactionHook("1cadfad44c3387c66d14a12cb33f282e", " ", ""))
+	// When
+	log.WithError(errors.New("GET http://api.weatherstack.com/current?access_key=1cadfad44c3387c66d14a12cb33f282e")).
+		WithField("key", "1cadfad44c3387c66d14a12cb33f282e").
+		Warn("Failed with 1cadfad44c3387c66d14a12cb33f282e")
+	// Then
+	assert.NotContains(t, out.String(), "1cadfad44c3387c66d14a12cb33f282e")
+	assert.Contains(t, out.String(), "access_key=****************************282e")
+	assert.Contains(t, out.String(), "Failed with ****************************282e")
+}
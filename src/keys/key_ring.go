@@ -0,0 +1,213 @@
+// The package keys rotates the access keys of a weather service, tracking the health of each key.
+package keys
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ColinSchofield/zai-weather/src/metrics"
+	"github.com/ColinSchofield/zai-weather/src/model"
+)
+
+// The Strategy used to rotate the access keys.
+type Strategy string
+
+const (
+	// RoundRobin uses each of the healthy keys in turn, spreading the load across the accounts
+	RoundRobin Strategy = "round-robin"
+	// Failover uses the same key until it is rejected, then falls through to the next healthy key
+	Failover Strategy = "failover"
+)
+
+// The keys.Ring interface hands out the access keys of a weather service.
+type Ring interface {
+	Next() (string, bool)
+	Fail(key string, cooldown time.Duration)
+	RetryAfter() time.Duration
+	Status() []model.KeyStatus
+	SetKeys(keys ...string)
+}
+
+type DefaultRing struct {
+	provider string
+	strategy Strategy
+
+	mu      sync.Mutex
+	keys    []*keyHealth
+	current int
+	now     func() time.Time
+}
+
+var _ Ring = (*DefaultRing)(nil)
+
+type keyHealth struct {
+	key            string
+	uses           int
+	failures       int
+	unhealthyUntil time.Time
+}
+
+// ParseStrategy returns the Strategy with the given name.
+func ParseStrategy(name string) (Strategy, error) {
+	switch strategy := Strategy(name); strategy {
+	case RoundRobin, Failover:
+		return strategy, nil
+	default:
+		return "", fmt.Errorf("unknown key rotation strategy %q", name)
+	}
+}
+
+// NewRing returns a ring of the (non empty and distinct) access keys of the weather service.
+func NewRing(provider string, strategy Strategy, keys ...string) *DefaultRing {
+	ring := &DefaultRing{
+		provider: provider,
+		strategy: strategy,
+		now:      time.Now,
+	}
+	ring.SetKeys(keys...)
+
+	return ring
+}
+
+// SetKeys replaces the access keys of the ring (i.e. when the configuration is reloaded), keeping the health of
+// those keys that remain.
+func (r *DefaultRing) SetKeys(keys ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing := make(map[string]*keyHealth)
+	for _, health := range r.keys {
+		existing[health.key] = health
+	}
+
+	var healths []*keyHealth
+	for _, key := range keys {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		if health, found := existing[key]; found {
+			healths = append(healths, health)
+			delete(existing, key)
+		} else if !r.contains(healths, key) {
+			healths = append(healths, &keyHealth{key: key})
+			metrics.KeyHealthy.WithLabelValues(r.provider, Mask(key)).Set(1)
+		}
+	}
+	for key := range existing {
+		metrics.KeyHealthy.DeleteLabelValues(r.provider, Mask(key))
+	}
+	// Should there be no keys at all, the empty key is used (leaving it to the weather service to reject the call)
+	if len(healths) == 0 {
+		healths = append(healths, &keyHealth{})
+	}
+
+	r.keys = healths
+	r.current = 0
+}
+
+// Returns true if the key is one of the healths.
+func (r *DefaultRing) contains(healths []*keyHealth, key string) bool {
+	for _, health := range healths {
+		if health.key == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Next returns the next healthy access key, or false if none of the keys are healthy.
+func (r *DefaultRing) Next() (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	for i := range r.keys {
+		index := (r.current + i) % len(r.keys)
+		health := r.keys[index]
+		if now.Before(health.unhealthyUntil) {
+			continue
+		}
+
+		r.current = index
+		if r.strategy == RoundRobin {
+			r.current = (index + 1) % len(r.keys)
+		}
+		health.uses++
+		metrics.KeyHealthy.WithLabelValues(r.provider, Mask(health.key)).Set(1)
+		return health.key, true
+	}
+
+	return "", false
+}
+
+// Fail marks the access key as unhealthy (i.e. it was rejected by the weather service) for the cooldown.
+func (r *DefaultRing) Fail(key string, cooldown time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, health := range r.keys {
+		if health.key == key {
+			health.failures++
+			health.unhealthyUntil = r.now().Add(cooldown)
+			metrics.KeyHealthy.WithLabelValues(r.provider, Mask(key)).Set(0)
+		}
+	}
+}
+
+// RetryAfter returns how long until the first of the unhealthy keys is healthy again.
+func (r *DefaultRing) RetryAfter() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	var retryAfter time.Duration
+	for _, health := range r.keys {
+		wait := health.unhealthyUntil.Sub(now)
+		if wait <= 0 {
+			return 0
+		}
+		if retryAfter == 0 || wait < retryAfter {
+			retryAfter = wait
+		}
+	}
+
+	return retryAfter
+}
+
+// Status returns the health of each of the access keys, which are masked.
+func (r *DefaultRing) Status() []model.KeyStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	statuses := []model.KeyStatus{}
+	for _, health := range r.keys {
+		status := model.KeyStatus{
+			Provider: r.provider,
+			Key:      Mask(health.key),
+			Healthy:  !now.Before(health.unhealthyUntil),
+			Uses:     health.uses,
+			Failures: health.failures,
+		}
+		if !status.Healthy {
+			until := health.unhealthyUntil
+			status.UnhealthyUntil = &until
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses
+}
+
+// Mask hides all but the last four characters of the access key.
+func Mask(key string) string {
+	if len(key) <= 4 {
+		return strings.Repeat("*", len(key))
+	}
+
+	return strings.Repeat("*", len(key)-4) + key[len(key)-4:]
+}
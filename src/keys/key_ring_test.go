@@ -0,0 +1,110 @@
+package keys
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type KeyRingTestSuite struct {
+	suite.Suite
+
+	now time.Time
+}
+
+func TestKeyRingSuite(t *testing.T) {
+	suite.Run(t, new(KeyRingTestSuite))
+}
+
+func (s *KeyRingTestSuite) SetupTest() {
+	s.now = time.Date(2023, time.December, 15, 10, 0, 0, 0, time.UTC)
+}
+
+func (s *KeyRingTestSuite) newRing(strategy Strategy, keys ...string) *DefaultRing {
+	ring := NewRing("primary", strategy, keys...)
+	ring.now = func() time.Time { return s.now }
+	return ring
+}
+
+func (s *KeyRingTestSuite) next(ring *DefaultRing) string {
+	key, ok := ring.Next()
+	s.Require().True(ok)
+	return key
+}
+
+func (s *KeyRingTestSuite) Test_RoundRobin() {
+	// Given
+	ring := s.newRing(RoundRobin, "a", "b", "c")
+	// Then
+	s.Assert().Equal([]string{"a", "b", "c", "a"}, []string{s.next(ring), s.next(ring), s.next(ring), s.next(ring)})
+	// When
+	ring.Fail("b", time.Minute)
+	// Then
+	s.Assert().Equal([]string{"c", "a", "c"}, []string{s.next(ring), s.next(ring), s.next(ring)}, "b is skipped")
+	// When
+	s.now = s.now.Add(2 * time.Minute)
+	// Then
+	s.Assert().Equal([]string{"a", "b"}, []string{s.next(ring), s.next(ring)}, "b is healthy again")
+}
+
+func (s *KeyRingTestSuite) Test_Failover() {
+	// Given
+	ring := s.newRing(Failover, "a", "b")
+	// Then
+	s.Assert().Equal([]string{"a", "a"}, []string{s.next(ring), s.next(ring)})
+	// When
+	ring.Fail("a", time.Minute)
+	// Then
+	s.Assert().Equal([]string{"b", "b"}, []string{s.next(ring), s.next(ring)}, "falls through to b")
+}
+
+func (s *KeyRingTestSuite) Test_NoHealthyKeys() {
+	// Given
+	ring := s.newRing(Failover, "a", "b")
+	// When
+	ring.Fail("a", time.Minute)
+	ring.Fail("b", time.Hour)
+	_, ok := ring.Next()
+	// Then
+	s.Assert().False(ok)
+	s.Assert().Equal(time.Minute, ring.RetryAfter(), "until the first key is healthy again")
+	statuses := ring.Status()
+	s.Assert().False(statuses[0].Healthy)
+	s.Assert().Equal(1, statuses[0].Failures)
+	s.Assert().Equal(s.now.Add(time.Minute), *statuses[0].UnhealthyUntil)
+}
+
+func (s *KeyRingTestSuite) Test_EmptyAndDuplicateKeys() {
+	// Given
+	ring := s.newRing(RoundRobin, "a", "", " a ")
+	// Then
+	s.Assert().Len(ring.Status(), 1)
+	// Given
+	ring = s.newRing(RoundRobin)
+	// Then
+	s.Assert().Equal("", s.next(ring), "the weather service is left to reject the call")
+}
+
+func (s *KeyRingTestSuite) Test_SetKeys() {
+	// Given
+	ring := s.newRing(Failover, "a", "b")
+	ring.Fail("a", time.Minute)
+	// When
+	ring.SetKeys("c", "a", "c")
+	// Then
+	statuses := ring.Status()
+	s.Require().Len(statuses, 2)
+	s.Assert().Equal("*", statuses[0].Key)
+	s.Assert().True(statuses[0].Healthy)
+	s.Assert().False(statuses[1].Healthy, "the health of a remaining key is kept")
+	s.Assert().Equal(1, statuses[1].Failures)
+	s.Assert().Equal("c", s.next(ring))
+}
+
+func (s *KeyRingTestSuite) Test_Mask() {
+	s.Assert().Equal("**************282e", Mask("abcdefghijklmf282e"))
+	s.Assert().Equal("***", Mask("abc"))
+	_, err := ParseStrategy("random")
+	s.Assert().Error(err)
+}
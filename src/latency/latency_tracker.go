@@ -0,0 +1,67 @@
+// The package latency records the most recent response times, so that percentiles may be derived from them.
+package latency
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// The latency.Tracker interface records response times over a sliding window.
+type Tracker interface {
+	Record(elapsed time.Duration)
+	Percentile(p float64) (time.Duration, bool)
+}
+
+type DefaultTracker struct {
+	mu         sync.Mutex
+	samples    []time.Duration
+	next       int
+	count      int
+	minSamples int
+}
+
+var _ Tracker = (*DefaultTracker)(nil)
+
+// NewTracker returns a tracker that holds (at most) the last size samples. Percentiles are only reported once
+// minSamples have been recorded, as the result would otherwise be meaningless.
+func NewTracker(size, minSamples int) *DefaultTracker {
+	return &DefaultTracker{
+		samples:    make([]time.Duration, size),
+		minSamples: minSamples,
+	}
+}
+
+// Record adds a response time to the window, overwriting the oldest sample once the window is full.
+func (t *DefaultTracker) Record(elapsed time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.samples[t.next] = elapsed
+	t.next = (t.next + 1) % len(t.samples)
+	if t.count < len(t.samples) {
+		t.count++
+	}
+}
+
+// Percentile returns the response time below which the fraction p (i.e. 0.95) of the samples fall.
+func (t *DefaultTracker) Percentile(p float64) (time.Duration, bool) {
+	t.mu.Lock()
+	sorted := make([]time.Duration, t.count)
+	copy(sorted, t.samples[:t.count])
+	t.mu.Unlock()
+
+	if len(sorted) == 0 || len(sorted) < t.minSamples {
+		return 0, false
+	}
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	index := int(p*float64(len(sorted))+0.5) - 1
+	if index < 0 {
+		index = 0
+	} else if index >= len(sorted) {
+		index = len(sorted) - 1
+	}
+
+	return sorted[index], true
+}
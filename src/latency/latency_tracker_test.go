@@ -0,0 +1,61 @@
+package latency_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ColinSchofield/zai-weather/src/latency"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type LatencyTrackerTestSuite struct {
+	suite.Suite
+
+	tracker latency.Tracker
+}
+
+func TestLatencyTrackerSuite(t *testing.T) {
+	suite.Run(t, new(LatencyTrackerTestSuite))
+}
+
+func (s *LatencyTrackerTestSuite) SetupTest() {
+	s.tracker = latency.NewTracker(10, 5)
+}
+
+func (s *LatencyTrackerTestSuite) Test_NotEnoughSamples() {
+	// When
+	s.tracker.Record(time.Second)
+	_, ok := s.tracker.Percentile(0.95)
+	// Then
+	s.Assert().False(ok, "a single sample is not enough")
+}
+
+func (s *LatencyTrackerTestSuite) Test_PercentileOfSamples() {
+	// When
+	for i := 1; i <= 10; i++ {
+		s.tracker.Record(time.Duration(i) * time.Millisecond)
+	}
+	p50, ok := s.tracker.Percentile(0.5)
+	// Then
+	s.Assert().True(ok)
+	s.Assert().Equal(5*time.Millisecond, p50)
+	// When
+	p90, _ := s.tracker.Percentile(0.9)
+	// Then
+	s.Assert().Equal(9*time.Millisecond, p90)
+}
+
+func (s *LatencyTrackerTestSuite) Test_OldestSamplesAreOverwritten() {
+	// When
+	for i := 1; i <= 10; i++ {
+		s.tracker.Record(time.Second)
+	}
+	for i := 1; i <= 10; i++ {
+		s.tracker.Record(time.Millisecond)
+	}
+	p100, ok := s.tracker.Percentile(1)
+	// Then
+	s.Assert().True(ok)
+	s.Assert().Equal(time.Millisecond, p100, "the slow samples have left the window")
+}
@@ -1,13 +1,37 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ColinSchofield/zai-weather/src/breaker"
+	"github.com/ColinSchofield/zai-weather/src/cache"
 	"github.com/ColinSchofield/zai-weather/src/config"
 	"github.com/ColinSchofield/zai-weather/src/controller"
+	"github.com/ColinSchofield/zai-weather/src/drift"
+	"github.com/ColinSchofield/zai-weather/src/keys"
+	"github.com/ColinSchofield/zai-weather/src/quota"
 	"github.com/ColinSchofield/zai-weather/src/service"
+	"github.com/ColinSchofield/zai-weather/src/stream"
+	"github.com/ColinSchofield/zai-weather/src/warm"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
-	"github.com/sony/gobreaker"
+)
+
+const (
+	// The command that validates the configuration, rather than running the service.
+	validateCommand = "validate-config"
+	// The requests in flight are given this long to complete, once the service is asked to stop.
+	shutdownTimeout = 10 * time.Second
 )
 
 // Run a microservice to serve requests for temperature (in celsius) and wind speed (in km/hr).
@@ -18,44 +42,209 @@ import (
 //
 // See https://en.wikipedia.org/wiki/Circuit_breaker_design_pattern.
 func main() {
+	configFile := flag.String("config", os.Getenv("CONFIG_FILE"), "the (YAML or TOML) configuration file")
+	printConfig := flag.Bool("print-config", false, "print the effective configuration (with the secrets redacted)")
+	// The validate-config command only checks the configuration (i.e. before deploying)
+	args := os.Args[1:]
+	validateOnly := len(args) > 0 && args[0] == validateCommand
+	if validateOnly {
+		args = args[1:]
+	}
+	_ = flag.CommandLine.Parse(args)
+	if validateOnly {
+		os.Exit(validateConfig(*configFile))
+	}
+
 	log := logrus.New()
-	cfg, err := config.LoadConfig()
+	cfg, err := config.LoadConfig(*configFile)
 	if err != nil {
 		log.WithError(err).Fatal("failed to load the configuration")
 	}
+	if *printConfig {
+		if err := cfg.WriteRedacted(os.Stdout); err != nil {
+			log.WithError(err).Fatal("failed to print the configuration")
+		}
+		return
+	}
+	// The access keys are masked in all the logs (including the HTTP debug output of the weather services)
+	redaction := keys.NewRedactionHook(cfg.Secrets()...)
+	log.AddHook(redaction)
+	if cfg.HTTPDebug {
+		log.SetLevel(logrus.DebugLevel)
+	}
+	// The service runs until it is interrupted (or terminated), then shuts down gracefully
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
+	// The configuration is reloaded whenever the files change (or on a SIGHUP)
+	reloader := config.NewReloader(log, *configFile, cfg)
+	go reloader.Watch(ctx, time.Duration(cfg.ReloadIntervalSeconds)*time.Second)
+
+	// The calls to each weather service are tracked against its budget
+	quotaStore := quota.NewFileStore(log, cfg.QuotaFile)
+	go quotaStore.Run(ctx, time.Duration(cfg.QuotaFlushSeconds)*time.Second)
+	primaryQuota := quota.NewTracker(log, quotaStore, "Weather Stack",
+		cfg.PrimaryDailyBudget, cfg.PrimaryMonthlyBudget, cfg.PrimaryBillingDay, cfg.QuotaReserve)
+	failoverQuota := quota.NewTracker(log, quotaStore, "Open Weather Map",
+		cfg.FailoverDailyBudget, cfg.FailoverMonthlyBudget, cfg.FailoverBillingDay, cfg.QuotaReserve)
+	weatherStack := service.NewWeatherStack(reloader, log, primaryQuota)
+	openWeatherMap := service.NewOpenWeatherMap(reloader, log, failoverQuota)
+	var primary, failover service.WeatherFetcher = quota.NewFetcher(primaryQuota, weatherStack),
+		quota.NewFetcher(failoverQuota, openWeatherMap)
+	if !cfg.PrimaryEnabled {
+		primary = service.NewDisabled("Weather Stack")
+	}
+	if !cfg.FailoverEnabled {
+		failover = service.NewDisabled("Open Weather Map")
+	}
+
+	// The circuit breakers are built from the (reloadable) configuration of each weather service, with the modes
+	// forced by an operator (see the admin API) kept until these are released
+	breakerStore, err := breaker.NewFileStore(cfg.AdminBreakerFile)
+	if err != nil {
+		log.WithError(err).Fatal("failed to load the modes of the circuit breakers")
+	}
+	breakers := breaker.NewFactory(log, breakerStore)
+	primaryBreaker := breakers.New("primary", "Weather Stack (primary)", func() breaker.Settings {
+		return breaker.PrimarySettings(reloader.Current())
+	})
+	failoverBreaker := breakers.New("failover", "Open Weather Map (failover)", func() breaker.Settings {
+		return breaker.FailoverSettings(reloader.Current())
+	})
 	weatherController := controller.NewWeatherController(
-		cfg,
+		reloader,
 		log,
-		service.NewWeatherStack(cfg, log),
-		service.NewOpenWeatherMap(cfg, log),
-		gobreaker.NewCircuitBreaker(
-			gobreaker.Settings{
-				Name: "Weather Stack (primary)",
-				ReadyToTrip: func(counts gobreaker.Counts) bool {
-					failureRatio := float64(counts.TotalFailures) / float64(counts.Requests)
-					return counts.Requests >= cfg.PrimaryRequests && failureRatio >= cfg.PrimaryFailureRatio
-				},
+		primary,
+		failover,
+		primaryBreaker,
+		failoverBreaker,
+	)
+
+	// Only the enabled weather services are sampled for drift, through their circuit breakers
+	var driftProviders []drift.Provider
+	if cfg.PrimaryEnabled {
+		driftProviders = append(driftProviders, drift.Provider{
+			Name:    "Weather Stack",
+			Fetcher: primary,
+			Breaker: primaryBreaker,
+			Timeout: func() time.Duration {
+				return time.Duration(reloader.Current().PrimaryTimeoutSeconds) * time.Second
 			},
-		),
-		gobreaker.NewCircuitBreaker(
-			gobreaker.Settings{
-				Name: "Open Weather Map (failover)",
-				ReadyToTrip: func(counts gobreaker.Counts) bool {
-					failureRatio := float64(counts.TotalFailures) / float64(counts.Requests)
-					return counts.Requests >= cfg.FailoverRequests && failureRatio >= cfg.FailoverFailureRatio
-				},
+		})
+	}
+	if cfg.FailoverEnabled {
+		driftProviders = append(driftProviders, drift.Provider{
+			Name:    "Open Weather Map",
+			Fetcher: failover,
+			Breaker: failoverBreaker,
+			Timeout: func() time.Duration {
+				return time.Duration(reloader.Current().FailoverTimeoutSeconds) * time.Second
 			},
-		),
+		})
+	}
+	sampler := drift.NewSampler(log, cfg.DriftCities, driftProviders...)
+	if cfg.DriftIntervalSeconds > 0 {
+		go sampler.Run(ctx, time.Duration(cfg.DriftIntervalSeconds)*time.Second)
+	}
+	driftController := controller.NewDriftController(sampler)
+	breakerController := controller.NewBreakerController(log, breakers)
+	cacheController := controller.NewCacheController(
+		log, weatherController.Cache(), weatherController.NotFoundCache(), weatherController,
+	)
+	reloader.OnReload(func(cfg *config.WeatherConfig) {
+		redaction.AddKeys(cfg.Secrets()...)
+		weatherController.Reconfigure(cfg)
+		breakers.Reconfigure()
+		weatherStack.KeyRing().SetKeys(cfg.PrimaryKeys()...)
+		openWeatherMap.KeyRing().SetKeys(cfg.FailoverKeys()...)
+	})
+	// The subscribers to the stream of a location share its refresh (through the cache)
+	hub := stream.NewHub(reloader, log, weatherController)
+	streamController := controller.NewStreamController(reloader, hub)
+	statusController := controller.NewStatusController(
+		[]quota.Tracker{primaryQuota, failoverQuota},
+		[]keys.Ring{weatherStack.KeyRing(), openWeatherMap.KeyRing()},
 	)
 
+	// The cache is restored from the last snapshot (should there be one), and saved at the interval and on shutdown
+	var snapshotter *cache.Snapshotter
+	if cfg.SnapshotFile != "" {
+		snapshotter = cache.NewSnapshotter(log, weatherController.Cache(), cfg.SnapshotFile,
+			time.Duration(cfg.SnapshotMaxAgeSeconds)*time.Second)
+		snapshotter.Load()
+		if cfg.SnapshotIntervalSeconds > 0 {
+			go snapshotter.Run(ctx, time.Duration(cfg.SnapshotIntervalSeconds)*time.Second)
+		}
+	}
+
+	// The popular cities are pre-warmed (through the enabled weather services), ahead of their TTL expiring
+	var warmProviders []warm.Provider
+	if cfg.PrimaryEnabled {
+		warmProviders = append(warmProviders, warm.Provider{Quota: primaryQuota, Breaker: primaryBreaker})
+	}
+	if cfg.FailoverEnabled {
+		warmProviders = append(warmProviders, warm.Provider{Quota: failoverQuota, Breaker: failoverBreaker})
+	}
+	warmer := warm.NewWarmer(reloader, log, weatherController.Cache(), weatherController, warmProviders...)
+	go warmer.Run(ctx)
+
 	log.Info("Starting Zai Weather REST API Service on Port ", cfg.Port)
 
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.Default()
+	// Each failed request is answered with a problem (see RFC 7807), carrying the correlation ID of the request
+	router.Use(controller.CorrelationID(), controller.Problems(log))
+	router.NoRoute(controller.RouteNotFound)
+
+	router.GET("v1/weather", warmer.Track, weatherController.GetWeather)
+	router.GET("v2/weather", warmer.Track, weatherController.GetWeatherV2)
+	router.GET("v1/weather/stream", streamController.GetWeatherStream)
+	router.GET("metrics", gin.WrapH(promhttp.Handler()))
+	router.GET("status", statusController.GetStatus)
+	// The admin API is authenticated by the admin token (and disabled without one)
+	admin := router.Group("admin", controller.AdminAuth(reloader, log))
+	admin.GET("drift", driftController.GetDrift)
+	admin.GET("keys", statusController.GetKeys)
+	admin.GET("breakers", breakerController.GetBreakers)
+	admin.POST("breakers/:id/:action", breakerController.UpdateBreaker)
+	admin.GET("cache", cacheController.GetEntries)
+	admin.DELETE("cache", cacheController.PurgeEntries)
+	admin.GET("cache/:location", cacheController.GetEntry)
+	admin.DELETE("cache/:location", cacheController.PurgeEntry)
+	admin.POST("cache/:location/refresh", cacheController.RefreshEntry)
+	server := &http.Server{Addr: cfg.Port, Handler: router}
+	server.RegisterOnShutdown(hub.Close) // Otherwise the open streams would hold up the shutdown
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.WithError(err).WithField("port_num", cfg.Port).Fatal("failed to run HTTP service")
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	log.Info("Stopping Zai Weather REST API Service")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.WithError(err).Error("failed to stop the HTTP service gracefully")
+	}
+	if err := quotaStore.Flush(); err != nil {
+		log.WithError(err).Error("failed to save the quota usage")
+	}
+	if snapshotter != nil {
+		if err := snapshotter.Save(); err != nil {
+			log.WithError(err).Error("failed to save the cache snapshot")
+		}
+	}
+}
 
-	router.GET("v1/weather", weatherController.GetWeather)
-	if err := router.Run(cfg.Port); err != nil {
-		log.WithError(err).WithField("port_num", cfg.Port).Fatal("failed to run HTTP service")
+// Validate the configuration, printing each of its problems, and returning the exit code.
+func validateConfig(path string) int {
+	if _, err := config.LoadConfig(path); err != nil {
+		fmt.Fprintf(os.Stderr, "The configuration is invalid:\n%v\n", err)
+		return 1
 	}
+
+	fmt.Println("The configuration is valid")
+	return 0
 }
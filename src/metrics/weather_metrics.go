@@ -0,0 +1,95 @@
+// The package metrics provides the Prometheus metrics, used for monitoring and observability.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "weather"
+
+var (
+	// NegativeCacheHits counts the requests for unknown locations, that were answered without calling a weather service.
+	NegativeCacheHits = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "negative_cache_hits_total",
+		Help:      "The number of requests for unknown locations answered from the negative cache.",
+	})
+
+	// ProviderErrors counts the failed fetches from each weather service, by the kind of failure.
+	ProviderErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "provider_errors_total",
+		Help:      "The number of failed fetches from a weather service, by the kind of failure.",
+	}, []string{"provider", "kind"})
+
+	// StackErrorCodes counts the error codes returned by the Weather Stack service.
+	StackErrorCodes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "stack_error_codes_total",
+		Help:      "The number of error codes returned by Weather Stack, by code and type.",
+	}, []string{"code", "type"})
+
+	// ProviderBias is the mean delta of each weather service, from the consensus of all the services.
+	ProviderBias = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "provider_drift_bias",
+		Help:      "The mean delta of a weather service from the consensus of all the services, by measure.",
+	}, []string{"provider", "measure"})
+
+	// ProviderVariance is the variance of the deltas of each weather service, from the consensus of all the services.
+	ProviderVariance = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "provider_drift_variance",
+		Help:      "The variance of the deltas of a weather service from the consensus of all the services, by measure.",
+	}, []string{"provider", "measure"})
+
+	// QuotaRemaining is the remaining budget of each weather service (zero is unlimited).
+	QuotaRemaining = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "quota_remaining",
+		Help:      "The remaining budget of calls to a weather service, by window (zero is unlimited).",
+	}, []string{"provider", "window"})
+
+	// KeyHealthy is one for each healthy access key of a weather service, and zero for those that were rejected.
+	KeyHealthy = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "key_healthy",
+		Help:      "Whether an access key (masked) of a weather service is healthy (one) or was rejected (zero).",
+	}, []string{"provider", "key"})
+
+	// BreakerState is the state of the circuit breaker of each weather service (closed zero, half open one, open two).
+	BreakerState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "breaker_state",
+		Help:      "The state of the circuit breaker of a weather service (0 closed, 1 half open, 2 open).",
+	}, []string{"breaker"})
+
+	// BreakerTransitions counts the changes of state of the circuit breaker of each weather service.
+	BreakerTransitions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "breaker_transitions_total",
+		Help:      "The number of changes of state of the circuit breaker of a weather service, by state.",
+	}, []string{"breaker", "from", "to"})
+
+	// WarmRefreshes counts the pre-warming of each city of the cache, by outcome (refreshed, failed or skipped).
+	WarmRefreshes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "warm_refreshes_total",
+		Help:      "The number of cities pre-warmed in the cache, by outcome (refreshed, failed or skipped).",
+	}, []string{"outcome"})
+
+	// WarmCities is the number of cities being pre-warmed (i.e. the static cities and the most requested).
+	WarmCities = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "warm_cities",
+		Help:      "The number of cities being pre-warmed in the cache.",
+	})
+
+	// StreamSubscribers is the number of subscribers to the streams of the weather information.
+	StreamSubscribers = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "stream_subscribers",
+		Help:      "The number of subscribers to the Server-Sent Events of the weather information.",
+	})
+)
@@ -0,0 +1,11 @@
+package model
+
+import "time"
+
+// The BreakerEvent records a change of state of the circuit breaker of a weather service.
+type BreakerEvent struct {
+	Breaker string    `json:"breaker"`
+	From    string    `json:"from"`
+	To      string    `json:"to"`
+	At      time.Time `json:"at"`
+}
@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+// The BreakerStatus of the circuit breaker of a weather service. The mode is set by an operator (e.g. forced-open),
+// whereas the state is that of the circuit breaker itself (i.e. closed, half-open or open). The breaker is suspended
+// (in the auto mode) until the quota of the weather service is renewed, once it is exhausted.
+type BreakerStatus struct {
+	ID             string        `json:"id"`
+	Name           string        `json:"name"`
+	Mode           string        `json:"mode"`
+	State          string        `json:"state"`
+	SuspendedUntil *time.Time    `json:"suspended_until,omitempty"`
+	Counts         BreakerCounts `json:"counts"`
+}
+
+// The BreakerCounts of the requests, since the circuit breaker last changed state (or its counts were cleared).
+type BreakerCounts struct {
+	Requests             uint32 `json:"requests"`
+	TotalSuccesses       uint32 `json:"total_successes"`
+	TotalFailures        uint32 `json:"total_failures"`
+	TotalExclusions      uint32 `json:"total_exclusions"` // Neither successes nor failures (e.g. cancelled)
+	ConsecutiveSuccesses uint32 `json:"consecutive_successes"`
+	ConsecutiveFailures  uint32 `json:"consecutive_failures"`
+}
+
+// The BreakerReport of all the circuit breakers, along with their most recent changes of state.
+type BreakerReport struct {
+	Breakers []BreakerStatus `json:"breakers"`
+	Events   []BreakerEvent  `json:"events"`
+}
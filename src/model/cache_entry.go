@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// The CacheEntry of the weather information of a location, along with the weather service it came from (or the blend)
+// and its age. An entry that is no longer fresh (i.e. past its TTL) is only returned should the weather services fail.
+type CacheEntry struct {
+	Location   string    `json:"location"`
+	Source     string    `json:"source"`
+	StoredAt   time.Time `json:"stored_at"`
+	AgeSeconds int       `json:"age_seconds"`
+	TTLSeconds int       `json:"ttl_seconds"`
+	Fresh      bool      `json:"fresh"`
+	Weather    *Weather  `json:"weather,omitempty"`
+}
+
+// The CachePurge reports how many entries were purged from the cache.
+type CachePurge struct {
+	Purged int `json:"purged"`
+}
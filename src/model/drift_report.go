@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// The DriftReport summarises how far each weather service drifts from the consensus (mean) of all the services.
+type DriftReport struct {
+	Cities    []string        `json:"cities"`
+	Rounds    int             `json:"rounds"`
+	UpdatedAt *time.Time      `json:"updated_at,omitempty"`
+	Providers []ProviderDrift `json:"providers"`
+}
+
+type ProviderDrift struct {
+	Provider    string    `json:"provider"`
+	Samples     int       `json:"samples"`
+	Temperature Deviation `json:"temperature_degrees"`
+	WindSpeed   Deviation `json:"wind_speed"`
+}
+
+type Deviation struct {
+	Bias     float64 `json:"bias"`
+	Variance float64 `json:"variance"`
+}
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// The KeyStatus reports on the health of an access key (which is masked) of a weather service.
+type KeyStatus struct {
+	Provider       string     `json:"provider"`
+	Key            string     `json:"key"`
+	Healthy        bool       `json:"healthy"`
+	Uses           int        `json:"uses"`
+	Failures       int        `json:"failures"`
+	UnhealthyUntil *time.Time `json:"unhealthy_until,omitempty"`
+}
+
+// The KeyReport lists the access keys of each weather service (for the admin API).
+type KeyReport struct {
+	Keys []KeyStatus `json:"keys"`
+}
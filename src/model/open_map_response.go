@@ -1,14 +1,16 @@
 package model
 
+// The fields are pointers, so that missing values may be told apart from zero values.
 type OpenMapResponse struct {
-	Main Main `json:"main"`
-	Wind Wind `json:"wind"`
+	Main Main  `json:"main"`
+	Wind Wind  `json:"wind"`
+	Dt   int64 `json:"dt"` // The time of the observation (in seconds since the epoch)
 }
 
 type Main struct {
-	Temperature float64 `json:"temp"`
+	Temperature *float64 `json:"temp"`
 }
 
 type Wind struct {
-	WindSpeed float64 `json:"speed"`
+	WindSpeed *float64 `json:"speed"`
 }
@@ -0,0 +1,17 @@
+package model
+
+// The Problem of a failed request (see RFC 7807), returned as application/problem+json. The correlation ID ties the
+// problem to the logs of the request, whereas the members that follow extend the problem (so that the v1 and v2 APIs
+// keep the fields their callers depend upon).
+type Problem struct {
+	Type          string `json:"type"`
+	Title         string `json:"title"`
+	Status        int    `json:"status"`
+	Detail        string `json:"detail,omitempty"`
+	Instance      string `json:"instance,omitempty"`
+	CorrelationID string `json:"correlation_id,omitempty"`
+
+	Message  string    `json:"message,omitempty"`  // The v1 API
+	Location *Location `json:"location,omitempty"` // The v2 API
+	Error    *APIError `json:"error,omitempty"`    // The v2 API
+}
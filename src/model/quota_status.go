@@ -0,0 +1,12 @@
+package model
+
+// The QuotaStatus reports how much of its daily and monthly budgets a weather service has used (zero is unlimited).
+type QuotaStatus struct {
+	Provider         string `json:"provider"`
+	DailyBudget      int    `json:"daily_budget"`
+	DailyUsed        int    `json:"daily_used"`
+	DailyRemaining   int    `json:"daily_remaining"`
+	MonthlyBudget    int    `json:"monthly_budget"`
+	MonthlyUsed      int    `json:"monthly_used"`
+	MonthlyRemaining int    `json:"monthly_remaining"`
+}
@@ -1,15 +1,19 @@
 package model
 
+// The current fields are pointers, so that missing values may be told apart from zero values.
 type StackResponse struct {
 	Error   Error   `json:"error"`
 	Current Current `json:"current"`
 }
 
 type Error struct {
-	Code int `json:"code"`
+	Code int    `json:"code"`
+	Type string `json:"type"`
+	Info string `json:"info"`
 }
 
 type Current struct {
-	Temperature int `json:"temperature"`
-	WindSpeed   int `json:"wind_speed"`
+	ObservationTime string `json:"observation_time"` // The UTC time of day, such as 12:14 PM
+	Temperature     *int   `json:"temperature"`
+	WindSpeed       *int   `json:"wind_speed"`
 }
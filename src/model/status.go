@@ -0,0 +1,7 @@
+package model
+
+// The Status of the service, along with the remaining budgets of the weather services.
+type Status struct {
+	Status string        `json:"status"`
+	Quotas []QuotaStatus `json:"quotas"`
+}
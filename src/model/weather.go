@@ -1,12 +1,55 @@
 package model
 
+import (
+	"encoding/xml"
+	"time"
+)
+
+// The Weather information returned to the caller. The observation time and the max age are the hints of the weather
+// service (where given) as to how long the information stays current, used to derive its TTL within the cache. The
+// freshness is only given in the responses (as it ages from one to the next). It may also be rendered as XML.
 type Weather struct {
-	Status  int    `json:"status"`
-	Message string `json:"message"`
-	Data    *Data  `json:"data,omitempty"`
+	XMLName   xml.Name   `json:"-" xml:"weather"`
+	Status    int        `json:"status" xml:"status"`
+	Message   string     `json:"message" xml:"message"`
+	Data      *Data      `json:"data,omitempty" xml:"data,omitempty"`
+	Blend     *Blend     `json:"blend,omitempty" xml:"blend,omitempty"`
+	Freshness *Freshness `json:"freshness,omitempty" xml:"freshness,omitempty"`
+
+	Values     *Values       `json:"-" xml:"-"` // As given by the weather service, for blending
+	ObservedAt time.Time     `json:"-" xml:"-"`
+	FetchedAt  time.Time     `json:"-" xml:"-"`
+	MaxAge     time.Duration `json:"-" xml:"-"` // From the Cache-Control header
+}
+
+// The Freshness of the weather information: the weather service it came from (or the blend), when the weather service
+// observed it (where given) and when it was fetched. Its age is since it was observed, or otherwise fetched.
+type Freshness struct {
+	Source     string     `json:"source" xml:"source"`
+	ObservedAt *time.Time `json:"observed_at,omitempty" xml:"observed_at,omitempty"`
+	FetchedAt  time.Time  `json:"fetched_at" xml:"fetched_at"`
+	AgeSeconds int        `json:"age_seconds" xml:"age_seconds"`
 }
 
 type Data struct {
-	Temperature int `json:"temperature_degrees"`
-	WindSpeed   int `json:"wind_speed"`
+	Temperature int `json:"temperature_degrees" xml:"temperature_degrees"`
+	WindSpeed   int `json:"wind_speed" xml:"wind_speed"`
+}
+
+// The Blend lists the readings of each weather service, that were blended into the data.
+type Blend struct {
+	Method       string    `json:"method" xml:"method"`
+	Readings     []Reading `json:"readings" xml:"readings>reading"`
+	Disagreement float64   `json:"disagreement" xml:"disagreement"` // The spread of the temperatures (in degrees celsius)
+}
+
+// The Values of the weather service as given (i.e. before being truncated to whole numbers).
+type Values struct {
+	Temperature float64 `json:"temperature_degrees" xml:"temperature_degrees"`
+	WindSpeed   float64 `json:"wind_speed" xml:"wind_speed"`
+}
+
+type Reading struct {
+	Provider string `json:"provider" xml:"provider"`
+	Values
 }
@@ -0,0 +1,22 @@
+package model
+
+// The WeatherV2 envelope of the v2 API. The source tells where the weather information came from (live, cache or
+// stale). Should the request fail, the location and the error are given within a Problem instead.
+type WeatherV2 struct {
+	Source    string     `json:"source"`
+	Location  Location   `json:"location"`
+	Data      *Data      `json:"data,omitempty"`
+	Blend     *Blend     `json:"blend,omitempty"`
+	Freshness *Freshness `json:"freshness,omitempty"`
+}
+
+// The Location the weather information was requested for.
+type Location struct {
+	Name string `json:"name"`
+}
+
+// The APIError of a failed request, with a stable (machine readable) code along with its (human readable) detail.
+type APIError struct {
+	Code   string `json:"code"`
+	Detail string `json:"detail"`
+}
@@ -0,0 +1,40 @@
+package quota
+
+import (
+	"context"
+
+	"github.com/ColinSchofield/zai-weather/src/model"
+	"github.com/ColinSchofield/zai-weather/src/service"
+)
+
+// The Fetcher decorates a service.WeatherFetcher, skipping the weather service (so that the next in the chain is used)
+// once it is approaching its budget. The requests are counted by the weather service itself (with the tracker as its
+// service.UsageRecorder), as each of its access keys tried is a request against the budget.
+type Fetcher struct {
+	tracker Tracker
+	fetcher service.WeatherFetcher
+}
+
+var _ service.WeatherFetcher = (*Fetcher)(nil)
+
+// NewFetcher returns the weather fetcher, skipped once the budget of the tracker is exhausted.
+func NewFetcher(tracker Tracker, fetcher service.WeatherFetcher) *Fetcher {
+	return &Fetcher{
+		tracker: tracker,
+		fetcher: fetcher,
+	}
+}
+
+// The FetchWeather method returns the weather information, unless the budget of the weather service is exhausted.
+func (f *Fetcher) FetchWeather(ctx context.Context, location string) (*model.Weather, error) {
+	if retryAfter, ok := f.tracker.Allow(); !ok {
+		return nil, &service.FetchError{
+			Kind:       service.KindQuotaExceeded,
+			Provider:   f.tracker.Name(),
+			RetryAfter: retryAfter,
+			Err:        ErrBudgetExhausted,
+		}
+	}
+
+	return f.fetcher.FetchWeather(ctx, location)
+}
@@ -0,0 +1,130 @@
+// The package quota tracks the calls made to each weather service, against its daily and monthly budgets.
+package quota
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ColinSchofield/zai-weather/src/atomicfile"
+
+	"github.com/sirupsen/logrus"
+)
+
+// The Usage of a weather service, within its current daily and monthly windows.
+type Usage struct {
+	Day     string `json:"day"`
+	Daily   int    `json:"daily"`
+	Month   string `json:"month"`
+	Monthly int    `json:"monthly"`
+}
+
+// The quota.Store interface persists the usage of each weather service, so that it survives a restart.
+type Store interface {
+	Load(name string) (Usage, bool)
+	Save(name string, usage Usage)
+}
+
+type FileStore struct {
+	log  *logrus.Logger
+	path string
+
+	mu     sync.Mutex
+	usages map[string]Usage
+	dirty  bool
+
+	flushMu sync.Mutex // Only the one write of the file at a time
+}
+
+var _ Store = (*FileStore)(nil)
+
+// NewFileStore reads the usages from the JSON file (if it exists). An unreadable (or corrupt) file is logged and
+// ignored, starting without any usage. An empty path keeps the usages in memory only.
+func NewFileStore(log *logrus.Logger, path string) *FileStore {
+	store := &FileStore{
+		log:    log,
+		path:   path,
+		usages: make(map[string]Usage),
+	}
+	if path == "" {
+		return store
+	}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store
+	} else if err != nil {
+		log.WithError(err).WithField("file", path).Warn("Ignored the unreadable quota usage")
+		return store
+	}
+	if err := json.Unmarshal(content, &store.usages); err != nil {
+		log.WithError(err).WithField("file", path).Warn("Ignored the corrupt quota usage")
+		store.usages = make(map[string]Usage)
+	}
+
+	return store
+}
+
+// Load returns the usage of the weather service, if it has been saved.
+func (f *FileStore) Load(name string) (Usage, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	usage, found := f.usages[name]
+	return usage, found
+}
+
+// Save keeps the usage of the weather service, which is written to the file by the next Flush (i.e. the calls to the
+// weather services do not wait on the disk).
+func (f *FileStore) Save(name string, usage Usage) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.usages[name] = usage
+	f.dirty = true
+}
+
+// Flush writes the usages to the file, should these have been saved since the last flush.
+func (f *FileStore) Flush() error {
+	f.flushMu.Lock()
+	defer f.flushMu.Unlock()
+
+	f.mu.Lock()
+	if !f.dirty || f.path == "" {
+		f.mu.Unlock()
+		return nil
+	}
+	content, err := json.Marshal(f.usages)
+	f.dirty = false
+	f.mu.Unlock()
+	if err == nil {
+		err = atomicfile.Write(f.path, content)
+	}
+
+	if err != nil {
+		f.mu.Lock()
+		f.dirty = true // Retried by the next flush
+		f.mu.Unlock()
+	}
+	return err
+}
+
+// Run flushes the usages at the interval given, until the context is done (the final flush is left to the shutdown).
+func (f *FileStore) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := f.Flush(); err != nil {
+				f.log.WithError(err).WithField("file", f.path).Error("Failed to save the quota usage")
+			}
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package quota
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ColinSchofield/zai-weather/src/metrics"
+	"github.com/ColinSchofield/zai-weather/src/model"
+	"github.com/ColinSchofield/zai-weather/src/service"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrBudgetExhausted is returned (within a service.FetchError) for a weather service that is approaching its budget.
+var ErrBudgetExhausted = errors.New("the budget is exhausted")
+
+// The quota.Tracker interface counts the calls made to a weather service, against its budgets.
+type Tracker interface {
+	Name() string
+	Allow() (time.Duration, bool)
+	Record()
+	Status() model.QuotaStatus
+}
+
+type DefaultTracker struct {
+	log   *logrus.Logger
+	store Store
+
+	name          string
+	dailyBudget   int
+	monthlyBudget int
+	billingDay    int
+	reserve       float64
+
+	mu    sync.Mutex
+	usage Usage
+	now   func() time.Time
+}
+
+var _ Tracker = (*DefaultTracker)(nil)
+
+// NewTracker returns a tracker for the weather service, with its usage loaded from the store. The monthly window
+// begins on the billing day, and the weather service is skipped once within the reserve (i.e. 0.05) of a budget.
+func NewTracker(
+	log *logrus.Logger,
+	store Store,
+	name string,
+	dailyBudget, monthlyBudget, billingDay int,
+	reserve float64,
+) *DefaultTracker {
+	return newTracker(log, store, name, dailyBudget, monthlyBudget, billingDay, reserve, time.Now)
+}
+
+func newTracker(
+	log *logrus.Logger,
+	store Store,
+	name string,
+	dailyBudget, monthlyBudget, billingDay int,
+	reserve float64,
+	now func() time.Time,
+) *DefaultTracker {
+	usage, _ := store.Load(name)
+	t := &DefaultTracker{
+		log:   log,
+		store: store,
+
+		name:          name,
+		dailyBudget:   dailyBudget,
+		monthlyBudget: monthlyBudget,
+		billingDay:    billingDay,
+		reserve:       reserve,
+
+		usage: usage,
+		now:   now,
+	}
+	t.mu.Lock()
+	t.rollover()
+	t.publish()
+	t.mu.Unlock()
+
+	return t
+}
+
+// Name returns the name of the weather service.
+func (t *DefaultTracker) Name() string {
+	return t.name
+}
+
+// Allow returns true, if the weather service may be called. Otherwise it returns how long until the exhausted
+// budget is reset.
+func (t *DefaultTracker) Allow() (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rollover()
+
+	now := t.now().UTC()
+	var retryAfter time.Duration
+	if exhausted(t.usage.Daily, t.dailyBudget, t.reserve) {
+		tomorrow := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+		retryAfter = tomorrow.Sub(now)
+	}
+	if exhausted(t.usage.Monthly, t.monthlyBudget, t.reserve) {
+		retryAfter = max(retryAfter, service.NextBillingWindow(now, t.billingDay).Sub(now))
+	}
+
+	return retryAfter, retryAfter == 0
+}
+
+// Record counts a call made to the weather service, saving the usage to the store.
+func (t *DefaultTracker) Record() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rollover()
+
+	t.usage.Daily++
+	t.usage.Monthly++
+	t.publish()
+	t.store.Save(t.name, t.usage)
+}
+
+// Status returns the budgets of the weather service, along with how much of them has been used.
+func (t *DefaultTracker) Status() model.QuotaStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rollover()
+
+	return model.QuotaStatus{
+		Provider:         t.name,
+		DailyBudget:      t.dailyBudget,
+		DailyUsed:        t.usage.Daily,
+		DailyRemaining:   remaining(t.usage.Daily, t.dailyBudget),
+		MonthlyBudget:    t.monthlyBudget,
+		MonthlyUsed:      t.usage.Monthly,
+		MonthlyRemaining: remaining(t.usage.Monthly, t.monthlyBudget),
+	}
+}
+
+// Reset the usage, once the daily or monthly window has moved on.
+func (t *DefaultTracker) rollover() {
+	now := t.now().UTC()
+	if day := now.Format(time.DateOnly); t.usage.Day != day {
+		t.usage.Day, t.usage.Daily = day, 0
+	}
+	if month := service.NextBillingWindow(now, t.billingDay).Format(time.DateOnly); t.usage.Month != month {
+		t.usage.Month, t.usage.Monthly = month, 0
+	}
+}
+
+func (t *DefaultTracker) publish() {
+	metrics.QuotaRemaining.WithLabelValues(t.name, "daily").Set(float64(remaining(t.usage.Daily, t.dailyBudget)))
+	metrics.QuotaRemaining.WithLabelValues(t.name, "monthly").Set(float64(remaining(t.usage.Monthly, t.monthlyBudget)))
+}
+
+// A budget of zero is unlimited.
+func exhausted(used, budget int, reserve float64) bool {
+	return budget > 0 && float64(used) >= float64(budget)*(1-reserve)
+}
+
+// The remaining budget, or zero when unlimited.
+func remaining(used, budget int) int {
+	if budget <= 0 {
+		return 0
+	}
+
+	return max(budget-used, 0)
+}
@@ -0,0 +1,156 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	mock "github.com/ColinSchofield/zai-weather/src/mock"
+	"github.com/ColinSchofield/zai-weather/src/model"
+	"github.com/ColinSchofield/zai-weather/src/service"
+
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/suite"
+)
+
+type QuotaTrackerTestSuite struct {
+	suite.Suite
+
+	log   *logrus.Logger
+	path  string
+	store *FileStore
+	now   time.Time
+}
+
+func TestQuotaTrackerSuite(t *testing.T) {
+	suite.Run(t, new(QuotaTrackerTestSuite))
+}
+
+func (s *QuotaTrackerTestSuite) SetupTest() {
+	s.log = logrus.New()
+	s.path = filepath.Join(s.T().TempDir(), "quota.json")
+	s.store = NewFileStore(s.log, s.path)
+	s.now = time.Date(2023, time.December, 15, 10, 0, 0, 0, time.UTC)
+}
+
+func (s *QuotaTrackerTestSuite) newTracker(daily, monthly int) *DefaultTracker {
+	return newTracker(s.log, s.store, "primary", daily, monthly, 1, 0.2, func() time.Time { return s.now })
+}
+
+func (s *QuotaTrackerTestSuite) Test_UnlimitedBudget() {
+	// Given
+	tracker := s.newTracker(0, 0)
+	// When
+	for i := 0; i < 100; i++ {
+		tracker.Record()
+	}
+	_, ok := tracker.Allow()
+	// Then
+	s.Assert().True(ok)
+	s.Assert().Equal(100, tracker.Status().DailyUsed)
+}
+
+func (s *QuotaTrackerTestSuite) Test_SkippedWhenApproachingTheDailyBudget() {
+	// Given
+	tracker := s.newTracker(10, 0)
+	// When
+	for i := 0; i < 7; i++ {
+		tracker.Record()
+	}
+	_, ok := tracker.Allow()
+	// Then
+	s.Assert().True(ok)
+	s.Assert().Equal(3, tracker.Status().DailyRemaining)
+	// When
+	tracker.Record()
+	retryAfter, ok := tracker.Allow()
+	// Then
+	s.Assert().False(ok, "within the reserve of the budget")
+	s.Assert().Equal(14*time.Hour, retryAfter, "until midnight (UTC)")
+	// When
+	s.now = s.now.Add(24 * time.Hour)
+	_, ok = tracker.Allow()
+	// Then
+	s.Assert().True(ok, "the next day")
+	s.Assert().Equal(0, tracker.Status().DailyUsed)
+}
+
+func (s *QuotaTrackerTestSuite) Test_SkippedWhenApproachingTheMonthlyBudget() {
+	// Given
+	tracker := s.newTracker(0, 5)
+	// When
+	for i := 0; i < 4; i++ {
+		tracker.Record()
+	}
+	retryAfter, ok := tracker.Allow()
+	// Then
+	s.Assert().False(ok)
+	s.Assert().Equal(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC).Sub(s.now), retryAfter)
+	s.Assert().Equal(1, tracker.Status().MonthlyRemaining)
+}
+
+func (s *QuotaTrackerTestSuite) Test_UsageIsPersistedAcrossRestarts() {
+	// Given
+	tracker := s.newTracker(10, 100)
+	tracker.Record()
+	tracker.Record()
+	// When
+	s.Require().NoError(s.store.Flush())
+	s.store = NewFileStore(s.log, s.path)
+	tracker = s.newTracker(10, 100)
+	// Then
+	s.Assert().Equal(2, tracker.Status().DailyUsed)
+	s.Assert().Equal(2, tracker.Status().MonthlyUsed)
+}
+
+func (s *QuotaTrackerTestSuite) Test_UsageIsOnlyWrittenWhenFlushed() {
+	// Given
+	tracker := s.newTracker(10, 100)
+	// When
+	tracker.Record()
+	// Then
+	s.Assert().NoFileExists(s.path, "Not written by the call itself")
+	// When
+	s.Require().NoError(s.store.Flush())
+	info, err := os.Stat(s.path)
+	s.Require().NoError(err)
+	s.Require().NoError(s.store.Flush())
+	// Then
+	unchanged, err := os.Stat(s.path)
+	s.Require().NoError(err)
+	s.Assert().Equal(info.ModTime(), unchanged.ModTime(), "Not rewritten, without any new calls")
+}
+
+func (s *QuotaTrackerTestSuite) Test_CorruptStore() {
+	// Given
+	s.store = NewFileStore(s.log, filepath.Join("testdata", "corrupt.json"))
+	// When
+	tracker := s.newTracker(10, 100)
+	// Then
+	s.Assert().Equal(0, tracker.Status().DailyUsed, "The corrupt usage is ignored")
+}
+
+func (s *QuotaTrackerTestSuite) Test_FetcherSkipsTheExhaustedWeatherService() {
+	// Given
+	tracker := s.newTracker(1, 0)
+	mockFetcher := mock.NewMockWeatherFetcher(gomock.NewController(s.T()))
+	mockFetcher.EXPECT().FetchWeather(gomock.Any(), "Melbourne").DoAndReturn(
+		func(ctx context.Context, location string) (*model.Weather, error) {
+			tracker.Record() // As the weather service records each of its requests
+			return &model.Weather{}, nil
+		})
+	fetcher := NewFetcher(tracker, mockFetcher)
+	// When
+	_, err := fetcher.FetchWeather(context.Background(), "Melbourne")
+	// Then
+	s.Assert().NoError(err)
+	// When
+	_, err = fetcher.FetchWeather(context.Background(), "Melbourne")
+	// Then
+	s.Assert().Equal(service.KindQuotaExceeded, service.KindOf(err))
+	s.Assert().True(errors.Is(err, ErrBudgetExhausted))
+}
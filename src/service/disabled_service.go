@@ -0,0 +1,28 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ColinSchofield/zai-weather/src/model"
+)
+
+// ErrDisabled is returned (within a FetchError) by a weather service that was disabled by the configuration.
+var ErrDisabled = errors.New("the weather service is disabled")
+
+// The DisabledFetcher stands in for a weather service that was disabled, never calling the service.
+type DisabledFetcher struct {
+	provider string
+}
+
+var _ WeatherFetcher = (*DisabledFetcher)(nil)
+
+// NewDisabled returns the fetcher of a weather service that was disabled.
+func NewDisabled(provider string) *DisabledFetcher {
+	return &DisabledFetcher{provider: provider}
+}
+
+// The FetchWeather method always fails, as the weather service is unavailable.
+func (d *DisabledFetcher) FetchWeather(_ context.Context, _ string) (*model.Weather, error) {
+	return nil, &FetchError{Kind: KindUnavailable, Provider: d.provider, Err: ErrDisabled}
+}
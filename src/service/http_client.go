@@ -0,0 +1,39 @@
+package service
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ColinSchofield/zai-weather/src/config"
+
+	resty "github.com/go-resty/resty/v2"
+	"github.com/sirupsen/logrus"
+)
+
+// Returns the HTTP client of a weather service, logging each request and response to the logger (i.e. through its
+// redaction of the access keys) when HTTP debugging is enabled.
+func newClient(cfg config.Source, log *logrus.Logger) *resty.Client {
+	return resty.New().SetLogger(log).SetDebug(cfg.Current().HTTPDebug)
+}
+
+// Parse the max age of the Cache-Control header (preferring the s-maxage, as the cache is shared by the callers), or
+// zero should the weather service not say.
+func parseMaxAge(header string) time.Duration {
+	maxAge := time.Duration(0)
+	for _, directive := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		seconds, err := strconv.Atoi(strings.Trim(value, `"`))
+		if err != nil || seconds <= 0 {
+			continue
+		}
+		switch strings.ToLower(name) {
+		case "s-maxage":
+			return time.Duration(seconds) * time.Second
+		case "max-age":
+			maxAge = time.Duration(seconds) * time.Second
+		}
+	}
+
+	return maxAge
+}
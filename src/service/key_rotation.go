@@ -0,0 +1,66 @@
+// The service package provides a boundary to the backend, exposed through a set of interfaces.
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ColinSchofield/zai-weather/src/keys"
+	"github.com/ColinSchofield/zai-weather/src/model"
+)
+
+// ErrNoHealthyKeys is returned (within a FetchError) when all the access keys of a weather service were rejected.
+var ErrNoHealthyKeys = errors.New("none of the access keys are healthy")
+
+// The UsageRecorder interface counts the requests made to a weather service against its budget (see quota.Tracker).
+type UsageRecorder interface {
+	Record()
+}
+
+// Fetch the weather information using the access keys of the ring. Whenever a key is rejected (on an auth, quota or
+// rate limit failure) it is marked as unhealthy, and the fetch falls through to the next healthy key. Each key tried
+// is a request to the weather service, so is recorded as such.
+func fetchWithKeys(
+	ring keys.Ring,
+	provider string,
+	cooldown time.Duration,
+	usage UsageRecorder,
+	fetch func(key string) (*model.Weather, error),
+) (*model.Weather, error) {
+	var lastErr error
+	tried := make(map[string]bool)
+	for {
+		key, ok := ring.Next()
+		if !ok && lastErr == nil {
+			return nil, &FetchError{
+				Kind:       KindQuotaExceeded,
+				Provider:   provider,
+				RetryAfter: ring.RetryAfter(),
+				Err:        ErrNoHealthyKeys,
+			}
+		}
+		// Each key is only tried the once (should a key have already recovered, following a short cooldown)
+		if !ok || tried[key] {
+			return nil, lastErr
+		}
+		tried[key] = true
+
+		usage.Record()
+		weather, err := fetch(key)
+		if err == nil {
+			return weather, nil
+		}
+
+		switch KindOf(err) {
+		case KindUnauthorized, KindQuotaExceeded, KindRateLimited:
+			keyCooldown := cooldown
+			if after := RetryAfterOf(err); after > 0 {
+				keyCooldown = after
+			}
+			ring.Fail(key, keyCooldown)
+			lastErr = err
+		default:
+			return nil, err
+		}
+	}
+}
@@ -4,39 +4,61 @@ package service
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/ColinSchofield/zai-weather/src/config"
+	"github.com/ColinSchofield/zai-weather/src/keys"
 	"github.com/ColinSchofield/zai-weather/src/model"
 
 	resty "github.com/go-resty/resty/v2"
 	"github.com/sirupsen/logrus"
 )
 
+const openWeatherMapName = "open weather map"
+
 type DefaultOpenWeatherMap struct {
-	cfg *config.WeatherConfig
+	cfg config.Source
 	log *logrus.Logger
 
 	client *resty.Client
+	keys   keys.Ring
+	usage  UsageRecorder
 }
 
 var _ WeatherFetcher = (*DefaultOpenWeatherMap)(nil)
 
-// NewOpenWeatherMap returns the default struct for the open weather map service.
-func NewOpenWeatherMap(cfg *config.WeatherConfig, log *logrus.Logger) *DefaultOpenWeatherMap {
+// NewOpenWeatherMap returns the default struct for the open weather map service, with its requests recorded by the
+// usage.
+func NewOpenWeatherMap(cfg config.Source, log *logrus.Logger, usage UsageRecorder) *DefaultOpenWeatherMap {
 	return &DefaultOpenWeatherMap{
-		cfg: cfg,
-		log: log,
+		cfg:   cfg,
+		log:   log,
+		usage: usage,
 
-		client: resty.New(),
+		client: newClient(cfg, log),
+		keys:   keys.NewRing(openWeatherMapName, keys.Strategy(cfg.Current().KeyRotation), cfg.Current().FailoverKeys()...),
 	}
 }
 
 // The FetchWeather method returns the weather information (in degrees celsius) and wind speed (in km/hr).
 func (o *DefaultOpenWeatherMap) FetchWeather(ctx context.Context, location string) (*model.Weather, error) {
+	return fetchWithKeys(o.keys, openWeatherMapName, time.Duration(o.cfg.Current().KeyCooldownSeconds)*time.Second,
+		o.usage, func(key string) (*model.Weather, error) {
+			return o.fetch(ctx, location, key)
+		})
+}
+
+// KeyRing returns the access keys of the open weather map service.
+func (o *DefaultOpenWeatherMap) KeyRing() keys.Ring {
+	return o.keys
+}
+
+// Fetch the weather information using the access key.
+func (o *DefaultOpenWeatherMap) fetch(ctx context.Context, location, key string) (*model.Weather, error) {
 	var response model.OpenMapResponse
 
 	queryParams := map[string]string{
-		"appid": o.cfg.FailoverAccessKey,
+		"appid": key,
 		"q":     location + ",AU", // The country is assumed to be Australia
 		"units": "metric",         // Otherwise results will be in Kelvin
 	}
@@ -45,20 +67,35 @@ func (o *DefaultOpenWeatherMap) FetchWeather(ctx context.Context, location strin
 		SetContext(ctx).
 		SetQueryParams(queryParams).
 		SetResult(&response).
-		Get(o.cfg.FailoverEndPoint)
+		Get(o.cfg.Current().FailoverEndPoint)
 
 	if err != nil {
-		return nil, err
+		return nil, &FetchError{Kind: fetchErrorKind(resp), Provider: openWeatherMapName, Err: err}
 	}
 
 	if resp.StatusCode() != 200 {
-		return nil, fmt.Errorf("open weather map returned an unexpected status code of %d", resp.StatusCode())
+		return nil, &FetchError{
+			Kind:       statusKind(resp.StatusCode()),
+			Provider:   openWeatherMapName,
+			RetryAfter: parseRetryAfter(resp.Header().Get("Retry-After")),
+			Err:        fmt.Errorf("unexpected status code of %d", resp.StatusCode()),
+		}
+	}
+
+	windSpeed := response.Wind.WindSpeed
+	if windSpeed != nil {
+		windSpeed = float64Ptr(*windSpeed * 3.6) // need to convert from meters/sec to km/hr
+	}
+
+	weather, err := validateWeather(openWeatherMapName, response.Main.Temperature, windSpeed)
+	if err != nil {
+		return nil, err
+	}
+	weather.FetchedAt = time.Now()
+	if response.Dt > 0 {
+		weather.ObservedAt = time.Unix(response.Dt, 0).UTC()
 	}
+	weather.MaxAge = parseMaxAge(resp.Header().Get("Cache-Control"))
 
-	return &model.Weather{
-		Data: &model.Data{
-			Temperature: int(response.Main.Temperature),
-			WindSpeed:   int(response.Wind.WindSpeed * 3.6), // need to convert from meters/sec to km/hr
-		},
-	}, nil
+	return weather, nil
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/ColinSchofield/zai-weather/src/config"
 	"github.com/ColinSchofield/zai-weather/src/model"
@@ -29,18 +30,18 @@ func (s *OpenWeatherMapServiceTestSuite) SetupTest() {
 	s.ctx = context.Background()
 	log := logrus.New()
 	cfg := &config.WeatherConfig{
-		FailoverEndPoint: "http://localhost",
+		FailoverConfig: config.FailoverConfig{FailoverEndPoint: "http://localhost"},
 	}
 	httpmock.Activate()
 	s.mockResponse = model.OpenMapResponse{
 		Main: model.Main{
-			Temperature: 5,
+			Temperature: float64Ptr(5),
 		},
 		Wind: model.Wind{
-			WindSpeed: 10,
+			WindSpeed: float64Ptr(10),
 		},
 	}
-	s.clientSvc = NewOpenWeatherMap(cfg, log)
+	s.clientSvc = NewOpenWeatherMap(cfg, log, &countingUsage{})
 	httpmock.ActivateNonDefault(s.clientSvc.client.GetClient())
 }
 
@@ -57,6 +58,21 @@ func (s *OpenWeatherMapServiceTestSuite) Test_OpenWeatherMapServiceSuccessful()
 	s.Suite.Assert().True(res.Data.Temperature == 5 && res.Data.WindSpeed == 36, "wind speed is converted to km/hr")
 }
 
+func (s *OpenWeatherMapServiceTestSuite) Test_OpenWeatherMapServiceUpstreamHints() {
+	// Given
+	s.mockResponse.Dt = 1709634840
+	responder := httpmock.NewJsonResponderOrPanic(http.StatusOK, s.mockResponse).
+		HeaderSet(http.Header{"Cache-Control": {"max-age=300"}})
+	// When
+	httpmock.RegisterResponder("GET", "http://localhost", responder)
+	res, err := s.clientSvc.FetchWeather(s.ctx, "Melbourne")
+	// Then
+	s.Suite.Assert().NoError(err)
+	s.Suite.Assert().Equal(time.Unix(1709634840, 0).UTC(), res.ObservedAt)
+	s.Suite.Assert().Equal(300*time.Second, res.MaxAge)
+	s.Suite.Assert().WithinDuration(time.Now(), res.FetchedAt, time.Second)
+}
+
 func (s *OpenWeatherMapServiceTestSuite) Test_OpenWeatherMapServiceUnsuccessful() {
 	// When
 	httpmock.RegisterResponder("GET", "http://localhost", httpmock.NewJsonResponderOrPanic(http.StatusNotFound, nil))
@@ -64,4 +80,54 @@ func (s *OpenWeatherMapServiceTestSuite) Test_OpenWeatherMapServiceUnsuccessful(
 	// Then
 	s.Suite.Assert().Error(err)
 	s.Suite.Assert().Nil(res)
+	s.Suite.Assert().True(IsNotFound(err), "a 404 is a location that could not be found")
+}
+
+func (s *OpenWeatherMapServiceTestSuite) Test_OpenWeatherMapServiceRateLimited() {
+	// When
+	responder := httpmock.NewJsonResponderOrPanic(http.StatusTooManyRequests, nil).HeaderSet(http.Header{"Retry-After": {"42"}})
+	httpmock.RegisterResponder("GET", "http://localhost", responder)
+	res, err := s.clientSvc.FetchWeather(s.ctx, "Melbourne")
+	// Then
+	s.Suite.Assert().Nil(res)
+	s.Suite.Assert().Equal(KindRateLimited, KindOf(err))
+	s.Suite.Assert().Equal(42*time.Second, RetryAfterOf(err))
+}
+
+func (s *OpenWeatherMapServiceTestSuite) Test_OpenWeatherMapServiceIsDown() {
+	// When
+	httpmock.RegisterResponder("GET", "http://localhost", httpmock.NewJsonResponderOrPanic(http.StatusBadGateway, nil))
+	_, err := s.clientSvc.FetchWeather(s.ctx, "Melbourne")
+	// Then
+	s.Suite.Assert().Equal(KindUnavailable, KindOf(err))
+	// When
+	httpmock.RegisterResponder("GET", "http://localhost", httpmock.NewJsonResponderOrPanic(http.StatusUnauthorized, nil))
+	_, err = s.clientSvc.FetchWeather(s.ctx, "Melbourne")
+	// Then
+	s.Suite.Assert().Equal(KindUnauthorized, KindOf(err))
+}
+
+func (s *OpenWeatherMapServiceTestSuite) Test_OpenWeatherMapServiceInvalidResponses() {
+	for _, fixture := range []string{
+		"open_map_malformed.json",
+		"open_map_partial.json",
+		"open_map_implausible.json",
+	} {
+		// When
+		httpmock.RegisterResponder("GET", "http://localhost", fixtureResponder(fixture))
+		res, err := s.clientSvc.FetchWeather(s.ctx, "Melbourne")
+		// Then
+		s.Suite.Assert().Nil(res, fixture)
+		s.Suite.Assert().Equal(KindBadResponse, KindOf(err), fixture)
+	}
+}
+
+func (s *OpenWeatherMapServiceTestSuite) Test_OpenWeatherMapServiceEmptyBody() {
+	// When
+	httpmock.RegisterResponder("GET", "http://localhost", httpmock.NewStringResponder(http.StatusOK, "{}").
+		HeaderSet(http.Header{"Content-Type": {"application/json"}}))
+	res, err := s.clientSvc.FetchWeather(s.ctx, "Melbourne")
+	// Then
+	s.Suite.Assert().Nil(res, "rather than a temperature and wind speed of zero")
+	s.Suite.Assert().Equal(KindBadResponse, KindOf(err))
 }
@@ -0,0 +1,115 @@
+// The service package provides a boundary to the backend, exposed through a set of interfaces.
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	resty "github.com/go-resty/resty/v2"
+)
+
+// The ErrorKind classifies why a weather service did not return any results.
+type ErrorKind int
+
+const (
+	KindUnavailable ErrorKind = iota
+	KindNotFound
+	KindUnauthorized
+	KindQuotaExceeded
+	KindRateLimited
+	KindBadResponse
+)
+
+var kindNames = map[ErrorKind]string{
+	KindUnavailable:   "upstream unavailable",
+	KindNotFound:      "not found",
+	KindUnauthorized:  "auth failure",
+	KindQuotaExceeded: "quota exceeded",
+	KindRateLimited:   "rate limited",
+	KindBadResponse:   "bad response",
+}
+
+func (k ErrorKind) String() string {
+	return kindNames[k]
+}
+
+// The FetchError is returned by a WeatherFetcher, whenever the weather service did not return any results.
+type FetchError struct {
+	Kind       ErrorKind
+	Provider   string
+	RetryAfter time.Duration // Zero when the weather service did not say
+	Err        error
+}
+
+func (e *FetchError) Error() string {
+	return fmt.Sprintf("%s %s: %v", e.Provider, e.Kind, e.Err)
+}
+
+func (e *FetchError) Unwrap() error {
+	return e.Err
+}
+
+// KindOf returns the ErrorKind of the error. Errors that were not raised by a WeatherFetcher (i.e. timeouts or an
+// open circuit breaker) are deemed to be KindUnavailable.
+func KindOf(err error) ErrorKind {
+	var fetchErr *FetchError
+	if errors.As(err, &fetchErr) {
+		return fetchErr.Kind
+	}
+
+	return KindUnavailable
+}
+
+// IsNotFound returns true, if the weather service could not find the location.
+func IsNotFound(err error) bool {
+	return err != nil && KindOf(err) == KindNotFound
+}
+
+// RetryAfterOf returns the time the weather service asked us to wait for, or zero if it did not say.
+func RetryAfterOf(err error) time.Duration {
+	var fetchErr *FetchError
+	if errors.As(err, &fetchErr) {
+		return fetchErr.RetryAfter
+	}
+
+	return 0
+}
+
+// Classify a non 200 HTTP status code returned by a weather service.
+func statusKind(statusCode int) ErrorKind {
+	switch {
+	case statusCode == http.StatusNotFound:
+		return KindNotFound
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return KindUnauthorized
+	case statusCode == http.StatusTooManyRequests:
+		return KindRateLimited
+	case statusCode >= http.StatusInternalServerError:
+		return KindUnavailable
+	default:
+		return KindBadResponse
+	}
+}
+
+// Classify the error returned by the HTTP client. A response that was received, but could not be decoded, is a bad
+// response rather than the weather service being unavailable.
+func fetchErrorKind(resp *resty.Response) ErrorKind {
+	if resp != nil && resp.RawResponse != nil && resp.IsSuccess() {
+		return KindBadResponse
+	}
+
+	return KindUnavailable
+}
+
+// Parse the Retry-After header, which is assumed to be in seconds.
+func parseRetryAfter(header string) time.Duration {
+	seconds, err := strconv.Atoi(header)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+
+	return time.Duration(seconds) * time.Second
+}
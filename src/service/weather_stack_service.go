@@ -3,9 +3,14 @@ package service
 
 import (
 	"context"
-	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/ColinSchofield/zai-weather/src/config"
+	"github.com/ColinSchofield/zai-weather/src/keys"
+	"github.com/ColinSchofield/zai-weather/src/metrics"
 	"github.com/ColinSchofield/zai-weather/src/model"
 
 	resty "github.com/go-resty/resty/v2"
@@ -19,31 +24,60 @@ type WeatherFetcher interface {
 
 //go:generate mockgen -source=weather_stack_service.go -destination=../mock/mock_weather_fetcher.go
 
+const weatherStackName = "weather stack"
+
+// The Weather Stack error codes, see https://weatherstack.com/documentation#api_error_codes.
+// Any other error code is deemed to be a bad response.
+var stackErrorKinds = map[int]ErrorKind{
+	101: KindUnauthorized,  // invalid_access_key or missing_access_key
+	102: KindUnauthorized,  // inactive_user
+	104: KindQuotaExceeded, // usage_limit_reached
+	105: KindUnauthorized,  // function_access_restricted
+	615: KindNotFound,      // request_failed
+}
+
 type DefaultWeatherFetcher struct {
-	cfg *config.WeatherConfig
+	cfg config.Source
 	log *logrus.Logger
 
 	client *resty.Client
+	keys   keys.Ring
+	usage  UsageRecorder
 }
 
 var _ WeatherFetcher = (*DefaultWeatherFetcher)(nil)
 
-// NewWeatherStack returns the default struct for the weather stack service.
-func NewWeatherStack(cfg *config.WeatherConfig, log *logrus.Logger) *DefaultWeatherFetcher {
+// NewWeatherStack returns the default struct for the weather stack service, with its requests recorded by the usage.
+func NewWeatherStack(cfg config.Source, log *logrus.Logger, usage UsageRecorder) *DefaultWeatherFetcher {
 	return &DefaultWeatherFetcher{
-		cfg: cfg,
-		log: log,
+		cfg:   cfg,
+		log:   log,
+		usage: usage,
 
-		client: resty.New(),
+		client: newClient(cfg, log),
+		keys:   keys.NewRing(weatherStackName, keys.Strategy(cfg.Current().KeyRotation), cfg.Current().PrimaryKeys()...),
 	}
 }
 
 // The FetchWeather method returns the weather information (in degrees celsius) and wind speed (in Km/hr).
 func (s *DefaultWeatherFetcher) FetchWeather(ctx context.Context, location string) (*model.Weather, error) {
+	return fetchWithKeys(s.keys, weatherStackName, time.Duration(s.cfg.Current().KeyCooldownSeconds)*time.Second,
+		s.usage, func(key string) (*model.Weather, error) {
+			return s.fetch(ctx, location, key)
+		})
+}
+
+// KeyRing returns the access keys of the weather stack service.
+func (s *DefaultWeatherFetcher) KeyRing() keys.Ring {
+	return s.keys
+}
+
+// Fetch the weather information using the access key.
+func (s *DefaultWeatherFetcher) fetch(ctx context.Context, location, key string) (*model.Weather, error) {
 	var response model.StackResponse
 
 	queryParams := map[string]string{
-		"access_key": s.cfg.PrimaryAccessKey,
+		"access_key": key,
 		"query":      location,
 	}
 
@@ -51,20 +85,93 @@ func (s *DefaultWeatherFetcher) FetchWeather(ctx context.Context, location strin
 		SetContext(ctx).
 		SetQueryParams(queryParams).
 		SetResult(&response).
-		Get(s.cfg.PrimaryEndPoint)
+		Get(s.cfg.Current().PrimaryEndPoint)
 
+	if err != nil {
+		return nil, &FetchError{Kind: fetchErrorKind(resp), Provider: weatherStackName, Err: err}
+	}
+
+	if resp.StatusCode() != 200 {
+		return nil, &FetchError{
+			Kind:       statusKind(resp.StatusCode()),
+			Provider:   weatherStackName,
+			RetryAfter: parseRetryAfter(resp.Header().Get("Retry-After")),
+			Err:        fmt.Errorf("unexpected status code of %d", resp.StatusCode()),
+		}
+	}
+
+	// This service always returns with http.StatusOK, but with an error code above zero
+	if response.Error.Code > 0 {
+		return nil, s.stackError(location, response.Error)
+	}
+
+	weather, err := validateWeather(weatherStackName,
+		toFloat64(response.Current.Temperature), toFloat64(response.Current.WindSpeed))
 	if err != nil {
 		return nil, err
 	}
+	weather.FetchedAt = time.Now()
+	weather.ObservedAt = parseObservationTime(response.Current.ObservationTime, weather.FetchedAt)
+	weather.MaxAge = parseMaxAge(resp.Header().Get("Cache-Control"))
+
+	return weather, nil
+}
+
+// Parse the observation time, which is only given as the UTC time of day (such as 12:14 PM). The observation is
+// assumed to be the most recent at that time, so one later in the day than now was yesterday's. A missing (or
+// malformed) time gives the zero time.
+func parseObservationTime(value string, now time.Time) time.Time {
+	clock, err := time.Parse(time.Kitchen, strings.ReplaceAll(value, " ", ""))
+	if err != nil {
+		return time.Time{}
+	}
+
+	now = now.UTC()
+	observed := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, time.UTC)
+	if observed.After(now) {
+		observed = observed.AddDate(0, 0, -1)
+	}
+
+	return observed
+}
+
+// Map the Weather Stack error code to a FetchError. Should the usage limit have been reached, the weather service is
+// not retried until its next billing window.
+func (s *DefaultWeatherFetcher) stackError(location string, stackErr model.Error) *FetchError {
+	kind, found := stackErrorKinds[stackErr.Code]
+	if !found {
+		kind = KindBadResponse
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"location": location,
+		"code":     stackErr.Code,
+		"type":     stackErr.Type,
+		"info":     stackErr.Info,
+		"kind":     kind.String(),
+	}).Warn("Weather stack returned an error")
+	metrics.StackErrorCodes.WithLabelValues(strconv.Itoa(stackErr.Code), stackErr.Type).Inc()
+
+	fetchErr := &FetchError{
+		Kind:     kind,
+		Provider: weatherStackName,
+		Err:      fmt.Errorf("error code %d (%s): %s", stackErr.Code, stackErr.Type, stackErr.Info),
+	}
+	if kind == KindQuotaExceeded {
+		now := time.Now()
+		fetchErr.RetryAfter = NextBillingWindow(now, s.cfg.Current().PrimaryBillingDay).Sub(now)
+	}
+
+	return fetchErr
+}
 
-	if resp.StatusCode() != 200 || response.Error.Code > 0 {
-		return nil, errors.New("weather stack did not return any results")
+// NextBillingWindow returns the start of the next billing window, which begins on the given day of each month (UTC).
+func NextBillingWindow(now time.Time, billingDay int) time.Time {
+	now = now.UTC()
+	window := time.Date(now.Year(), now.Month(), billingDay, 0, 0, 0, 0, time.UTC)
+	if !window.After(now) {
+		window = time.Date(now.Year(), now.Month()+1, billingDay, 0, 0, 0, 0, time.UTC)
 	}
 
-	return &model.Weather{
-		Data: &model.Data{
-			Temperature: response.Current.Temperature,
-			WindSpeed:   response.Current.WindSpeed,
-		},
-	}, nil
+	return window
 }
@@ -4,12 +4,15 @@ import (
 	"context"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/ColinSchofield/zai-weather/src/config"
+	"github.com/ColinSchofield/zai-weather/src/keys"
 	"github.com/ColinSchofield/zai-weather/src/model"
 
 	"github.com/jarcoal/httpmock"
 	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -20,6 +23,16 @@ type WeatherStackServiceTestSuite struct {
 	clientSvc       *DefaultWeatherFetcher
 	mockResponse    model.StackResponse
 	mockBadResponse model.StackResponse
+	usage           *countingUsage
+}
+
+// The countingUsage counts the requests made to a weather service.
+type countingUsage struct {
+	requests int
+}
+
+func (u *countingUsage) Record() {
+	u.requests++
 }
 
 func TestWeatherStackServiceSuite(t *testing.T) {
@@ -30,14 +43,18 @@ func (s *WeatherStackServiceTestSuite) SetupTest() {
 	s.ctx = context.Background()
 	log := logrus.New()
 	cfg := &config.WeatherConfig{
-		PrimaryEndPoint: "http://localhost",
+		PrimaryConfig: config.PrimaryConfig{
+			PrimaryEndPoint:  "http://localhost",
+			PrimaryAccessKey: "1cadfad44c3387c66d14a12cb33f282e",
+		},
+		KeysConfig: config.KeysConfig{KeyCooldownSeconds: 60},
 	}
 	httpmock.Activate()
 	s.mockResponse = model.StackResponse{
 		Error: model.Error{},
 		Current: model.Current{
-			Temperature: 5,
-			WindSpeed:   36,
+			Temperature: intPtr(5),
+			WindSpeed:   intPtr(36),
 		},
 	}
 	s.mockBadResponse = model.StackResponse{
@@ -46,7 +63,8 @@ func (s *WeatherStackServiceTestSuite) SetupTest() {
 		},
 		Current: model.Current{},
 	}
-	s.clientSvc = NewWeatherStack(cfg, log)
+	s.usage = &countingUsage{}
+	s.clientSvc = NewWeatherStack(cfg, log, s.usage)
 	httpmock.ActivateNonDefault(s.clientSvc.client.GetClient())
 }
 
@@ -63,6 +81,22 @@ func (s *WeatherStackServiceTestSuite) Test_WeatherStackServiceSuccessful() {
 	s.Suite.Assert().True(res.Data.Temperature == 5 && res.Data.WindSpeed == 36, "all values are in the correct units")
 }
 
+func (s *WeatherStackServiceTestSuite) Test_WeatherStackServiceUpstreamHints() {
+	// Given
+	s.mockResponse.Current.ObservationTime = "12:14 AM"
+	responder := httpmock.NewJsonResponderOrPanic(http.StatusOK, s.mockResponse).
+		HeaderSet(http.Header{"Cache-Control": {"public, max-age=600"}})
+	// When
+	httpmock.RegisterResponder("GET", "http://localhost", responder)
+	res, err := s.clientSvc.FetchWeather(s.ctx, "Melbourne")
+	// Then
+	s.Suite.Assert().NoError(err)
+	s.Suite.Assert().Equal(600*time.Second, res.MaxAge)
+	s.Suite.Assert().Equal(14, res.ObservedAt.Minute())
+	s.Suite.Assert().False(res.ObservedAt.After(time.Now()), "the observation is never in the future")
+	s.Suite.Assert().WithinDuration(time.Now(), res.FetchedAt, time.Second)
+}
+
 func (s *WeatherStackServiceTestSuite) Test_WeatherStackServiceUnsuccessful() {
 	// When
 	httpmock.RegisterResponder("GET", "http://localhost", httpmock.NewJsonResponderOrPanic(http.StatusOK, s.mockBadResponse))
@@ -70,4 +104,148 @@ func (s *WeatherStackServiceTestSuite) Test_WeatherStackServiceUnsuccessful() {
 	// Then
 	s.Suite.Assert().Error(err)
 	s.Suite.Assert().Nil(res)
+	s.Suite.Assert().Equal(KindBadResponse, KindOf(err))
+}
+
+func (s *WeatherStackServiceTestSuite) Test_WeatherStackServiceLocationNotFound() {
+	// When
+	s.mockBadResponse.Error.Code = 615
+	s.mockBadResponse.Error.Type = "request_failed"
+	httpmock.RegisterResponder("GET", "http://localhost", httpmock.NewJsonResponderOrPanic(http.StatusOK, s.mockBadResponse))
+	res, err := s.clientSvc.FetchWeather(s.ctx, "Melbourne")
+	// Then
+	s.Suite.Assert().Nil(res)
+	s.Suite.Assert().True(IsNotFound(err), "the 615 error code is a location that could not be found")
+}
+
+func (s *WeatherStackServiceTestSuite) Test_WeatherStackServiceErrorCodes() {
+	for code, kind := range map[int]ErrorKind{
+		101: KindUnauthorized,
+		104: KindQuotaExceeded,
+		601: KindBadResponse,
+		615: KindNotFound,
+	} {
+		// Given
+		s.TearDownTest()
+		s.SetupTest() // As the access key is unhealthy, once rejected
+		// When
+		s.mockBadResponse.Error = model.Error{Code: code, Type: "some_type", Info: "Some information."}
+		httpmock.RegisterResponder("GET", "http://localhost", httpmock.NewJsonResponderOrPanic(http.StatusOK, s.mockBadResponse))
+		_, err := s.clientSvc.FetchWeather(s.ctx, "Melbourne")
+		// Then
+		s.Suite.Assert().Equal(kind, KindOf(err), "error code %d", code)
+		s.Suite.Assert().Contains(err.Error(), "some_type")
+	}
+}
+
+func (s *WeatherStackServiceTestSuite) Test_WeatherStackServiceQuotaExceededUntilNextBillingWindow() {
+	// When
+	s.mockBadResponse.Error = model.Error{Code: 104, Type: "usage_limit_reached"}
+	httpmock.RegisterResponder("GET", "http://localhost", httpmock.NewJsonResponderOrPanic(http.StatusOK, s.mockBadResponse))
+	_, err := s.clientSvc.FetchWeather(s.ctx, "Melbourne")
+	// Then
+	s.Suite.Assert().Greater(RetryAfterOf(err), time.Duration(0))
+	s.Suite.Assert().LessOrEqual(RetryAfterOf(err), 31*24*time.Hour)
+}
+
+func (s *WeatherStackServiceTestSuite) Test_NextBillingWindow() {
+	// Given
+	now := time.Date(2023, time.December, 15, 10, 0, 0, 0, time.UTC)
+	// Then
+	s.Suite.Assert().Equal(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC), NextBillingWindow(now, 1))
+	s.Suite.Assert().Equal(time.Date(2023, time.December, 20, 0, 0, 0, 0, time.UTC), NextBillingWindow(now, 20))
+	s.Suite.Assert().Equal(time.Date(2024, time.January, 15, 0, 0, 0, 0, time.UTC), NextBillingWindow(now, 15))
+}
+
+func (s *WeatherStackServiceTestSuite) Test_WeatherStackServiceInvalidResponses() {
+	for _, fixture := range []string{
+		"stack_malformed.json",
+		"stack_partial.json",
+		"stack_implausible.json",
+	} {
+		// When
+		httpmock.RegisterResponder("GET", "http://localhost", fixtureResponder(fixture))
+		res, err := s.clientSvc.FetchWeather(s.ctx, "Melbourne")
+		// Then
+		s.Suite.Assert().Nil(res, fixture)
+		s.Suite.Assert().Equal(KindBadResponse, KindOf(err), fixture)
+	}
+}
+
+func (s *WeatherStackServiceTestSuite) Test_WeatherStackServiceFallsThroughToTheNextKey() {
+	// Given
+	cfg := &config.WeatherConfig{
+		PrimaryConfig: config.PrimaryConfig{
+			PrimaryEndPoint:   "http://localhost",
+			PrimaryAccessKey:  "first",
+			PrimaryAccessKeys: []string{"second"},
+		},
+		KeysConfig: config.KeysConfig{KeyRotation: string(keys.Failover), KeyCooldownSeconds: 60},
+	}
+	s.clientSvc = NewWeatherStack(cfg, logrus.New(), s.usage)
+	httpmock.ActivateNonDefault(s.clientSvc.client.GetClient())
+	s.mockBadResponse.Error = model.Error{Code: 101, Type: "invalid_access_key"}
+	httpmock.RegisterResponderWithQuery("GET", "http://localhost", "access_key=first&query=Melbourne",
+		httpmock.NewJsonResponderOrPanic(http.StatusOK, s.mockBadResponse))
+	httpmock.RegisterResponderWithQuery("GET", "http://localhost", "access_key=second&query=Melbourne",
+		httpmock.NewJsonResponderOrPanic(http.StatusOK, s.mockResponse))
+	// When
+	res, err := s.clientSvc.FetchWeather(s.ctx, "Melbourne")
+	// Then
+	s.Suite.Assert().NoError(err)
+	s.Suite.Assert().Equal(5, res.Data.Temperature)
+	s.Suite.Assert().Equal(2, s.usage.requests, "each key tried is a request against the budget")
+	statuses := s.clientSvc.KeyRing().Status()
+	s.Suite.Assert().False(statuses[0].Healthy, "the first key was rejected")
+	s.Suite.Assert().True(statuses[1].Healthy)
+	// When
+	res, err = s.clientSvc.FetchWeather(s.ctx, "Melbourne")
+	// Then
+	s.Suite.Assert().NoError(err)
+	s.Suite.Assert().Equal(2, httpmock.GetCallCountInfo()["GET http://localhost?access_key=second&query=Melbourne"])
+	s.Suite.Assert().Equal(1, httpmock.GetCallCountInfo()["GET http://localhost?access_key=first&query=Melbourne"])
+}
+
+func (s *WeatherStackServiceTestSuite) Test_WeatherStackServiceAllKeysRejected() {
+	// When
+	s.mockBadResponse.Error = model.Error{Code: 101, Type: "invalid_access_key"}
+	httpmock.RegisterResponder("GET", "http://localhost", httpmock.NewJsonResponderOrPanic(http.StatusOK, s.mockBadResponse))
+	_, err := s.clientSvc.FetchWeather(s.ctx, "Melbourne")
+	// Then
+	s.Suite.Assert().Equal(KindUnauthorized, KindOf(err), "the rejection of the last key")
+	// When
+	_, err = s.clientSvc.FetchWeather(s.ctx, "Melbourne")
+	// Then
+	s.Suite.Assert().Equal(KindQuotaExceeded, KindOf(err))
+	s.Suite.Assert().ErrorIs(err, ErrNoHealthyKeys)
+	s.Suite.Assert().Equal(1, httpmock.GetTotalCallCount(), "the unhealthy key is not called again")
+	s.Suite.Assert().Equal(1, s.usage.requests)
+}
+
+func Test_ParseObservationTime(t *testing.T) {
+	now := time.Date(2024, time.March, 5, 10, 30, 0, 0, time.UTC)
+	for value, expected := range map[string]time.Time{
+		"10:14 AM": time.Date(2024, time.March, 5, 10, 14, 0, 0, time.UTC),
+		"12:00 AM": time.Date(2024, time.March, 5, 0, 0, 0, 0, time.UTC),
+		"11:45 PM": time.Date(2024, time.March, 4, 23, 45, 0, 0, time.UTC), // Yesterday's
+		"":         {},
+		"noon":     {},
+	} {
+		assert.Equal(t, expected, parseObservationTime(value, now), value)
+	}
+}
+
+func Test_ParseMaxAge(t *testing.T) {
+	for header, expected := range map[string]time.Duration{
+		"max-age=300":                  300 * time.Second,
+		"public, max-age=60":           60 * time.Second,
+		"max-age=60, s-maxage=120":     120 * time.Second,
+		"no-cache":                     0,
+		"max-age=0":                    0,
+		"max-age=soon":                 0,
+		"":                             0,
+		`private, max-age="90", extra`: 90 * time.Second,
+	} {
+		assert.Equal(t, expected, parseMaxAge(header), header)
+	}
 }
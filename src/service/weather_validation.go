@@ -0,0 +1,59 @@
+// The service package provides a boundary to the backend, exposed through a set of interfaces.
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ColinSchofield/zai-weather/src/model"
+)
+
+const (
+	// The plausible range of temperatures (in degrees celsius)
+	minTemperature = -60
+	maxTemperature = 60
+)
+
+// Reject the weather information, should any of its values be missing or implausible. The weather service has then
+// failed (with a bad response), so that the fail-over is tried rather than serving bogus values. The values are
+// validated as given (i.e. before being truncated to whole numbers, which would bring -60.9 within range).
+func validateWeather(provider string, temperature, windSpeed *float64) (*model.Weather, error) {
+	var problems []error
+	if temperature == nil {
+		problems = append(problems, errors.New("temperature is missing"))
+	} else if *temperature < minTemperature || *temperature > maxTemperature {
+		problems = append(problems,
+			fmt.Errorf("temperature of %g is outside %d..%d", *temperature, minTemperature, maxTemperature))
+	}
+	if windSpeed == nil {
+		problems = append(problems, errors.New("wind speed is missing"))
+	} else if *windSpeed < 0 {
+		problems = append(problems, fmt.Errorf("wind speed of %g is negative", *windSpeed))
+	}
+
+	if len(problems) > 0 {
+		return nil, &FetchError{Kind: KindBadResponse, Provider: provider, Err: errors.Join(problems...)}
+	}
+
+	return &model.Weather{
+		Data: &model.Data{
+			Temperature: int(*temperature),
+			WindSpeed:   int(*windSpeed),
+		},
+		Values: &model.Values{Temperature: *temperature, WindSpeed: *windSpeed},
+	}, nil
+}
+
+func float64Ptr(value float64) *float64 {
+	return &value
+}
+
+// Returns the whole number (should there be one) as a float, for its validation.
+func toFloat64(value *int) *float64 {
+	if value == nil {
+		return nil
+	}
+	float := float64(*value)
+
+	return &float
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ValidateWeather(t *testing.T) {
+	for name, tc := range map[string]struct {
+		temperature, windSpeed *float64
+		valid                  bool
+	}{
+		"plausible":         {float64Ptr(21), float64Ptr(15), true},
+		"fractional":        {float64Ptr(21.7), float64Ptr(15.2), true},
+		"coldest":           {float64Ptr(-60), float64Ptr(0), true},
+		"hottest":           {float64Ptr(60), float64Ptr(0), true},
+		"too cold":          {float64Ptr(-61), float64Ptr(0), false},
+		"just too cold":     {float64Ptr(-60.9), float64Ptr(0), false},
+		"too hot":           {float64Ptr(61), float64Ptr(0), false},
+		"just too hot":      {float64Ptr(60.2), float64Ptr(0), false},
+		"negative wind":     {float64Ptr(21), float64Ptr(-1), false},
+		"missing temp":      {nil, float64Ptr(15), false},
+		"missing windspeed": {float64Ptr(21), nil, false},
+	} {
+		weather, err := validateWeather("test", tc.temperature, tc.windSpeed)
+		if tc.valid {
+			assert.NoError(t, err, name)
+			assert.Equal(t, int(*tc.temperature), weather.Data.Temperature, name)
+			assert.Equal(t, *tc.temperature, weather.Values.Temperature, "Untruncated: "+name)
+		} else {
+			assert.Equal(t, KindBadResponse, KindOf(err), name)
+			assert.Nil(t, weather, name)
+		}
+	}
+}
+
+// Responds with the JSON fixture, read from the testdata directory.
+func fixtureResponder(name string) httpmock.Responder {
+	body, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		panic(err)
+	}
+
+	return httpmock.NewBytesResponder(http.StatusOK, body).HeaderSet(http.Header{"Content-Type": {"application/json"}})
+}
+
+func intPtr(value int) *int {
+	return &value
+}
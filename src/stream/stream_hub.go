@@ -0,0 +1,178 @@
+// The package stream shares the updates of the weather information of each location among its subscribers (i.e. the
+// Server-Sent Events), refreshing each location once for all of them.
+package stream
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/ColinSchofield/zai-weather/src/config"
+	"github.com/ColinSchofield/zai-weather/src/metrics"
+	"github.com/ColinSchofield/zai-weather/src/model"
+
+	"github.com/sirupsen/logrus"
+)
+
+// The Resolver interface returns the current weather information of a location (through the cache), or why it could
+// not be found.
+type Resolver interface {
+	Current(location string) (model.Weather, error)
+}
+
+// An Update of the weather information of a location, or the error should it not be found.
+type Update struct {
+	Weather model.Weather
+	Err     error
+}
+
+// Returns true should the update differ from the last one, by its data (or its error). The freshness and message of
+// the weather information change as it ages, so are not deemed to be a change.
+func (u Update) changed(last *Update) bool {
+	if last == nil {
+		return true
+	}
+	if u.Err != nil || last.Err != nil {
+		return u.Err == nil || last.Err == nil || u.Err.Error() != last.Err.Error()
+	}
+
+	return !reflect.DeepEqual(u.Weather.Data, last.Weather.Data) ||
+		!reflect.DeepEqual(u.Weather.Blend, last.Weather.Blend)
+}
+
+// The stream.Hub interface subscribes to the updates of the weather information of a location. The subscription is
+// ended by calling its cancel function (or by closing the hub), either of which closes its channel.
+type Hub interface {
+	Subscribe(location string) (<-chan Update, func())
+	Close()
+}
+
+// The topic of a location: its subscribers, and its last update (given to each new subscriber).
+type topic struct {
+	subscribers map[chan Update]struct{}
+	last        *Update
+	cancel      context.CancelFunc
+}
+
+type DefaultHub struct {
+	cfg      config.Source
+	log      *logrus.Logger
+	resolver Resolver
+
+	mu     sync.Mutex
+	topics map[string]*topic
+	closed bool
+}
+
+var _ Hub = (*DefaultHub)(nil)
+
+// NewHub returns the default struct for the hub, refreshing each location at the (reloadable) interval.
+func NewHub(cfg config.Source, log *logrus.Logger, resolver Resolver) *DefaultHub {
+	return &DefaultHub{
+		cfg:      cfg,
+		log:      log,
+		resolver: resolver,
+		topics:   make(map[string]*topic),
+	}
+}
+
+// Subscribe returns the channel of the updates of the location, starting with its last update (should there be one).
+// The first subscriber of a location starts its refresh loop, and the last one to cancel stops it.
+func (h *DefaultHub) Subscribe(location string) (<-chan Update, func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	updates := make(chan Update, 1)
+	if h.closed {
+		close(updates)
+		return updates, func() {}
+	}
+
+	t, found := h.topics[location]
+	if !found {
+		ctx, cancel := context.WithCancel(context.Background())
+		t = &topic{subscribers: make(map[chan Update]struct{}), cancel: cancel}
+		h.topics[location] = t
+		go h.refresh(ctx, location, t)
+	}
+	t.subscribers[updates] = struct{}{}
+	if t.last != nil {
+		updates <- *t.last
+	}
+	metrics.StreamSubscribers.Inc()
+
+	var once sync.Once
+	return updates, func() { once.Do(func() { h.unsubscribe(location, t, updates) }) }
+}
+
+// Close ends all the subscriptions (closing their channels), and stops the refresh loops.
+func (h *DefaultHub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for location, t := range h.topics {
+		t.cancel()
+		for updates := range t.subscribers {
+			delete(t.subscribers, updates)
+			close(updates)
+			metrics.StreamSubscribers.Dec()
+		}
+		delete(h.topics, location)
+	}
+}
+
+// End the subscription, stopping the refresh loop of the location should it have no other subscribers.
+func (h *DefaultHub) unsubscribe(location string, t *topic, updates chan Update) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, found := t.subscribers[updates]; !found {
+		return // The hub was closed
+	}
+	delete(t.subscribers, updates)
+	close(updates)
+	metrics.StreamSubscribers.Dec()
+	if len(t.subscribers) == 0 {
+		t.cancel()
+		delete(h.topics, location)
+	}
+}
+
+// Refresh the weather information of the location at the interval (for all its subscribers), publishing each change.
+func (h *DefaultHub) refresh(ctx context.Context, location string, t *topic) {
+	h.log.WithField("location", location).Debug("Started streaming the weather")
+	defer h.log.WithField("location", location).Debug("Stopped streaming the weather")
+
+	for {
+		weather, err := h.resolver.Current(location)
+		h.publish(ctx, t, Update{Weather: weather, Err: err})
+
+		interval := time.Duration(max(h.cfg.Current().StreamRefreshSeconds, 1)) * time.Second
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// Publish the update to the subscribers of the topic, should it have changed. A subscriber that has yet to receive
+// the previous update is given the latest one instead (i.e. a slow subscriber does not hold up the others).
+func (h *DefaultHub) publish(ctx context.Context, t *topic, update Update) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if ctx.Err() != nil || !update.changed(t.last) {
+		return
+	}
+	t.last = &update
+	for updates := range t.subscribers {
+		select {
+		case <-updates:
+		default:
+		}
+		updates <- update
+	}
+}
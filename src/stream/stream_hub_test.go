@@ -0,0 +1,165 @@
+package stream
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ColinSchofield/zai-weather/src/config"
+	"github.com/ColinSchofield/zai-weather/src/model"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/suite"
+)
+
+// The stubResolver returns the temperature given (or the error), counting the calls for each location.
+type stubResolver struct {
+	mu          sync.Mutex
+	temperature int
+	err         error
+	calls       map[string]int
+}
+
+func (r *stubResolver) Current(location string) (model.Weather, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.calls[location]++
+	if r.err != nil {
+		return model.Weather{}, r.err
+	}
+	return model.Weather{Message: "Request successful", Data: &model.Data{Temperature: r.temperature}}, nil
+}
+
+func (r *stubResolver) set(temperature int, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.temperature, r.err = temperature, err
+}
+
+func (r *stubResolver) callsOf(location string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.calls[location]
+}
+
+type StreamHubTestSuite struct {
+	suite.Suite
+
+	resolver *stubResolver
+	hub      *DefaultHub
+}
+
+func TestStreamHubSuite(t *testing.T) {
+	suite.Run(t, new(StreamHubTestSuite))
+}
+
+func (s *StreamHubTestSuite) SetupTest() {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	s.resolver = &stubResolver{temperature: 10, calls: make(map[string]int)}
+	cfg := &config.WeatherConfig{StreamConfig: config.StreamConfig{StreamRefreshSeconds: 1}}
+	s.hub = NewHub(cfg, log, s.resolver)
+}
+
+func (s *StreamHubTestSuite) TearDownTest() {
+	s.hub.Close()
+}
+
+// Receive the next update, failing should there be none within the time given.
+func (s *StreamHubTestSuite) receive(updates <-chan Update, within time.Duration) Update {
+	select {
+	case update, open := <-updates:
+		s.Require().True(open, "The channel is open")
+		return update
+	case <-time.After(within):
+		s.FailNow("No update was received")
+		return Update{}
+	}
+}
+
+func (s *StreamHubTestSuite) Test_SubscribersShareTheRefresh() {
+	// Given
+	first, cancelFirst := s.hub.Subscribe("Sydney")
+	second, cancelSecond := s.hub.Subscribe("Sydney")
+	defer cancelFirst()
+	defer cancelSecond()
+	// When
+	fromFirst := s.receive(first, time.Second)
+	fromSecond := s.receive(second, time.Second)
+	// Then
+	s.Assert().Equal(10, fromFirst.Weather.Data.Temperature)
+	s.Assert().Equal(10, fromSecond.Weather.Data.Temperature)
+	s.Assert().Equal(1, s.resolver.callsOf("Sydney"), "One refresh for both subscribers")
+	// When
+	third, cancelThird := s.hub.Subscribe("Sydney")
+	defer cancelThird()
+	// Then
+	s.Assert().Equal(10, s.receive(third, 10*time.Millisecond).Weather.Data.Temperature, "The last update, at once")
+	s.Assert().Equal(1, s.resolver.callsOf("Sydney"))
+}
+
+func (s *StreamHubTestSuite) Test_OnlyChangesArePublished() {
+	// Given
+	updates, cancel := s.hub.Subscribe("Perth")
+	defer cancel()
+	s.receive(updates, time.Second)
+	// When
+	time.Sleep(1200 * time.Millisecond)
+	// Then
+	s.Assert().Equal(2, s.resolver.callsOf("Perth"))
+	s.Assert().Empty(updates, "Unchanged")
+	// When
+	s.resolver.set(12, nil)
+	// Then
+	s.Assert().Equal(12, s.receive(updates, 1500*time.Millisecond).Weather.Data.Temperature)
+	// When
+	s.resolver.set(12, errors.New("Weather services are unavailable"))
+	// Then
+	s.Assert().EqualError(s.receive(updates, 1500*time.Millisecond).Err, "Weather services are unavailable")
+}
+
+func (s *StreamHubTestSuite) Test_TheLastToCancelStopsTheRefresh() {
+	// Given
+	first, cancelFirst := s.hub.Subscribe("Hobart")
+	second, cancelSecond := s.hub.Subscribe("Hobart")
+	s.receive(first, time.Second)
+	// When
+	cancelFirst()
+	cancelFirst()
+	// Then
+	_, open := <-first
+	s.Assert().False(open, "The channel is closed")
+	s.Assert().Contains(s.hub.topics, "Hobart", "While it has a subscriber")
+	// When
+	cancelSecond()
+	calls := s.resolver.callsOf("Hobart")
+	time.Sleep(1200 * time.Millisecond)
+	// Then
+	s.Assert().NotContains(s.hub.topics, "Hobart")
+	s.Assert().Equal(calls, s.resolver.callsOf("Hobart"), "No longer refreshed")
+	for range second {
+	}
+}
+
+func (s *StreamHubTestSuite) Test_CloseEndsTheSubscriptions() {
+	// Given
+	updates, cancel := s.hub.Subscribe("Darwin")
+	s.receive(updates, time.Second)
+	// When
+	s.hub.Close()
+	cancel()
+	// Then
+	_, open := <-updates
+	s.Assert().False(open, "The channel is closed")
+	s.Assert().Empty(s.hub.topics)
+	// When
+	updates, _ = s.hub.Subscribe("Darwin")
+	// Then
+	_, open = <-updates
+	s.Assert().False(open, "Nor may it be subscribed to, once closed")
+}
@@ -0,0 +1,265 @@
+// The package warm pre-warms the cache with the popular cities, refreshing each shortly before its TTL expires.
+package warm
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ColinSchofield/zai-weather/src/breaker"
+	"github.com/ColinSchofield/zai-weather/src/cache"
+	"github.com/ColinSchofield/zai-weather/src/config"
+	"github.com/ColinSchofield/zai-weather/src/metrics"
+	"github.com/ColinSchofield/zai-weather/src/model"
+	"github.com/ColinSchofield/zai-weather/src/quota"
+	"github.com/ColinSchofield/zai-weather/src/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// The most cities whose requests are counted (further cities are ignored, until the counts decay)
+	maxTracked = 10000
+	// The counts of the requests are halved at this interval, so that the most requested cities follow the traffic
+	decayInterval = time.Hour
+	// The cities refreshed at the same time
+	concurrency = 4
+)
+
+// The Refresher interface fetches the weather information of a location through the weather services, caching it.
+type Refresher interface {
+	Refresh(ctx context.Context, location string) (*model.Weather, error)
+}
+
+// A Provider is a weather service, that may be called to pre-warm the cache should its budget allow and its circuit
+// breaker let the requests through.
+type Provider struct {
+	Quota   quota.Tracker
+	Breaker *breaker.Breaker
+}
+
+// The warm.Warmer interface counts the requests for each city, and pre-warms the cache with the popular cities.
+type Warmer interface {
+	Track(gCtx *gin.Context)
+	Run(ctx context.Context)
+	Warm(ctx context.Context)
+	Cities() []string
+}
+
+type DefaultWarmer struct {
+	cfg          config.Source
+	log          *logrus.Logger
+	weatherCache cache.Weather
+	refresher    Refresher
+	providers    []Provider
+	now          func() time.Time
+
+	mu        sync.Mutex
+	counts    map[string]float64
+	decayedAt time.Time
+	cities    []string // Those pre-warmed in the last round
+}
+
+var _ Warmer = (*DefaultWarmer)(nil)
+
+// NewWarmer returns the default struct for the cache warmer.
+func NewWarmer(
+	cfg config.Source,
+	log *logrus.Logger,
+	weatherCache cache.Weather,
+	refresher Refresher,
+	providers ...Provider,
+) *DefaultWarmer {
+	return &DefaultWarmer{
+		cfg:          cfg,
+		log:          log,
+		weatherCache: weatherCache,
+		refresher:    refresher,
+		providers:    providers,
+		now:          time.Now,
+		counts:       make(map[string]float64),
+		decayedAt:    time.Now(),
+	}
+}
+
+// Track counts the requests for each city that were answered successfully, including those the caller already had
+// (i.e. as middleware, ahead of the weather controller). A failed request may not be written yet, as its problem is
+// only rendered by the Problems middleware.
+func (w *DefaultWarmer) Track(gCtx *gin.Context) {
+	gCtx.Next()
+	if status := gCtx.Writer.Status(); status != http.StatusOK && status != http.StatusNotModified {
+		return
+	}
+	if len(gCtx.Errors) > 0 {
+		return
+	}
+
+	location := gCtx.DefaultQuery("city", config.DefaultLocation)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, found := w.counts[location]; found || len(w.counts) < maxTracked {
+		w.counts[location]++
+	}
+}
+
+// Cities returns the cities to pre-warm: the configured cities, followed by the top N most requested cities.
+func (w *DefaultWarmer) Cities() []string {
+	cfg := w.cfg.Current()
+	var cities []string
+	seen := make(map[string]bool)
+	for _, city := range cfg.WarmCities {
+		if !seen[city] {
+			seen[city] = true
+			cities = append(cities, city)
+		}
+	}
+
+	top := 0
+	for _, city := range w.ranked() {
+		if top >= cfg.WarmTopN {
+			break
+		}
+		if !seen[city] {
+			seen[city] = true
+			cities = append(cities, city)
+			top++
+		}
+	}
+
+	return cities
+}
+
+// Run pre-warms the cache straight away, and then every lead seconds (as the lead may be reloaded), until the context
+// is done.
+func (w *DefaultWarmer) Run(ctx context.Context) {
+	for {
+		w.Warm(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Duration(w.cfg.Current().WarmLeadSeconds) * time.Second):
+		}
+	}
+}
+
+// Warm refreshes each of the cities that is missing from the cache, or whose TTL expires within the lead. The cities
+// are skipped should none of the weather services be available (i.e. their budgets are exhausted, or their circuit
+// breakers are open).
+func (w *DefaultWarmer) Warm(ctx context.Context) {
+	w.decay()
+	cities := w.Cities()
+	metrics.WarmCities.Set(float64(len(cities)))
+	w.logChanges(cities)
+
+	lead := time.Duration(w.cfg.Current().WarmLeadSeconds) * time.Second
+	var due []string
+	for _, city := range cities {
+		entry, found := w.weatherCache.Lookup(city)
+		if found && entry.Fresh && entry.TTL-w.now().Sub(entry.StoredAt) > lead {
+			continue
+		}
+		due = append(due, city)
+	}
+	if len(due) == 0 {
+		return
+	}
+	if !w.available() {
+		w.log.WithField("cities", len(due)).Warn("Skipped pre-warming the cache, as no weather service is available")
+		metrics.WarmRefreshes.WithLabelValues("skipped").Add(float64(len(due)))
+		return
+	}
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, concurrency)
+	for _, city := range due {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(city string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			w.refresh(ctx, city)
+		}(city)
+	}
+	wg.Wait()
+}
+
+// Refresh the city, forgetting a city that could not be found (so that it is no longer among the most requested).
+func (w *DefaultWarmer) refresh(ctx context.Context, city string) {
+	if _, err := w.refresher.Refresh(ctx, city); err != nil {
+		w.log.WithError(err).WithField("location", city).Warn("Failed to pre-warm the cache")
+		metrics.WarmRefreshes.WithLabelValues("failed").Inc()
+		if service.IsNotFound(err) {
+			w.mu.Lock()
+			delete(w.counts, city)
+			w.mu.Unlock()
+		}
+		return
+	}
+
+	w.log.WithField("location", city).Debug("Pre-warmed the cache")
+	metrics.WarmRefreshes.WithLabelValues("refreshed").Inc()
+}
+
+// Returns true should any of the weather services be available.
+func (w *DefaultWarmer) available() bool {
+	for _, provider := range w.providers {
+		if _, allowed := provider.Quota.Allow(); allowed && provider.Breaker.Available() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the requested cities, the most requested first.
+func (w *DefaultWarmer) ranked() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	cities := make([]string, 0, len(w.counts))
+	for city := range w.counts {
+		cities = append(cities, city)
+	}
+	sort.Slice(cities, func(i, j int) bool {
+		if w.counts[cities[i]] != w.counts[cities[j]] {
+			return w.counts[cities[i]] > w.counts[cities[j]]
+		}
+		return cities[i] < cities[j]
+	})
+
+	return cities
+}
+
+// Halve the counts of the requests at the decay interval, forgetting the cities that are rarely requested.
+func (w *DefaultWarmer) decay() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for ; w.now().Sub(w.decayedAt) >= decayInterval; w.decayedAt = w.decayedAt.Add(decayInterval) {
+		for city, count := range w.counts {
+			if count /= 2; count < 1 {
+				delete(w.counts, city)
+			} else {
+				w.counts[city] = count
+			}
+		}
+	}
+}
+
+// Log the cities being pre-warmed, whenever these change.
+func (w *DefaultWarmer) logChanges(cities []string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if slices.Equal(w.cities, cities) {
+		return
+	}
+	w.cities = cities
+	w.log.WithField("cities", cities).Info("Pre-warming the cache")
+}
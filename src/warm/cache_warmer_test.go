@@ -0,0 +1,176 @@
+package warm
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ColinSchofield/zai-weather/src/breaker"
+	"github.com/ColinSchofield/zai-weather/src/cache"
+	"github.com/ColinSchofield/zai-weather/src/config"
+	"github.com/ColinSchofield/zai-weather/src/model"
+	"github.com/ColinSchofield/zai-weather/src/quota"
+	"github.com/ColinSchofield/zai-weather/src/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/suite"
+)
+
+// The stubRefresher caches the weather information of each location refreshed (failing for the unknown locations).
+type stubRefresher struct {
+	weatherCache cache.Weather
+
+	mu        sync.Mutex
+	refreshed []string
+}
+
+func (r *stubRefresher) Refresh(_ context.Context, location string) (*model.Weather, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.refreshed = append(r.refreshed, location)
+	if location == "Nowhere" {
+		return nil, &service.FetchError{Kind: service.KindNotFound}
+	}
+	weather := &model.Weather{Data: &model.Data{}}
+	r.weatherCache.Set(location, weather, "primary", 0)
+	return weather, nil
+}
+
+type CacheWarmerTestSuite struct {
+	suite.Suite
+
+	log          *logrus.Logger
+	cfg          *config.WeatherConfig
+	now          time.Time
+	weatherCache *cache.DefaultWeatherCache
+	refresher    *stubRefresher
+	breakers     *breaker.DefaultFactory
+	tracker      *quota.DefaultTracker
+	warmer       *DefaultWarmer
+}
+
+func TestCacheWarmerSuite(t *testing.T) {
+	suite.Run(t, new(CacheWarmerTestSuite))
+}
+
+func (s *CacheWarmerTestSuite) SetupTest() {
+	s.log = logrus.New()
+	s.log.SetOutput(io.Discard)
+	s.cfg = &config.WeatherConfig{CacheTTLSeconds: 60}
+	s.cfg.WarmLeadSeconds = 5
+	s.now = time.Now()
+	s.weatherCache = cache.NewWeatherCache(time.Minute)
+	s.refresher = &stubRefresher{weatherCache: s.weatherCache}
+
+	breakerStore, _ := breaker.NewFileStore("")
+	s.breakers = breaker.NewFactory(s.log, breakerStore)
+	cb := s.breakers.New("primary", "primary", func() breaker.Settings {
+		return breaker.Settings{Policy: breaker.Ratio, Requests: 1, FailureRatio: 1, HalfOpenRequests: 1}
+	})
+	quotaStore := quota.NewFileStore(logrus.New(), "")
+	s.tracker = quota.NewTracker(s.log, quotaStore, "primary", 1, 0, 1, 0)
+
+	s.warmer = NewWarmer(s.cfg, s.log, s.weatherCache, s.refresher, Provider{Quota: s.tracker, Breaker: cb})
+	s.warmer.now = func() time.Time { return s.now }
+	s.warmer.decayedAt = s.now
+}
+
+// Request each of the cities, answering with the status given.
+func (s *CacheWarmerTestSuite) request(status int, cities ...string) {
+	router := gin.New()
+	router.GET("v1/weather", s.warmer.Track, func(gCtx *gin.Context) { gCtx.Status(status) })
+	for _, city := range cities {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/weather?city="+city, nil))
+	}
+}
+
+func (s *CacheWarmerTestSuite) Test_StaticCitiesThenTheMostRequested() {
+	// Given
+	s.cfg.WarmCities = []string{"Hobart", "Darwin", "Hobart"}
+	s.cfg.WarmTopN = 2
+	// When
+	s.request(http.StatusOK, "Perth", "Sydney", "Sydney", "Hobart", "Hobart", "Hobart", "Adelaide")
+	// Then
+	s.Assert().Equal([]string{"Hobart", "Darwin", "Sydney", "Adelaide"}, s.warmer.Cities(),
+		"Ranked by the requests, then by name")
+}
+
+func (s *CacheWarmerTestSuite) Test_OnlySuccessfulRequestsAreCounted() {
+	// Given
+	s.cfg.WarmTopN = 5
+	// When
+	s.request(http.StatusNotFound, "Nowhere")
+	s.request(http.StatusOK, "Sydney")
+	s.request(http.StatusNotModified, "Perth")
+	// Then
+	s.Assert().Equal([]string{"Perth", "Sydney"}, s.warmer.Cities(), "A 304 is answered successfully")
+}
+
+func (s *CacheWarmerTestSuite) Test_WarmTheMissingAndExpiringCities() {
+	// Given
+	s.cfg.WarmCities = []string{"Melbourne", "Sydney", "Perth", "Darwin"}
+	s.weatherCache.Set("Melbourne", &model.Weather{}, "primary", 0)
+	s.weatherCache.Restore(cache.Entry{Key: "Sydney", Value: &model.Weather{}, StoredAt: s.now.Add(-57 * time.Second)})
+	s.weatherCache.Restore(cache.Entry{
+		Key: "Darwin", Value: &model.Weather{}, StoredAt: s.now.Add(-57 * time.Second), TTL: 10 * time.Minute,
+	})
+	// When
+	s.warmer.Warm(context.Background())
+	// Then
+	s.Assert().ElementsMatch([]string{"Sydney", "Perth"}, s.refresher.refreshed,
+		"Melbourne and Darwin (by its own TTL) are fresh, whereas Sydney expires within the lead")
+	_, found := s.weatherCache.Get("Perth")
+	s.Assert().True(found)
+}
+
+func (s *CacheWarmerTestSuite) Test_SkippedWhileTheBreakerIsOpen() {
+	// Given
+	s.cfg.WarmCities = []string{"Melbourne"}
+	_, _ = s.breakers.SetMode("primary", breaker.ForcedOpen)
+	logs := test.NewLocal(s.log)
+	// When
+	s.warmer.Warm(context.Background())
+	// Then
+	s.Assert().Empty(s.refresher.refreshed)
+	s.Require().NotNil(logs.LastEntry())
+	s.Assert().Equal(logrus.WarnLevel, logs.LastEntry().Level, "Noticed at the default level")
+}
+
+func (s *CacheWarmerTestSuite) Test_SkippedOnceTheBudgetIsExhausted() {
+	// Given
+	s.cfg.WarmCities = []string{"Melbourne"}
+	s.tracker.Record()
+	// When
+	s.warmer.Warm(context.Background())
+	// Then
+	s.Assert().Empty(s.refresher.refreshed)
+}
+
+func (s *CacheWarmerTestSuite) Test_UnknownCitiesAreForgotten() {
+	// Given
+	s.cfg.WarmTopN = 1
+	s.request(http.StatusOK, "Nowhere")
+	// When
+	s.warmer.Warm(context.Background())
+	// Then
+	s.Assert().Equal([]string{"Nowhere"}, s.refresher.refreshed)
+	s.Assert().Empty(s.warmer.Cities())
+}
+
+func (s *CacheWarmerTestSuite) Test_CountsDecay() {
+	// Given
+	s.cfg.WarmTopN = 2
+	s.request(http.StatusOK, "Perth", "Sydney", "Sydney", "Sydney")
+	// When
+	s.now = s.now.Add(decayInterval)
+	s.warmer.decay()
+	// Then
+	s.Assert().Equal([]string{"Sydney"}, s.warmer.Cities(), "Perth was rarely requested")
+}